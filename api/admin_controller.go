@@ -1,121 +1,101 @@
 package api
 
 import (
-	"context"
+    "bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"strings"
+    "sync"
 	"time"
     "os"
     "io"
+    "path"
     "path/filepath"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
     "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/login"
+    "github.com/vinny-pereira/personal-blog/internal/mail"
     "github.com/vinny-pereira/personal-blog/internal/repository"
 )
 
-func HandleAdminEndpoints(){
+// adminMeta keeps the admin pages out of search results.
+var adminMeta = repository.PageMeta{Title: "Admin", NoIndex: true}
+
+func HandleAdminEndpoints(m mail.Mailer){
+    mailer = m
+    logins = login.NewLimiter(settings.Login)
+    resetRequests = login.NewLimiter(settings.Login)
+    challenges = login.NewChallenges()
+    enrolments = login.NewEnrolments()
+
     http.HandleFunc("/admin", handleAdmin)
     http.HandleFunc("/authenticate", handleAuthentication)
     http.HandleFunc("/register", handleRegistration)
-    http.HandleFunc("/parse-md", handleParseMarkdown)
-    http.HandleFunc("/create-post", handlePostCreation)
-    http.HandleFunc("/edit-post", handlePostEdit)
-    http.HandleFunc("/delete-post", handlePostDeletion)
-    http.HandleFunc("/upload", handleFileUpload)
-    http.HandleFunc("/create-portfolio", handlePortfolioEntry)
-    http.HandleFunc("/posts-management", handlePostManagement)
-    http.HandleFunc("/portfolio-management", handlePortfolioManagement)
-    http.HandleFunc("/create-portfolio-entry", handlePortfolioEntryCreation)
-    http.HandleFunc("/edit-portfolio", handleEntryEdit)
-    http.HandleFunc("/delete-portfolio", handleEntryDeletion)
+    http.HandleFunc("POST /verify-login", handleLoginVerification)
+    http.HandleFunc("GET /forgot-password", handleForgotPassword)
+    http.HandleFunc("POST /forgot-password", handleResetRequest)
+    http.HandleFunc("GET /reset-password", handleResetForm)
+    http.HandleFunc("POST /reset-password", handlePasswordReset)
+    handleAdminRoutes()
+    handleAPIRoutes()
 }
 
 
 func handleAdmin(w http.ResponseWriter, r *http.Request){
-    if !isAuthenticated(r){
+    session, user, ok := currentSession(r)
+    if !ok{
         showLoginForm(w, r)
     } else{
-        showDashboard(w, r, repository.Post{})
-    }
-}
-
-func isAuthenticated(r *http.Request) bool{
-    cookie, err := r.Cookie("session_token")
-    if err != nil {
-        return false
-    }
-
-    collection := repository.Client.Database("blog").Collection("sessions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
-
-    var session struct {
-        UserId  primitive.ObjectID `bson:"user_id"`
-        Token   string             `bson:"token"`
-        Expires time.Time          `bson:"expires"`
-    }
-
-    err = collection.FindOne(ctx, bson.M{"token": cookie.Value}).Decode(&session)
-    if err != nil {
-        return false
+        keepAlive(w, r, session)
+        if mustEnrol(r, user){
+            showTwoFactorSetup(w, r, session, user)
+            return
+        }
+        showDashboard(w, r, user, repository.Post{})
     }
-
-    return session.Expires.After(time.Now())
 }
 
 func showLoginForm(w http.ResponseWriter, r *http.Request) {
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
-    content, err := internal.RenderTemplate(tmpl, "login", nil)
-    if err != nil {
-        log.Printf("Error rendering login template: %v\n", err)
-        http.Error(w, "Error rendering template.", http.StatusInternalServerError)
-        return
-    }
-    version := uuid.New()
-    data := repository.PageData{
-        Content: template.HTML(content),
-        Version: version.String(),
-    }
-    if err := tmpl.ExecuteTemplate(w, "admin", data); err != nil {
-        log.Println(err)
-        http.Error(w, "Error executing template.", http.StatusInternalServerError)
-    }
+    showAdminPage(w, r, "login", nil)
 }
 
+// publishAtLayout is how <input type="datetime-local"> submits its value.
+const publishAtLayout = "2006-01-02T15:04"
+
 type Editable struct{
     Post repository.Post
     MarkDown template.HTML
 }
 
+func (e Editable) Statuses() []string{
+    return repository.PostStatuses
+}
+
 type DashBoard struct{
+    // User is who is looking, to offer only what their role allows.
+    User repository.User
     Editable Editable
     Posts []repository.Post
+    Reactions []ReactionStat
 }
 
-func showDashboard(w http.ResponseWriter, r *http.Request, p repository.Post) {
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+func showDashboard(w http.ResponseWriter, r *http.Request, user repository.User, p repository.Post) {
+    tmpl := templates.Get()
 
-    posts, err := repository.GetPosts()
+    posts, err := store.GetPosts(r.Context())
     if err != nil{
         http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
+        return
     }
 
     dashBoard := DashBoard{
+        User: user,
         Posts: posts,
+        Reactions: reactionStats(posts),
         Editable: Editable{
             Post: p,
             MarkDown: template.HTML(internal.MdToHtml([]byte(p.Body))),
@@ -128,10 +108,12 @@ func showDashboard(w http.ResponseWriter, r *http.Request, p repository.Post) {
         http.Error(w, "Error rendering template.", http.StatusInternalServerError)
         return
     }
-    version := uuid.New()
     data := repository.PageData{
         Content: template.HTML(content),
-        Version: version.String(),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
+        Meta: adminMeta,
+        User: user,
     }
     if err := tmpl.ExecuteTemplate(w, "admin", data); err != nil {
         log.Println(err)
@@ -139,21 +121,19 @@ func showDashboard(w http.ResponseWriter, r *http.Request, p repository.Post) {
     }
 }
 
-func getPostsTemplate(w http.ResponseWriter, r *http.Request, p repository.Post){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+func getPostsTemplate(w http.ResponseWriter, r *http.Request, user repository.User, p repository.Post){
+    tmpl := templates.Get()
 
-    posts, err := repository.GetPosts()
+    posts, err := store.GetPosts(r.Context())
     if err != nil{
         http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
+        return
     }
 
     dashBoard := DashBoard{
+        User: user,
         Posts: posts,
+        Reactions: reactionStats(posts),
         Editable: Editable{
             Post: p,
             MarkDown: template.HTML(internal.MdToHtml([]byte(p.Body))),
@@ -167,14 +147,9 @@ func getPostsTemplate(w http.ResponseWriter, r *http.Request, p repository.Post)
 }
 
 func getEntriesTemplates(w http.ResponseWriter, r *http.Request, e repository.PortfolioEntry){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
-    entries, err := repository.GetPortfolioEntries()
+    entries, err := store.GetPortfolioEntries(r.Context(), repository.Page{})
     if err != nil{
         http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
     }
@@ -199,24 +174,36 @@ func handleAuthentication(w http.ResponseWriter, r *http.Request){
     username := r.FormValue("username")
     password := r.FormValue("password")
 
-    user, err := repository.AuthenticateUser(username, password)
-    if err != nil {
-        http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+    if wait, locked := logins.Wait(username, clientIP(r)); wait > 0 {
+        tooManyLogins(w, wait, locked)
         return
     }
 
-    sessionToken := uuid.New().String()
-    expiresAt := time.Now().Add(24 * time.Hour)
-
-    http.SetCookie(w, &http.Cookie{
-        Name:    "session_token",
-        Value:   sessionToken,
-        Expires: expiresAt,
-    })
+    user, err := repository.AuthenticateUser(r.Context(), store, username, password, settings.Password)
+    if errors.Is(err, repository.ErrInvalidCredentials) {
+        loginFailed(r, username)
+        http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+        return
+    }
+    if err != nil {
+        log.Printf("Error authenticating: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    if rehashed, err := repository.RehashPassword(r.Context(), store, *user, password, settings.Password); err != nil {
+        log.Printf("Error rehashing password of %s: %v\n", user.Username, err)
+    } else if rehashed {
+        log.Printf("Rehashed password of %s with cost %d\n", user.Username, settings.Password.Cost)
+    }
 
-    sessionErr := storeSession(user.Id, sessionToken, expiresAt)
+    if user.HasTwoFactor() {
+        startChallenge(w, r, *user)
+        return
+    }
+    logins.Success(username)
 
-    if sessionErr != nil{
+    if err := startSession(w, r, user.Id); err != nil{
+        log.Printf("Error storing session: %v\n", err)
         http.Error(w, "Internal Server Error", http.StatusInternalServerError)
         return
     }
@@ -224,89 +211,164 @@ func handleAuthentication(w http.ResponseWriter, r *http.Request){
     http.Redirect(w, r, "/admin", http.StatusSeeOther)
 }
 
-func storeSession(userId primitive.ObjectID, token string, expiresAt time.Time) error {
-    collection := repository.Client.Database("blog").Collection("sessions")
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
+// errRegistrationClosed is why people without an invite can't register
+// once the site has users, unless registration is open.
+var errRegistrationClosed = errors.New("Registration is by invite only")
 
-    _, err := collection.InsertOne(ctx, bson.M{
-        "user_id": userId,
-        "token":   token,
-        "expires": expiresAt,
-    })
-    return err
+// Registration is the form new users fill in, carrying their invite along.
+type Registration struct{
+    Invite string
+    Role   string
+}
+
+// registrationRole is the role someone registering with the invite token
+// gets: the invite's, owner for the very first user, or viewer when
+// registration is open to everyone.
+func registrationRole(r *http.Request, token string) (string, error){
+    if token != ""{
+        invite, err := store.GetInvite(r.Context(), repository.HashToken(token))
+        if errors.Is(err, repository.ErrNotFound) || (err == nil && !invite.Usable(time.Now())){
+            return "", repository.ErrInvalidInvite
+        }
+        if err != nil{
+            return "", err
+        }
+        return invite.Role, nil
+    }
+
+    count, err := store.CountUsers(r.Context())
+    if err != nil{
+        return "", err
+    }
+    if count == 0{
+        return repository.RoleOwner, nil
+    }
+    if settings.Features.Registration{
+        return repository.RoleViewer, nil
+    }
+    return "", errRegistrationClosed
 }
 
+// registrations is held while a registration is stored.
+var registrations sync.Mutex
+
 func handleRegistration(w http.ResponseWriter, r *http.Request){
+    if r.Method != http.MethodGet && r.Method != http.MethodPost{
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    // Held from deciding the role until the user exists, so that two people
+    // registering on an empty site can't both become its owner.
+    if r.Method == http.MethodPost{
+        registrations.Lock()
+        defer registrations.Unlock()
+    }
+
+    token := r.FormValue("invite")
+    role, err := registrationRole(r, token)
+    if errors.Is(err, repository.ErrInvalidInvite) || errors.Is(err, errRegistrationClosed){
+        http.Error(w, err.Error(), http.StatusForbidden)
+        return
+    }
+    if err != nil{
+        log.Printf("Error checking registration: %v\n", err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+
     if r.Method == http.MethodGet{
-        tmpl, err := internal.ParseTemplates()
+        tmpl := templates.Get()
+        content, err := internal.RenderTemplate(tmpl, "register", Registration{Invite: token, Role: role})
         if err != nil {
-            log.Printf("Error loading templates: %v\n", err)
-            http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-            return
-        }
-        content, err := internal.RenderTemplate(tmpl, "register", nil)
-        if err != nil {
-            log.Printf("Error rendering dashboard template: %v\n", err)
+            log.Printf("Error rendering register template: %v\n", err)
             http.Error(w, "Error rendering template.", http.StatusInternalServerError)
             return
         }
-        version := uuid.New()
         data := repository.PageData{
             Content: template.HTML(content),
-            Version: version.String(),
+            Version: assets.Version,
+            CSRF: csrfToken(r),
+            Meta: adminMeta,
         }
-        if err := tmpl.ExecuteTemplate(w, "register", data); err != nil {
+        if err := tmpl.ExecuteTemplate(w, "admin", data); err != nil {
             log.Println(err)
             http.Error(w, "Error executing template.", http.StatusInternalServerError)
         }
+        return
+    }
 
+    username := strings.TrimSpace(r.FormValue("username"))
+    password := r.FormValue("password")
+
+    if len(username) == 0 || len(password) == 0{
+        http.Error(w, "Required fields not filled", http.StatusBadRequest)
         return
     }
-    if r.Method == http.MethodPost{
-        username := r.FormValue("username")
-        password := r.FormValue("password")
 
-        if len(username) == 0 || len(password) == 0{
-            http.Error(w, "Required fields not filled", http.StatusBadRequest)
-        }
+    // Checked before the invite is used up, so a weak password doesn't cost
+    // the invite.
+    if err := repository.CheckPasswordStrength(settings.Password, username, password); err != nil{
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
 
-        err := repository.RegisterUser(username, password)
-        if err != nil{
-            log.Fatal(err)
-            http.Error(w, "Internal server error", http.StatusInternalServerError)
+    _, err = store.GetUserByUsername(r.Context(), username)
+    if err == nil{
+        http.Error(w, "Username already taken", http.StatusConflict)
+        return
+    }
+    if !errors.Is(err, repository.ErrNotFound){
+        log.Printf("Error looking up user: %v\n", err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+
+    // The invite is used up before the user exists, so that two people
+    // racing with the same link can't both register, and given back if
+    // registering them fails.
+    user := repository.User{
+        Id: primitive.NewObjectID(),
+        Username: username,
+        Password: password,
+        Role: role,
+    }
+    if token != ""{
+        _, err = store.UseInvite(r.Context(), repository.HashToken(token), user.Id, time.Now())
+        if errors.Is(err, repository.ErrInvalidInvite){
+            http.Error(w, err.Error(), http.StatusForbidden)
             return
         }
-
-        user, err := repository.AuthenticateUser(username, password)
         if err != nil{
-            log.Fatal(err)
+            log.Printf("Error using invite: %v\n", err)
             http.Error(w, "Internal server error", http.StatusInternalServerError)
             return
         }
+    }
 
-        sessionToken := uuid.New().String()
-        expiresAt := time.Now().Add(24 * time.Hour)
-
-        http.SetCookie(w, &http.Cookie{
-            Name:    "session_token",
-            Value:   sessionToken,
-            Expires: expiresAt,
-        })
-
-        sessionErr := storeSession(user.Id, sessionToken, expiresAt)
-
-        if sessionErr != nil {
-            log.Printf("Error storing session: %v\n", sessionErr)
-            http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-            return
+    registered, err := repository.RegisterUser(r.Context(), store, user, settings.Password)
+    if err != nil && token != ""{
+        if err := store.ReleaseInvite(r.Context(), repository.HashToken(token), user.Id); err != nil{
+            log.Printf("Error releasing invite: %v\n", err)
         }
+    }
+    if errors.Is(err, repository.ErrDuplicate){
+        http.Error(w, "Username already taken", http.StatusConflict)
+        return
+    }
+    if err != nil{
+        log.Printf("Error registering user: %v\n", err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
 
-        http.Redirect(w, r, "/admin", http.StatusSeeOther)
+    if err := startSession(w, r, registered.Id); err != nil{
+        log.Printf("Error storing session: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
         return
     }
 
-    http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    http.Redirect(w, r, "/admin", http.StatusSeeOther)
 }
 
 func handleParseMarkdown(w http.ResponseWriter, r *http.Request){
@@ -331,11 +393,31 @@ func handlePostCreation(w http.ResponseWriter, r *http.Request){
         return
     }
 
+    user := requestUser(r)
+
     idStr := r.URL.Query().Get("id")
     title := r.FormValue("title")
     body := r.FormValue("post-text")
     synopsys := r.FormValue("synopsys")
     coverImage := r.FormValue("cover-image")
+    status := r.FormValue("status")
+    tags := repository.ParseTags(r.FormValue("tags"))
+    category := r.FormValue("category")
+
+    seo, err := postSEO(r)
+    if err != nil{
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    var publishAt time.Time
+    if value := r.FormValue("publish-at"); value != ""{
+        publishAt, err = time.ParseInLocation(publishAtLayout, value, time.Local)
+        if err != nil{
+            http.Error(w, "Invalid publish date", http.StatusBadRequest)
+            return
+        }
+    }
 
     if idStr != primitive.NilObjectID.Hex(){
         id, err := primitive.ObjectIDFromHex(idStr)
@@ -344,21 +426,58 @@ func handlePostCreation(w http.ResponseWriter, r *http.Request){
             return
         }
 
-        post, err := repository.UpdatePost(id, title, body, synopsys, coverImage)
+        stored, err := store.GetPost(r.Context(), idStr)
+        if errors.Is(err, repository.ErrNotFound){
+            http.NotFound(w, r)
+            return
+        }
+        if err != nil{
+            log.Println(err)
+            http.Error(w, "Error fetching post", http.StatusInternalServerError)
+            return
+        }
+        if !user.CanEditPost(stored){
+            http.Error(w, "Forbidden", http.StatusForbidden)
+            return
+        }
+
+        post, err := store.UpdatePost(r.Context(), repository.Post{
+            Id: id,
+            Title: title,
+            Body: body,
+            Synopsys: synopsys,
+            CoverImage: coverImage,
+            Status: status,
+            PublishAt: publishAt,
+            Tags: tags,
+            Category: category,
+            SEO: seo,
+        })
         if err != nil {
             http.Error(w, err.Error(), http.StatusBadRequest)
             return
         }
 
-        getPostsTemplate(w, r, post)
+        getPostsTemplate(w, r, user, post)
     } else { 
-        post, err := repository.CreatePost(title, body, synopsys, coverImage)
+        post, err := store.CreatePost(r.Context(), repository.Post{
+            AuthorId: user.Id,
+            Title: title,
+            Body: body,
+            Synopsys: synopsys,
+            CoverImage: coverImage,
+            Status: status,
+            PublishAt: publishAt,
+            Tags: tags,
+            Category: category,
+            SEO: seo,
+        })
         if err != nil {
             http.Error(w, err.Error(), http.StatusBadRequest)
             return
         }
 
-        getPostsTemplate(w, r, post)
+        getPostsTemplate(w, r, user, post)
     }
 }
 
@@ -368,6 +487,8 @@ func handlePostEdit(w http.ResponseWriter, r *http.Request){
         return
     }
 
+    user := requestUser(r)
+
     if query := r.URL.Query(); !query.Has("id"){
         http.Error(w, "Post id is required", http.StatusBadRequest)
         return
@@ -375,20 +496,20 @@ func handlePostEdit(w http.ResponseWriter, r *http.Request){
 
     id := r.URL.Query().Get("id")
 
-    post, err := repository.GetPost(id); 
+    post, err := store.GetPost(r.Context(), id); 
     if err != nil{
         fmt.Println(err)
         http.Error(w, "Error fetching post", http.StatusInternalServerError)
         return
     }
 
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
+    if !user.CanEditPost(post){
+        http.Error(w, "Forbidden", http.StatusForbidden)
         return
     }
 
+    tmpl := templates.Get()
+
     editable := Editable{
         Post: post,
         MarkDown: template.HTML(internal.MdToHtml([]byte(post.Body))),
@@ -406,6 +527,8 @@ func handlePostDeletion(w http.ResponseWriter, r *http.Request){
         return
     }
 
+    user := requestUser(r)
+
     if query := r.URL.Query(); !query.Has("id"){
         http.Error(w, "Post id is required", http.StatusBadRequest)
         return
@@ -413,7 +536,22 @@ func handlePostDeletion(w http.ResponseWriter, r *http.Request){
 
     id := r.URL.Query().Get("id")
 
-    if err := repository.DeletePost(id); err != nil{
+    post, err := store.GetPost(r.Context(), id)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error fetching post", http.StatusInternalServerError)
+        return
+    }
+    if !user.CanEditPost(post){
+        http.Error(w, "Forbidden", http.StatusForbidden)
+        return
+    }
+
+    if err := store.DeletePost(r.Context(), id); err != nil{
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
@@ -427,52 +565,112 @@ func handlePostDeletion(w http.ResponseWriter, r *http.Request){
     fmt.Println(w)
 }
 
+// maxUpload is how much of an upload is kept in memory, the rest going to
+// temporary files.
+const maxUpload = 10 << 20
+
+// uploadTypes are the only kinds of file that can be uploaded, with the
+// extension each is saved with. Anything else, HTML and SVG above all, would
+// be served from the site's own origin and could script it.
+var uploadTypes = map[string]string{
+    "image/jpeg": ".jpg",
+    "image/png":  ".png",
+    "image/gif":  ".gif",
+    "image/webp": ".webp",
+}
+
+var errUnsupportedUpload = errors.New("Only JPEG, PNG, GIF and WebP images can be uploaded")
+
+// saveUpload stores an uploaded image under a new random name, with the
+// extension of the type its content turns out to be, whatever it was
+// called. Files that aren't images fail with errUnsupportedUpload.
+func saveUpload(file io.Reader) (string, error) {
+    head := make([]byte, 512)
+    n, err := io.ReadFull(file, head)
+    if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+        return "", err
+    }
+    head = head[:n]
+
+    ext, ok := uploadTypes[http.DetectContentType(head)]
+    if !ok {
+        return "", errUnsupportedUpload
+    }
+    filename := uuid.New().String() + ext
+
+    uploadDir := settings.Paths.Uploads
+    if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+        return "", err
+    }
+
+    dst, err := os.Create(filepath.Join(uploadDir, filename))
+    if err != nil {
+        return "", err
+    }
+    defer dst.Close()
+
+    if _, err := io.Copy(dst, io.MultiReader(bytes.NewReader(head), file)); err != nil {
+        return "", err
+    }
+    return filename, dst.Close()
+}
+
+func isImageExt(ext string) bool{
+    for _, e := range uploadTypes{
+        if strings.EqualFold(e, ext){
+            return true
+        }
+    }
+    return false
+}
+
+// Uploads serves the uploaded files in dir under /uploads/. Files from
+// before only images could be uploaded may be anything, so none of them is
+// sniffed as another type, allowed to run scripts, or shown inline unless
+// it is an image.
+func Uploads(dir string) http.Handler{
+    files := http.StripPrefix("/uploads/", http.FileServer(http.Dir(dir)))
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        w.Header().Set("X-Content-Type-Options", "nosniff")
+        w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+        if !isImageExt(path.Ext(r.URL.Path)){
+            w.Header().Set("Content-Disposition", "attachment")
+        }
+        files.ServeHTTP(w, r)
+    })
+}
+
 func handleFileUpload(w http.ResponseWriter, r *http.Request){
     if r.Method != http.MethodPost {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
 
-    err := r.ParseMultipartForm(10 << 20)
+    err := r.ParseMultipartForm(maxUpload)
     if err != nil {
         http.Error(w, "Unable to parse form", http.StatusBadRequest)
         return
     }
 
-    file, header, err := r.FormFile("file")
+    file, _, err := r.FormFile("file")
     if err != nil {
         http.Error(w, "Error retrieving the file", http.StatusBadRequest)
         return
     }
     defer file.Close()
 
-    ext := filepath.Ext(header.Filename)
-    filename := uuid.New().String() + ext
-
-    uploadDir := "./web/wwwroot/uploads"
-    if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-        http.Error(w, "Unable to create upload directory", http.StatusInternalServerError)
+    filename, err := saveUpload(file)
+    if errors.Is(err, errUnsupportedUpload){
+        http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
         return
     }
-
-    dst, err := os.Create(filepath.Join(uploadDir, filename))
     if err != nil {
-        http.Error(w, "Unable to create the file", http.StatusInternalServerError)
+        log.Printf("Error saving upload: %v\n", err)
+        http.Error(w, "Unable to save the file", http.StatusInternalServerError)
         return
     }
-    defer dst.Close()
 
-    if _, err := io.Copy(dst, file); err != nil {
-        http.Error(w, "Unable to write file", http.StatusInternalServerError)
-        return
-    }
-
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
 	if err := tmpl.ExecuteTemplate(w, "cover-image-field", repository.Post{ CoverImage: filename}); err != nil {
 		log.Println(err)
@@ -485,22 +683,22 @@ func handlePortfolioEntry(w http.ResponseWriter, r *http.Request){
 }
 
 func handlePostManagement(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    user := requestUser(r)
+
+    tmpl := templates.Get()
 
-    posts, err := repository.GetPosts()
+    posts, err := store.GetPosts(r.Context())
     if err != nil{
         http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
+        return
     }
 
     p := repository.Post{}
 
     dashBoard := DashBoard{
+        User: user,
         Posts: posts,
+        Reactions: reactionStats(posts),
         Editable: Editable{
             Post: p,
             MarkDown: template.HTML(internal.MdToHtml([]byte(p.Body))),
@@ -519,14 +717,9 @@ type PortfolioManagement struct{
 }
 
 func handlePortfolioManagement(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
-    entries, err := repository.GetPortfolioEntries()
+    entries, err := store.GetPortfolioEntries(r.Context(), repository.Page{})
     if err != nil{
         http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
     }
@@ -548,6 +741,7 @@ func handlePortfolioEntryCreation(w http.ResponseWriter, r *http.Request){
         return
     }
 
+
     idStr := r.URL.Query().Get("id")
     title := r.FormValue("title")
     repo := r.FormValue("repo")
@@ -561,7 +755,13 @@ func handlePortfolioEntryCreation(w http.ResponseWriter, r *http.Request){
             return
         }
 
-        entry, err := repository.UpdateEntry(id, title, repo, url, coverImage)
+        entry, err := store.UpdateEntry(r.Context(), repository.PortfolioEntry{
+            Id: id,
+            Title: title,
+            Repo: repo,
+            Url: url,
+            CoverImage: coverImage,
+        })
         if err != nil {
             http.Error(w, err.Error(), http.StatusBadRequest)
             return
@@ -569,7 +769,12 @@ func handlePortfolioEntryCreation(w http.ResponseWriter, r *http.Request){
 
         getEntriesTemplates(w, r, entry)
     } else { 
-        entry, err := repository.CreatePortfolioEntry(title, repo, url, coverImage)
+        entry, err := store.CreatePortfolioEntry(r.Context(), repository.PortfolioEntry{
+            Title: title,
+            Repo: repo,
+            Url: url,
+            CoverImage: coverImage,
+        })
         if err != nil {
             http.Error(w, err.Error(), http.StatusBadRequest)
             return
@@ -585,6 +790,7 @@ func handleEntryEdit(w http.ResponseWriter, r *http.Request){
         return
     }
 
+
     if query := r.URL.Query(); !query.Has("id"){
         http.Error(w, "Post id is required", http.StatusBadRequest)
         return
@@ -592,19 +798,14 @@ func handleEntryEdit(w http.ResponseWriter, r *http.Request){
 
     id := r.URL.Query().Get("id")
 
-    entry, err := repository.GetEntry(id); 
+    entry, err := store.GetEntry(r.Context(), id); 
     if err != nil{
         fmt.Println(err)
         http.Error(w, "Error fetching post", http.StatusInternalServerError)
         return
     }
 
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
 	if err := tmpl.ExecuteTemplate(w, "portfolio-form", entry); err != nil {
 		log.Println(err)
@@ -618,6 +819,7 @@ func handleEntryDeletion(w http.ResponseWriter, r *http.Request){
         return
     }
 
+
     if query := r.URL.Query(); !query.Has("id"){
         http.Error(w, "Post id is required", http.StatusBadRequest)
         return
@@ -625,7 +827,7 @@ func handleEntryDeletion(w http.ResponseWriter, r *http.Request){
 
     id := r.URL.Query().Get("id")
 
-    if err := repository.DeleteEntry(id); err != nil{
+    if err := store.DeleteEntry(r.Context(), id); err != nil{
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
@@ -0,0 +1,323 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// apiPrefix starts the path of every API endpoint. Only API tokens are
+// accepted under it, never session cookies, which is why protectCSRF lets
+// its requests with one through.
+const apiPrefix = "/api/"
+
+const (
+    // maxAPIBody is how large, in bytes, a JSON request body may be.
+    maxAPIBody = 1 << 20
+    // apiPageSize is how many items a page of an API listing has, unless
+    // asked for fewer.
+    apiPageSize = 50
+)
+
+// apiRoute is an API endpoint and the scope a token needs for it.
+type apiRoute struct{
+    Pattern string
+    Scope   string
+    Handler http.HandlerFunc
+}
+
+// apiRoutes are every endpoint scripts reach with an API token. Like
+// adminRoutes, they are only ever served through authenticateToken.
+var apiRoutes = []apiRoute{
+    {"POST /api/posts", repository.ScopePostsWrite, handleAPIPostCreation},
+    {"PUT /api/posts/{id}", repository.ScopePostsWrite, handleAPIPostUpdate},
+    {"POST /api/media", repository.ScopeMediaWrite, handleAPIUpload},
+    {"GET /api/portfolio", repository.ScopePortfolioRead, handleAPIPortfolio},
+}
+
+// handleAPIRoutes serves apiRoutes, each behind a check of its scope, and
+// answers the rest of apiPrefix with JSON errors instead of site pages.
+func handleAPIRoutes(){
+    for _, route := range apiRoutes{
+        if !repository.IsScope(route.Scope){
+            panic(fmt.Sprintf("API route %q has no valid scope", route.Pattern))
+        }
+        http.Handle(route.Pattern, authenticateToken(route.Scope, route.Handler))
+    }
+    http.HandleFunc(apiPrefix, func(w http.ResponseWriter, r *http.Request){
+        apiError(w, http.StatusNotFound, "Not found")
+    })
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any){
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(v); err != nil{
+        log.Printf("Error writing API response: %v\n", err)
+    }
+}
+
+// apiError answers an API request with status and message, as JSON.
+func apiError(w http.ResponseWriter, status int, message string){
+    writeJSON(w, status, map[string]string{"error": message})
+}
+
+// readJSON decodes the request's body into v, answering the request itself
+// if it can't.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool{
+    decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(v); err != nil{
+        apiError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+        return false
+    }
+    return true
+}
+
+// APIPostSEO is PostSEO as the API reads and writes it.
+type APIPostSEO struct{
+    Title       string `json:"title,omitempty"`
+    Description string `json:"description,omitempty"`
+    Canonical   string `json:"canonical,omitempty"`
+    NoIndex     bool   `json:"noindex,omitempty"`
+}
+
+// APIPost is a post as the API reads and writes it. Saving one sets every
+// field, as the post form does: those left out are emptied.
+type APIPost struct{
+    Id         string     `json:"id,omitempty"`
+    Title      string     `json:"title"`
+    Body       string     `json:"body"`
+    Synopsis   string     `json:"synopsis"`
+    CoverImage string     `json:"cover_image,omitempty"`
+    // Status is draft, scheduled or published, which it defaults to.
+    Status     string     `json:"status,omitempty"`
+    PublishAt  *time.Time `json:"publish_at,omitempty"`
+    Tags       []string   `json:"tags,omitempty"`
+    Category   string     `json:"category,omitempty"`
+    SEO        APIPostSEO `json:"seo"`
+    // Slug and URL are set by the site and only ever written.
+    Slug       string     `json:"slug,omitempty"`
+    URL        string     `json:"url,omitempty"`
+}
+
+// post turns p into the post it saves, or says what is wrong with it.
+func (p APIPost) post() (repository.Post, error){
+    if p.SEO.Canonical != ""{
+        if err := checkCanonical(p.SEO.Canonical); err != nil{
+            return repository.Post{}, err
+        }
+    }
+
+    post := repository.Post{
+        Title: p.Title,
+        Body: p.Body,
+        Synopsys: p.Synopsis,
+        CoverImage: p.CoverImage,
+        Status: p.Status,
+        Tags: p.Tags,
+        Category: p.Category,
+        SEO: repository.PostSEO{
+            Title: p.SEO.Title,
+            Description: p.SEO.Description,
+            Canonical: p.SEO.Canonical,
+            NoIndex: p.SEO.NoIndex,
+        },
+    }
+    if p.PublishAt != nil{
+        post.PublishAt = *p.PublishAt
+    }
+    return post, nil
+}
+
+func apiPost(r *http.Request, post repository.Post) APIPost{
+    p := APIPost{
+        Id: post.Id.Hex(),
+        Title: post.Title,
+        Body: post.Body,
+        Synopsis: post.Synopsys,
+        CoverImage: post.CoverImage,
+        Status: post.Status,
+        Tags: post.Tags,
+        Category: post.Category,
+        SEO: APIPostSEO{
+            Title: post.SEO.Title,
+            Description: post.SEO.Description,
+            Canonical: post.SEO.Canonical,
+            NoIndex: post.SEO.NoIndex,
+        },
+        Slug: post.Slug,
+        URL: siteURL() + post.Permalink(),
+    }
+    if !post.PublishAt.IsZero(){
+        p.PublishAt = &post.PublishAt
+    }
+    return p
+}
+
+// handleAPIPostCreation writes a post by the token's user.
+func handleAPIPostCreation(w http.ResponseWriter, r *http.Request){
+    var p APIPost
+    if !readJSON(w, r, &p){
+        return
+    }
+    post, err := p.post()
+    if err != nil{
+        apiError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    post.AuthorId = requestUser(r).Id
+    post, err = store.CreatePost(r.Context(), post)
+    if err != nil{
+        apiError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    w.Header().Set("Location", siteURL()+post.Permalink())
+    writeJSON(w, http.StatusCreated, apiPost(r, post))
+}
+
+// handleAPIPostUpdate saves over a post the token's user may edit.
+func handleAPIPostUpdate(w http.ResponseWriter, r *http.Request){
+    id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+    if err != nil{
+        apiError(w, http.StatusBadRequest, "Invalid post id")
+        return
+    }
+
+    stored, err := store.GetPost(r.Context(), id.Hex())
+    if errors.Is(err, repository.ErrNotFound){
+        apiError(w, http.StatusNotFound, "Post not found")
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError, "Error fetching post")
+        return
+    }
+    if !requestUser(r).CanEditPost(stored){
+        apiError(w, http.StatusForbidden, "You can't edit this post")
+        return
+    }
+
+    var p APIPost
+    if !readJSON(w, r, &p){
+        return
+    }
+    post, err := p.post()
+    if err != nil{
+        apiError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    post.Id = id
+    post, err = store.UpdatePost(r.Context(), post)
+    if err != nil{
+        apiError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    writeJSON(w, http.StatusOK, apiPost(r, post))
+}
+
+// APIMedia is an uploaded file as the API describes it.
+type APIMedia struct{
+    // Name is what a post's cover_image is set to to use the file.
+    Name string `json:"name"`
+    URL  string `json:"url"`
+}
+
+// handleAPIUpload stores the multipart file field, as the post form's
+// uploads are stored: only images are taken.
+func handleAPIUpload(w http.ResponseWriter, r *http.Request){
+    if err := r.ParseMultipartForm(maxUpload); err != nil{
+        apiError(w, http.StatusBadRequest, "Unable to parse form")
+        return
+    }
+
+    file, _, err := r.FormFile("file")
+    if err != nil{
+        apiError(w, http.StatusBadRequest, "A file field is required")
+        return
+    }
+    defer file.Close()
+
+    name, err := saveUpload(file)
+    if errors.Is(err, errUnsupportedUpload){
+        apiError(w, http.StatusUnsupportedMediaType, err.Error())
+        return
+    }
+    if err != nil{
+        log.Printf("Error saving upload: %v\n", err)
+        apiError(w, http.StatusInternalServerError, "Unable to save the file")
+        return
+    }
+
+    link := siteURL() + "/uploads/" + url.PathEscape(name)
+    w.Header().Set("Location", link)
+    writeJSON(w, http.StatusCreated, APIMedia{Name: name, URL: link})
+}
+
+// APIPortfolioEntry is a portfolio entry as the API reads it.
+type APIPortfolioEntry struct{
+    Id         string    `json:"id"`
+    Title      string    `json:"title"`
+    Date       time.Time `json:"date"`
+    Repo       string    `json:"repo,omitempty"`
+    URL        string    `json:"url,omitempty"`
+    CoverImage string    `json:"cover_image,omitempty"`
+}
+
+// APIPortfolio is a page of the portfolio. Next is passed as page to get
+// the one after it, and is empty on the last.
+type APIPortfolio struct{
+    Entries []APIPortfolioEntry `json:"entries"`
+    Next    string              `json:"next,omitempty"`
+}
+
+// handleAPIPortfolio lists the portfolio newest first, a page at a time.
+func handleAPIPortfolio(w http.ResponseWriter, r *http.Request){
+    after, err := pageCursor(r)
+    if err != nil{
+        apiError(w, http.StatusBadRequest, "Invalid page")
+        return
+    }
+
+    size := apiPageSize
+    if value := r.URL.Query().Get("limit"); value != ""{
+        size, err = strconv.Atoi(value)
+        if err != nil || size < 1 || size > apiPageSize{
+            apiError(w, http.StatusBadRequest, fmt.Sprintf("limit must be from 1 to %d", apiPageSize))
+            return
+        }
+    }
+
+    entries, err := store.GetPortfolioEntries(r.Context(), repository.Page{Limit: size + 1, After: after})
+    if err != nil{
+        log.Println(err)
+        apiError(w, http.StatusInternalServerError, "Couldn't fetch portfolio")
+        return
+    }
+    entries, next := paginate(entries, size)
+
+    page := APIPortfolio{Entries: []APIPortfolioEntry{}, Next: next}
+    for _, entry := range entries{
+        page.Entries = append(page.Entries, APIPortfolioEntry{
+            Id: entry.Id.Hex(),
+            Title: entry.Title,
+            Date: entry.Date,
+            Repo: entry.Repo,
+            URL: entry.Url,
+            CoverImage: entry.CoverImage,
+        })
+    }
+    writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+    "bytes"
+    "encoding/json"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// uploadRequest posts content to the media endpoint as a file called name.
+func uploadRequest(t *testing.T, token, name, content string) *http.Request{
+    t.Helper()
+    var body bytes.Buffer
+    form := multipart.NewWriter(&body)
+    part, err := form.CreateFormFile("file", name)
+    if err != nil{
+        t.Fatal(err)
+    }
+    part.Write([]byte(content))
+    form.Close()
+
+    r := httptest.NewRequest(http.MethodPost, "/api/media", &body)
+    r.Header.Set("Content-Type", form.FormDataContentType())
+    r.Header.Set("Authorization", "Bearer "+token)
+    return r
+}
+
+func TestAPIUpload(t *testing.T){
+    testSite(t)
+    author := testUser(t, "author", repository.RoleAuthor)
+    token, _ := testToken(t, author, time.Time{}, repository.ScopeMediaWrite)
+    handler := authenticateToken(repository.ScopeMediaWrite, http.HandlerFunc(handleAPIUpload))
+
+    tests := []struct{
+        name     string
+        filename string
+        content  string
+        want     int
+    }{
+        {"image", "cover.png", pngHeader, http.StatusCreated},
+        // The name it is uploaded with makes no difference.
+        {"image called html", "cover.html", pngHeader, http.StatusCreated},
+        {"html", "page.html", "<html><script>fetch('/users/role')</script></html>", http.StatusUnsupportedMediaType},
+        {"html called png", "cover.png", "<html><script>fetch('/users/role')</script></html>", http.StatusUnsupportedMediaType},
+        {"svg", "logo.svg", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, http.StatusUnsupportedMediaType},
+        {"javascript", "app.js", "alert(document.cookie)", http.StatusUnsupportedMediaType},
+    }
+
+    for _, test := range tests{
+        t.Run(test.name, func(t *testing.T){
+            w := httptest.NewRecorder()
+            handler.ServeHTTP(w, uploadRequest(t, token, test.filename, test.content))
+            if w.Code != test.want{
+                t.Fatalf("got status %d, want %d: %s", w.Code, test.want, w.Body)
+            }
+            if w.Code != http.StatusCreated{
+                return
+            }
+
+            var media APIMedia
+            if err := json.NewDecoder(w.Body).Decode(&media); err != nil{
+                t.Fatal(err)
+            }
+            if filepath.Ext(media.Name) != ".png"{
+                t.Errorf("saved as %s, want a .png", media.Name)
+            }
+            if !strings.HasPrefix(media.URL, "https://example.com/uploads/"){
+                t.Errorf("URL %s isn't an absolute upload URL", media.URL)
+            }
+        })
+    }
+}
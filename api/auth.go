@@ -0,0 +1,186 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// adminRoute is an admin endpoint and the least trusted role let in.
+type adminRoute struct{
+    Pattern string
+    Role    string
+    Handler http.HandlerFunc
+}
+
+// adminRoutes are every endpoint behind the admin login. They are only ever
+// served through authenticate, so there is no way to add one that skips it.
+// /admin, /authenticate and /register are the only admin pages anonymous
+// users reach.
+var adminRoutes = []adminRoute{
+    {"/posts-management", repository.RoleViewer, handlePostManagement},
+    {"/portfolio-management", repository.RoleViewer, handlePortfolioManagement},
+    {"/parse-md", repository.RoleAuthor, handleParseMarkdown},
+    {"/create-post", repository.RoleAuthor, handlePostCreation},
+    {"/edit-post", repository.RoleAuthor, handlePostEdit},
+    {"/delete-post", repository.RoleAuthor, handlePostDeletion},
+    {"/upload", repository.RoleAuthor, handleFileUpload},
+    {"/create-portfolio", repository.RoleEditor, handlePortfolioEntry},
+    {"/create-portfolio-entry", repository.RoleEditor, handlePortfolioEntryCreation},
+    {"/edit-portfolio", repository.RoleEditor, handleEntryEdit},
+    {"/delete-portfolio", repository.RoleEditor, handleEntryDeletion},
+    {"/comments-management", repository.RoleEditor, handleCommentManagement},
+    {"POST /moderate-comment", repository.RoleEditor, handleCommentModeration},
+    {"GET /users", repository.RoleOwner, handleUserManagement},
+    {"POST /invites", repository.RoleOwner, handleInviteCreation},
+    {"POST /delete-invite", repository.RoleOwner, handleInviteDeletion},
+    {"POST /user-role", repository.RoleOwner, handleRoleChange},
+    {"POST /logout", repository.RoleViewer, handleLogout},
+    {"GET /sessions", repository.RoleViewer, handleSessions},
+    {"POST /revoke-session", repository.RoleViewer, handleSessionRevocation},
+    {"POST /logout-everywhere", repository.RoleViewer, handleLogoutEverywhere},
+    {"GET /security", repository.RoleOwner, handleSecurity},
+    {"POST /unlock", repository.RoleOwner, handleUnlock},
+    {"GET /two-factor", repository.RoleViewer, handleTwoFactor},
+    {"POST /two-factor", repository.RoleViewer, handleTwoFactorSetup},
+    {"POST /disable-two-factor", repository.RoleViewer, handleTwoFactorDisable},
+    {"POST /recovery-codes", repository.RoleViewer, handleRecoveryCodes},
+    {"POST /reset-two-factor", repository.RoleOwner, handleTwoFactorReset},
+    {"POST /require-two-factor", repository.RoleOwner, handleRequireTwoFactor},
+    {"GET /account", repository.RoleViewer, handleAccount},
+    {"POST /password", repository.RoleViewer, handlePasswordChange},
+    {"POST /email", repository.RoleViewer, handleEmailChange},
+    {"GET /tokens", repository.RoleViewer, handleAPITokens},
+    {"POST /tokens", repository.RoleViewer, handleAPITokenCreation},
+    {"POST /revoke-token", repository.RoleViewer, handleAPITokenRevocation},
+}
+
+// twoFactorSetup are the adminRoutes users who must turn on two-factor
+// authentication can still reach: the ones to turn it on, or to leave.
+var twoFactorSetup = map[string]bool{
+    "GET /two-factor": true,
+    "POST /two-factor": true,
+    "POST /logout": true,
+}
+
+// handleAdminRoutes serves adminRoutes from a mux of their own, wrapped in
+// authenticate, and checks each route's role before its handler runs.
+func handleAdminRoutes(){
+    admin := http.NewServeMux()
+    protected := authenticate(admin)
+
+    for _, route := range adminRoutes{
+        if !repository.IsRole(route.Role){
+            panic(fmt.Sprintf("admin route %q has no valid role", route.Pattern))
+        }
+        handler := requireRole(route.Role, route.Handler)
+        if !twoFactorSetup[route.Pattern]{
+            handler = requireTwoFactor(handler)
+        }
+        admin.Handle(route.Pattern, handler)
+        http.Handle(route.Pattern, protected)
+    }
+}
+
+type (
+    userKey    struct{}
+    sessionKey struct{}
+)
+
+// authenticate lets through only requests from signed in users, who are put
+// in the request's context for requestUser, along with their session for
+// requestSession.
+func authenticate(next http.Handler) http.Handler{
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        session, user, ok := currentSession(r)
+        if !ok{
+            unauthorized(w, r)
+            return
+        }
+        keepAlive(w, r, session)
+
+        ctx := context.WithValue(r.Context(), userKey{}, user)
+        ctx = context.WithValue(ctx, sessionKey{}, session)
+        next.ServeHTTP(w, r.WithContext(ctx))
+    })
+}
+
+// requireRole answers 403 to users whose role falls short of role.
+func requireRole(role string, next http.Handler) http.Handler{
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        if !requestUser(r).Can(role){
+            http.Error(w, "Forbidden", http.StatusForbidden)
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// requireTwoFactor turns away users without two-factor authentication while
+// the owner requires it. htmx is sent to the admin page, which has them set
+// it up.
+func requireTwoFactor(next http.Handler) http.Handler{
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        if mustEnrol(r, requestUser(r)){
+            if isHTMX(r){
+                w.Header().Set("HX-Redirect", "/admin")
+            }
+            http.Error(w, "Two-factor authentication is required", http.StatusForbidden)
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// unauthorized answers anonymous requests with a 401. htmx is also told to
+// load the login page instead of swapping the error in.
+func unauthorized(w http.ResponseWriter, r *http.Request){
+    if isHTMX(r){
+        w.Header().Set("HX-Redirect", "/admin")
+    }
+    http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// requestUser is the user authenticate let in. It is the zero User, who can
+// do nothing, outside of adminRoutes.
+func requestUser(r *http.Request) repository.User{
+    user, _ := r.Context().Value(userKey{}).(repository.User)
+    return user
+}
+
+// requestSession is the session authenticate let in.
+func requestSession(r *http.Request) repository.Session{
+    session, _ := r.Context().Value(sessionKey{}).(repository.Session)
+    return session
+}
+
+// currentSession is the request's session, if it has one that hasn't
+// expired, and the user it is signed in as.
+func currentSession(r *http.Request) (repository.Session, repository.User, bool){
+    cookie, err := r.Cookie(sessionCookie)
+    if err != nil {
+        return repository.Session{}, repository.User{}, false
+    }
+
+    session, err := store.GetSession(r.Context(), repository.HashToken(cookie.Value))
+    if err != nil || !session.Expires.After(time.Now()) {
+        return repository.Session{}, repository.User{}, false
+    }
+
+    user, err := store.GetUser(r.Context(), session.UserId)
+    if err != nil {
+        return repository.Session{}, repository.User{}, false
+    }
+
+    return session, user, true
+}
+
+// currentUser is the user signed in with the request's session, if any.
+func currentUser(r *http.Request) (repository.User, bool){
+    _, user, ok := currentSession(r)
+    return user, ok
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+    "errors"
+    "html/template"
+    "log"
+    "net/http"
+    "net"
+    "net/mail"
+    "sort"
+    "strings"
+    "unicode/utf8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+    "github.com/vinny-pereira/personal-blog/internal/spam"
+)
+
+const (
+    maxCommentName  = 80
+    maxCommentEmail = 254
+    maxCommentBody  = 5000
+)
+
+// CommentThread is an approved comment ready to show, with the replies to it.
+type CommentThread struct{
+    repository.Comment
+    Html    template.HTML
+    Replies []CommentThread
+}
+
+// commentThreads nests approved comments under the ones they reply to,
+// keeping them oldest first at every level. A reply whose parent isn't shown
+// is shown at the top level.
+func commentThreads(comments []repository.Comment) []CommentThread{
+    shown := make(map[primitive.ObjectID]bool, len(comments))
+    for _, c := range comments{
+        shown[c.Id] = true
+    }
+
+    replies := map[primitive.ObjectID][]repository.Comment{}
+    var roots []repository.Comment
+    for _, c := range comments{
+        if c.IsReply() && shown[c.ParentId]{
+            replies[c.ParentId] = append(replies[c.ParentId], c)
+        } else{
+            roots = append(roots, c)
+        }
+    }
+
+    var build func([]repository.Comment) []CommentThread
+    build = func(level []repository.Comment) []CommentThread{
+        threads := make([]CommentThread, 0, len(level))
+        for _, c := range level{
+            threads = append(threads, CommentThread{
+                Comment: c,
+                Html: internal.CommentToHtml(c.Body),
+                Replies: build(replies[c.Id]),
+            })
+        }
+        return threads
+    }
+
+    return build(roots)
+}
+
+// CommentForm is the form for a new comment, or a reply when ParentId is set.
+// It keeps what was typed when it has to be shown again with an error. Token
+// is the spam filter's token telling when the form was first served.
+type CommentForm struct{
+    PostId   primitive.ObjectID
+    ParentId primitive.ObjectID
+    Name     string
+    Email    string
+    Body     string
+    Token    string
+    Error    string
+    Sent     bool
+}
+
+func (f CommentForm) IsReply() bool{
+    return !f.ParentId.IsZero()
+}
+
+// validate checks the fields a reader filled in.
+func (f CommentForm) validate() string{
+    switch{
+    case f.Name == "" || f.Email == "" || f.Body == "":
+        return "Please fill in your name, email and comment."
+    case utf8.RuneCountInString(f.Name) > maxCommentName:
+        return "Your name is too long."
+    case len(f.Email) > maxCommentEmail:
+        return "Your email is too long."
+    case utf8.RuneCountInString(f.Body) > maxCommentBody:
+        return "Your comment is too long."
+    }
+
+    if _, err := mail.ParseAddress(f.Email); err != nil{
+        return "Please enter a valid email."
+    }
+    return ""
+}
+
+func renderCommentForm(w http.ResponseWriter, form CommentForm){
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "comment-form", form); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// handleCommentForm serves the form to reply to a comment.
+func handleCommentForm(w http.ResponseWriter, r *http.Request){
+    query := r.URL.Query()
+
+    postId, err := primitive.ObjectIDFromHex(query.Get("post"))
+    if err != nil{
+        http.Error(w, "Invalid post id", http.StatusBadRequest)
+        return
+    }
+
+    form := CommentForm{PostId: postId, Token: spamFilter.Tokens.Issue()}
+    if parent := query.Get("parent"); parent != ""{
+        form.ParentId, err = primitive.ObjectIDFromHex(parent)
+        if err != nil{
+            http.Error(w, "Invalid comment id", http.StatusBadRequest)
+            return
+        }
+    }
+
+    renderCommentForm(w, form)
+}
+
+func handleCommentCreation(w http.ResponseWriter, r *http.Request){
+    postId, err := primitive.ObjectIDFromHex(r.FormValue("post"))
+    if err != nil{
+        http.Error(w, "Invalid post id", http.StatusBadRequest)
+        return
+    }
+
+    form := CommentForm{
+        PostId: postId,
+        Name: strings.TrimSpace(r.FormValue("name")),
+        Email: strings.TrimSpace(r.FormValue("email")),
+        Body: strings.TrimSpace(r.FormValue("body")),
+        Token: r.FormValue("token"),
+    }
+
+    if parent := r.FormValue("parent"); parent != ""{
+        form.ParentId, err = primitive.ObjectIDFromHex(parent)
+        if err != nil{
+            http.Error(w, "Invalid comment id", http.StatusBadRequest)
+            return
+        }
+    }
+
+    post, err := store.GetPost(r.Context(), postId.Hex())
+    if errors.Is(err, repository.ErrNotFound) || (err == nil && !post.IsPublished()){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error fetching post", http.StatusInternalServerError)
+        return
+    }
+
+    // Replies can only be made to comments readers can see.
+    if form.IsReply(){
+        parent, err := store.GetComment(r.Context(), form.ParentId)
+        if errors.Is(err, repository.ErrNotFound) || (err == nil && (parent.PostId != postId || parent.Status != repository.CommentApproved)){
+            http.NotFound(w, r)
+            return
+        }
+        if err != nil{
+            log.Println(err)
+            http.Error(w, "Error fetching comment", http.StatusInternalServerError)
+            return
+        }
+    }
+
+    if form.Error = form.validate(); form.Error != ""{
+        renderCommentForm(w, form)
+        return
+    }
+
+    verdict, status, rejected := spamFilter.Check(spam.Submission{
+        IP: clientIP(r),
+        Name: form.Name,
+        Email: form.Email,
+        Body: form.Body,
+        Honeypot: r.FormValue("website"),
+        Token: form.Token,
+    })
+    if rejected{
+        log.Printf("Comment from %s turned away: %v\n", clientIP(r), verdict.Reasons)
+        http.Error(w, "Too many comments, please try again later", http.StatusTooManyRequests)
+        return
+    }
+
+    // Spam is stored for the classifier to learn from and for admins to
+    // rescue, but the sender is thanked all the same.
+    _, err = store.CreateComment(r.Context(), repository.Comment{
+        PostId: postId,
+        ParentId: form.ParentId,
+        Name: form.Name,
+        Email: form.Email,
+        Body: form.Body,
+        Status: status,
+        Verdict: verdict,
+    })
+    if err != nil{
+        log.Printf("Error storing comment: %v\n", err)
+        http.Error(w, "Error storing comment", http.StatusInternalServerError)
+        return
+    }
+
+    renderCommentForm(w, CommentForm{PostId: postId, ParentId: form.ParentId, Sent: true})
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string{
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil{
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// ModerationItem is a comment in the moderation queue with the post it was
+// left on.
+type ModerationItem struct{
+    repository.Comment
+    Html template.HTML
+    Post repository.Post
+}
+
+// CommentQueue lists the comments with a status, oldest first or, when
+// ByScore is set, most likely spam first.
+type CommentQueue struct{
+    Status  string
+    ByScore bool
+    Items   []ModerationItem
+}
+
+func (q CommentQueue) Statuses() []string{
+    return repository.CommentStatuses
+}
+
+func handleCommentManagement(w http.ResponseWriter, r *http.Request){
+    status := r.URL.Query().Get("status")
+    if status == ""{
+        status = repository.CommentPending
+    }
+
+    comments, err := store.QueryComments(r.Context(), repository.CommentQuery{Status: status})
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch comments", http.StatusInternalServerError)
+        return
+    }
+
+    posts := map[primitive.ObjectID]repository.Post{}
+    queue := CommentQueue{Status: status, ByScore: r.URL.Query().Get("sort") == "score"}
+    for _, c := range comments{
+        post, ok := posts[c.PostId]
+        if !ok{
+            post, err = store.GetPost(r.Context(), c.PostId.Hex())
+            if err != nil && !errors.Is(err, repository.ErrNotFound){
+                log.Println(err)
+                http.Error(w, "Couldn't fetch posts", http.StatusInternalServerError)
+                return
+            }
+            posts[c.PostId] = post
+        }
+
+        queue.Items = append(queue.Items, ModerationItem{
+            Comment: c,
+            Html: internal.CommentToHtml(c.Body),
+            Post: post,
+        })
+    }
+
+    if queue.ByScore{
+        sort.SliceStable(queue.Items, func(i, j int) bool{
+            return queue.Items[i].Verdict.Score > queue.Items[j].Verdict.Score
+        })
+    }
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "comment-queue", queue); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// handleCommentModeration approves, rejects or marks a comment as spam. The
+// comment leaves the queue it was shown in, so nothing is sent back.
+func handleCommentModeration(w http.ResponseWriter, r *http.Request){
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid comment id", http.StatusBadRequest)
+        return
+    }
+
+    _, err = store.SetCommentStatus(r.Context(), id, r.URL.Query().Get("status"))
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if errors.Is(err, repository.ErrInvalidStatus){
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error moderating comment", http.StatusInternalServerError)
+        return
+    }
+
+    w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+const testCSRF = "csrf-token"
+
+// csrfSite serves a form endpoint and an API endpoint behind protectCSRF,
+// with a signed in owner's session cookie and an API token for them.
+func csrfSite(t *testing.T) (http.Handler, *http.Cookie, string){
+    t.Helper()
+    settings = config.Default()
+    settings.Site.URL = "https://example.com"
+    store = repository.NewMemoryStore()
+    ctx := context.Background()
+
+    user, err := store.CreateUser(ctx, repository.User{Username: "owner", Role: repository.RoleOwner})
+    if err != nil{
+        t.Fatal(err)
+    }
+
+    w := httptest.NewRecorder()
+    if err := startSession(w, httptest.NewRequest(http.MethodPost, "/authenticate", nil), user.Id); err != nil{
+        t.Fatal(err)
+    }
+    var session *http.Cookie
+    for _, cookie := range w.Result().Cookies(){
+        if cookie.Name == sessionCookie{
+            session = cookie
+        }
+    }
+    if session == nil{
+        t.Fatal("no session cookie was set")
+    }
+
+    raw, hash, err := repository.NewAPIToken()
+    if err != nil{
+        t.Fatal(err)
+    }
+    _, err = store.CreateAPIToken(ctx, repository.APIToken{
+        UserId: user.Id,
+        Name: "test",
+        TokenHash: hash,
+        Scopes: []string{repository.ScopePostsWrite},
+        Created: time.Now(),
+    })
+    if err != nil{
+        t.Fatal(err)
+    }
+
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        w.WriteHeader(http.StatusNoContent)
+    })
+    mux := http.NewServeMux()
+    mux.Handle("POST /change", authenticate(ok))
+    mux.Handle("POST /api/posts", authenticateToken(repository.ScopePostsWrite, ok))
+    return protectCSRF(mux), session, raw
+}
+
+func TestCSRF(t *testing.T){
+    handler, session, token := csrfSite(t)
+
+    tests := []struct{
+        name    string
+        path    string
+        cookie  bool
+        header  string
+        session bool
+        bearer  bool
+        want    int
+    }{
+        {"missing header", "/change", true, "", true, false, http.StatusForbidden},
+        {"missing cookie", "/change", false, testCSRF, true, false, http.StatusForbidden},
+        {"mismatched header", "/change", true, "other", true, false, http.StatusForbidden},
+        {"matching header", "/change", true, testCSRF, true, false, http.StatusNoContent},
+        {"bearer to API without token", "/api/posts", false, "", false, true, http.StatusNoContent},
+        {"bearer outside API without token", "/change", false, "", true, true, http.StatusForbidden},
+        {"session to API without token", "/api/posts", false, "", true, false, http.StatusForbidden},
+        {"session to API with token", "/api/posts", true, testCSRF, true, false, http.StatusUnauthorized},
+    }
+
+    for _, test := range tests{
+        t.Run(test.name, func(t *testing.T){
+            r := httptest.NewRequest(http.MethodPost, test.path, nil)
+            if test.cookie{
+                r.AddCookie(&http.Cookie{Name: csrfCookie, Value: testCSRF})
+            }
+            if test.header != ""{
+                r.Header.Set(csrfHeader, test.header)
+            }
+            if test.session{
+                r.AddCookie(session)
+            }
+            if test.bearer{
+                r.Header.Set("Authorization", "Bearer "+token)
+            }
+
+            w := httptest.NewRecorder()
+            handler.ServeHTTP(w, r)
+            if w.Code != test.want{
+                t.Errorf("got status %d, want %d: %s", w.Code, test.want, w.Body)
+            }
+        })
+    }
+}
+
+func TestCSRFIssuesToken(t *testing.T){
+    handler, _, _ := csrfSite(t)
+
+    w := httptest.NewRecorder()
+    handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/change", nil))
+    for _, cookie := range w.Result().Cookies(){
+        if cookie.Name == csrfCookie{
+            if cookie.Value == "" || !cookie.Secure || !cookie.HttpOnly{
+                t.Errorf("CSRF cookie %v should be set, secure and HTTP only", cookie)
+            }
+            return
+        }
+    }
+    t.Error("no CSRF cookie was set")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/feed"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// feedPostCount is how many of the latest posts a feed carries.
+const feedPostCount = 20
+
+// handleFeeds serves every feed format for the whole blog and for each tag,
+// e.g. /feed.xml and /tags/go/atom.xml.
+func handleFeeds(){
+    for _, format := range feed.Formats{
+        http.HandleFunc("GET /"+format.Name, feedHandler(format))
+        http.HandleFunc("GET /tags/{tag}/"+format.Name, feedHandler(format))
+    }
+}
+
+func feedHandler(format feed.Format) http.HandlerFunc{
+    return func(w http.ResponseWriter, r *http.Request){
+        q := repository.PublishedPosts
+        q.Limit = feedPostCount
+        title := settings.Site.Title
+        link := "/blog"
+
+        if raw := r.PathValue("tag"); raw != ""{
+            tag := repository.NormalizeTag(raw)
+            if tag == ""{
+                http.NotFound(w, r)
+                return
+            }
+            if tag != raw{
+                http.Redirect(w, r, "/tags/"+tag+"/"+format.Name, http.StatusMovedPermanently)
+                return
+            }
+
+            q.Tag = tag
+            title = fmt.Sprintf("%s: posts tagged #%s", title, tag)
+            link = "/tags/" + tag
+        }
+
+        posts, err := store.QueryPosts(r.Context(), q)
+        if err != nil{
+            log.Printf("Error fetching posts for %s: %v\n", r.URL.Path, err)
+            http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
+            return
+        }
+
+        // Like the tag's page, the feed of a tag nothing is published under
+        // doesn't exist.
+        if q.Tag != "" && len(posts) == 0{
+            http.NotFound(w, r)
+            return
+        }
+
+        base := siteURL()
+        f := postsFeed(base, posts)
+        f.Title = title
+        f.Link = base + link
+        f.Self = base + r.URL.Path
+
+        body, err := format.Encode(f)
+        if err != nil{
+            log.Printf("Error encoding %s: %v\n", r.URL.Path, err)
+            http.Error(w, "Error writing feed.", http.StatusInternalServerError)
+            return
+        }
+
+        serveCached(w, r, format.ContentType, f.Updated, body)
+    }
+}
+
+// postsFeed turns posts into a feed's items, linking to the site at base.
+func postsFeed(base string, posts []repository.Post) feed.Feed{
+    f := feed.Feed{
+        Description: settings.Site.Description,
+        Author: settings.Site.Author,
+    }
+
+    for _, post := range posts{
+        if post.LastModified().After(f.Updated){
+            f.Updated = post.LastModified()
+        }
+
+        item := feed.Item{
+            Id: base + "/post?id=" + post.Id.Hex(),
+            Title: post.Title,
+            Link: base + post.Permalink(),
+            Summary: post.Synopsys,
+            Content: feed.AbsoluteUploads(string(internal.MdToHtml([]byte(post.Body))), base),
+            Published: post.Date,
+            Updated: post.LastModified(),
+            Tags: post.Tags,
+        }
+        if post.CoverImage != ""{
+            item.Image = base + "/uploads/" + url.PathEscape(post.CoverImage)
+        }
+        f.Items = append(f.Items, item)
+    }
+    return f
+}
+
+// serveCached writes a generated document, such as a feed, with an ETag of
+// its content and the time it last changed as Last-Modified, answering
+// conditional requests from clients that already have it with 304 Not
+// Modified.
+func serveCached(w http.ResponseWriter, r *http.Request, contentType string, modified time.Time, body []byte){
+    sum := sha256.Sum256(body)
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+    w.Header().Set("Cache-Control", "no-cache")
+    http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// siteURL is the configured public URL of the site without a trailing
+// slash. It is empty when none is configured, which leaves links relative:
+// the Host header a request came with is never trusted to make them, since
+// feeds, the sitemap and link previews are cached with whatever it said.
+func siteURL() string{
+    return strings.TrimSuffix(settings.Site.URL, "/")
+}
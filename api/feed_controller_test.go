@@ -0,0 +1,263 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/feed"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// feedEntry is what the tests check of an item, whichever the format.
+type feedEntry struct{
+    Id      string
+    Link    string
+    Content string
+    Image   string
+}
+
+// parseFeed reads back the self link and entries of a feed in format.
+func parseFeed(t *testing.T, format feed.Format, body []byte) (string, []feedEntry){
+    t.Helper()
+    type link struct{
+        Href string `xml:"href,attr"`
+        Rel  string `xml:"rel,attr"`
+    }
+
+    var self string
+    var entries []feedEntry
+    var err error
+    switch format.Name{
+    case feed.RSSFormat.Name:
+        var doc struct{
+            Channel struct{
+                Self  link `xml:"http://www.w3.org/2005/Atom link"`
+                Items []struct{
+                    Guid      string `xml:"guid"`
+                    Link      string `xml:"link"`
+                    Content   string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+                    Enclosure struct{
+                        URL string `xml:"url,attr"`
+                    } `xml:"enclosure"`
+                } `xml:"item"`
+            } `xml:"channel"`
+        }
+        err = xml.Unmarshal(body, &doc)
+        self = doc.Channel.Self.Href
+        for _, item := range doc.Channel.Items{
+            entries = append(entries, feedEntry{item.Guid, item.Link, item.Content, item.Enclosure.URL})
+        }
+    case feed.AtomFormat.Name:
+        var doc struct{
+            Links   []link `xml:"link"`
+            Entries []struct{
+                Id      string `xml:"id"`
+                Links   []link `xml:"link"`
+                Content string `xml:"content"`
+            } `xml:"entry"`
+        }
+        err = xml.Unmarshal(body, &doc)
+        for _, l := range doc.Links{
+            if l.Rel == "self"{
+                self = l.Href
+            }
+        }
+        for _, entry := range doc.Entries{
+            e := feedEntry{Id: entry.Id, Content: entry.Content}
+            for _, l := range entry.Links{
+                switch l.Rel{
+                case "alternate":
+                    e.Link = l.Href
+                case "enclosure":
+                    e.Image = l.Href
+                }
+            }
+            entries = append(entries, e)
+        }
+    case feed.JSONFormat.Name:
+        var doc struct{
+            FeedURL string `json:"feed_url"`
+            Items   []struct{
+                Id          string `json:"id"`
+                URL         string `json:"url"`
+                ContentHTML string `json:"content_html"`
+                Image       string `json:"image"`
+            } `json:"items"`
+        }
+        err = json.Unmarshal(body, &doc)
+        self = doc.FeedURL
+        for _, item := range doc.Items{
+            entries = append(entries, feedEntry{item.Id, item.URL, item.ContentHTML, item.Image})
+        }
+    }
+
+    if err != nil{
+        t.Fatalf("%s doesn't parse: %v\n%s", format.Name, err, body)
+    }
+    return self, entries
+}
+
+// feedSite has more published posts than a feed carries, each with a cover
+// and an uploaded image in its body, a draft and a post tagged go.
+func feedSite(t *testing.T) *http.ServeMux{
+    testSite(t)
+    ctx := context.Background()
+    now := time.Now()
+
+    for i := 0; i < feedPostCount+5; i++{
+        _, err := store.CreatePost(ctx, repository.Post{
+            Title: fmt.Sprintf("Post %d", i),
+            Body: "An image:\n\n![diagram](/uploads/diagram.png)\n",
+            CoverImage: "cover.png",
+            PublishAt: now.Add(time.Duration(i-100) * time.Minute),
+        })
+        if err != nil{
+            t.Fatal(err)
+        }
+    }
+    _, err := store.CreatePost(ctx, repository.Post{Title: "Tagged", Tags: []string{"go"}, PublishAt: now.Add(-24 * time.Hour)})
+    if err == nil{
+        _, err = store.CreatePost(ctx, repository.Post{Title: "Draft", Status: repository.StatusDraft})
+    }
+    if err != nil{
+        t.Fatal(err)
+    }
+
+    mux := http.NewServeMux()
+    for _, format := range feed.Formats{
+        mux.HandleFunc("GET /"+format.Name, feedHandler(format))
+        mux.HandleFunc("GET /tags/{tag}/"+format.Name, feedHandler(format))
+    }
+    return mux
+}
+
+func TestFeeds(t *testing.T){
+    mux := feedSite(t)
+
+    for _, format := range feed.Formats{
+        t.Run(format.Name, func(t *testing.T){
+            r := httptest.NewRequest(http.MethodGet, "/"+format.Name, nil)
+            // Links are never made from the host a request claims.
+            r.Host = "evil.example"
+            w := httptest.NewRecorder()
+            mux.ServeHTTP(w, r)
+
+            if w.Code != http.StatusOK{
+                t.Fatalf("status %d: %s", w.Code, w.Body)
+            }
+            if got := w.Header().Get("Content-Type"); got != format.ContentType{
+                t.Errorf("Content-Type %q, want %q", got, format.ContentType)
+            }
+            if strings.Contains(w.Body.String(), "evil.example"){
+                t.Error("feed links to the request's host")
+            }
+
+            self, entries := parseFeed(t, format, w.Body.Bytes())
+            if self != "https://example.com/"+format.Name{
+                t.Errorf("self link %q", self)
+            }
+            if len(entries) != feedPostCount{
+                t.Fatalf("%d entries, want the latest %d", len(entries), feedPostCount)
+            }
+            if !strings.Contains(entries[0].Link, "post-24"){
+                t.Errorf("first entry links to %s, want the newest post", entries[0].Link)
+            }
+
+            for _, e := range entries{
+                if !strings.HasPrefix(e.Id, "https://example.com/post?id=") || !strings.HasPrefix(e.Link, "https://example.com/blog/"){
+                    t.Errorf("entry id %q, link %q, want absolute URLs of the site", e.Id, e.Link)
+                }
+                if !strings.Contains(e.Content, `src="https://example.com/uploads/diagram.png"`){
+                    t.Errorf("content %q, want the upload linked absolutely", e.Content)
+                }
+                if e.Image != "https://example.com/uploads/cover.png"{
+                    t.Errorf("image %q, want the cover's absolute URL", e.Image)
+                }
+            }
+        })
+    }
+}
+
+func TestTagFeed(t *testing.T){
+    mux := feedSite(t)
+
+    tests := []struct{
+        path     string
+        status   int
+        location string
+        entries  int
+    }{
+        {"/tags/go/atom.xml", http.StatusOK, "", 1},
+        {"/tags/Go/atom.xml", http.StatusMovedPermanently, "/tags/go/atom.xml", 0},
+        {"/tags/nothing/atom.xml", http.StatusNotFound, "", 0},
+    }
+    for _, test := range tests{
+        w := httptest.NewRecorder()
+        mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+        if w.Code != test.status{
+            t.Fatalf("%s: status %d, want %d", test.path, w.Code, test.status)
+        }
+        if got := w.Header().Get("Location"); got != test.location{
+            t.Errorf("%s: redirected to %q, want %q", test.path, got, test.location)
+        }
+        if test.status == http.StatusOK{
+            if _, entries := parseFeed(t, feed.AtomFormat, w.Body.Bytes()); len(entries) != test.entries{
+                t.Errorf("%s: %d entries, want %d", test.path, len(entries), test.entries)
+            }
+        }
+    }
+}
+
+func TestFeedCaching(t *testing.T){
+    mux := feedSite(t)
+
+    w := httptest.NewRecorder()
+    mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.xml", nil))
+    etag := w.Header().Get("ETag")
+    if etag == "" || w.Header().Get("Last-Modified") == ""{
+        t.Fatalf("ETag %q, Last-Modified %q", etag, w.Header().Get("Last-Modified"))
+    }
+
+    r := httptest.NewRequest(http.MethodGet, "/feed.xml", nil)
+    r.Header.Set("If-None-Match", etag)
+    w = httptest.NewRecorder()
+    mux.ServeHTTP(w, r)
+    if w.Code != http.StatusNotModified{
+        t.Errorf("status %d for an unchanged feed, want %d", w.Code, http.StatusNotModified)
+    }
+}
+
+func TestSiteURL(t *testing.T){
+    testSite(t)
+
+    tests := []struct{
+        configured string
+        want       string
+    }{
+        {"https://example.com", "https://example.com"},
+        {"https://example.com/", "https://example.com"},
+        {"https://example.com/blog/", "https://example.com/blog"},
+        // Without one, links are relative rather than made from the Host
+        // header.
+        {"", ""},
+    }
+    for _, test := range tests{
+        settings.Site.URL = test.configured
+        if got := siteURL(); got != test.want{
+            t.Errorf("siteURL() with %q = %q, want %q", test.configured, got, test.want)
+        }
+    }
+
+    // robots.txt only points at a sitemap there is one.
+    w := httptest.NewRecorder()
+    handleRobots(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+    if strings.Contains(w.Body.String(), "Sitemap:"){
+        t.Errorf("robots.txt without site.url:\n%s", w.Body)
+    }
+}
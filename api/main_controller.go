@@ -1,59 +1,79 @@
 package api
 
 import (
+    "errors"
+    "fmt"
     "html/template"
     "log"
     "net/http"
-    "fmt"
-    "regexp"
-    "github.com/google/uuid"
-    "go.mongodb.org/mongo-driver/bson"
+    "net/url"
+    "strings"
     "go.mongodb.org/mongo-driver/bson/primitive"
     "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/config"
     "github.com/vinny-pereira/personal-blog/internal/repository"
+    "github.com/vinny-pereira/personal-blog/internal/search"
+    "github.com/vinny-pereira/personal-blog/internal/spam"
+)
+
+// settings, store, templates, assets, index and spamFilter are what the
+// server was started with. They are set by HandleEndpoints, which must run
+// before HandleAdminEndpoints.
+var (
+    settings   *config.Config
+    store      repository.Store
+    templates  *internal.Templates
+    assets     *internal.Assets
+    index      *search.Index
+    spamFilter *spam.Filter
 )
 
-func HandleEndpoints(){ 
-    fs := http.FileServer(http.Dir("./web/wwwroot/dist"))
-    http.Handle("/dist/", http.StripPrefix("/dist/", fs))
+func HandleEndpoints(cfg *config.Config, s repository.Store, t *internal.Templates, a *internal.Assets, ix *search.Index, f *spam.Filter){ 
+    settings = cfg
+    store = s
+    templates = t
+    assets = a
+    index = ix
+    spamFilter = f
+    initSecret(cfg.Secret)
+
+    http.Handle("/dist/", http.StripPrefix("/dist/", a.Handler()))
     http.HandleFunc("/", handleIndex)
     http.HandleFunc("/contact", handleContact)
     http.HandleFunc("/home", handleHome)
     http.HandleFunc("/blog", handleBlog)
-    http.HandleFunc("/like", handleLikeIncrement)
+    http.HandleFunc("POST /reactions", handleReaction)
+    http.HandleFunc("DELETE /reactions", handleUnreaction)
     http.HandleFunc("/post", handleReadPost)
+    http.HandleFunc("GET /blog/{year}/{month}/{slug}", handlePermalink)
+    http.HandleFunc("GET /tags/{tag}", handleTag)
+    http.HandleFunc("GET /category/{name}", handleCategory)
     http.HandleFunc("/search-posts", handleSearchPosts)
     http.HandleFunc("/portfolio-card", handlePortfolioCard)
+    http.HandleFunc("GET /comment-form", handleCommentForm)
+    http.HandleFunc("POST /comments", handleCommentCreation)
+    http.HandleFunc("GET /portfolio-entries", handlePortfolioEntries)
+    http.HandleFunc("GET /robots.txt", handleRobots)
+
+    // Feeds and sitemaps are made of absolute URLs only.
+    if siteURL() != ""{
+        handleFeeds()
+        handleSitemaps()
+    } else{
+        log.Println("site.url isn't set: feeds and the sitemap are off, and links are relative")
+    }
 }
 
 func handleIndex(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
-
-    entries, err := repository.GetPortfolioEntries()
-    if err != nil{
-        log.Printf("Error rendereing home template: %v\n", err)
-        http.Error(w, "Error rendering template", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
-    posts, err := repository.GetPosts()
+    home, err := loadHome(r)
     if err != nil{
         log.Printf("Error rendereing home template: %v\n", err)
         http.Error(w, "Error rendering template", http.StatusInternalServerError)
         return
     }
 
-    home := Home{
-        Entries: entries,
-        DefaultEntry: entries[0],
-        Posts: posts[:5],
-    }
-
     content, err := internal.RenderTemplate(tmpl, "home", home)
     if err != nil {
         log.Printf("Error rendering home template: %v\n", err)
@@ -61,10 +81,11 @@ func handleIndex(w http.ResponseWriter, r *http.Request){
         return
     }
 
-    version := uuid.New()
     data := repository.PageData{
         Content: template.HTML(content),
-        Version: version.String(),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
+        Meta: homeMeta(r),
     }
 
     if err := tmpl.ExecuteTemplate(w, "index", data); err != nil {
@@ -74,12 +95,7 @@ func handleIndex(w http.ResponseWriter, r *http.Request){
 }
 
 func handleContact(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
     content, err := internal.RenderTemplate(tmpl, "contact", nil)
     if err != nil {
@@ -88,10 +104,10 @@ func handleContact(w http.ResponseWriter, r *http.Request){
         return
     }
 
-    version := uuid.New()
     data := repository.PageData{
         Content: template.HTML(content),
-        Version: version.String(),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
     }
 
     if err := tmpl.ExecuteTemplate(w, "contact", data); err != nil {
@@ -101,150 +117,447 @@ func handleContact(w http.ResponseWriter, r *http.Request){
 }
 
 type Home struct{
-    Entries         []repository.PortfolioEntry
-    DefaultEntry    repository.PortfolioEntry
+    Entries         EntriesPage
+    DefaultEntry    *repository.PortfolioEntry
     Posts           []repository.Post
 }
 
-func handleHome(w http.ResponseWriter, r *http.Request){
+// EntriesPage is a page of the portfolio list. More is the URL of the next
+// page, if there is one.
+type EntriesPage struct{
+    Entries []repository.PortfolioEntry
+    More    string
+}
 
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
+const (
+    homePostCount = 5
+    entriesPageSize = 10
+)
+
+func loadHome(r *http.Request) (Home, error){
+    entries, err := loadEntries(r, repository.Cursor{})
+    if err != nil{
+        return Home{}, err
+    }
+
+    q := repository.PublishedPosts
+    q.Limit = homePostCount
+
+    posts, err := store.QueryPosts(r.Context(), q)
+    if err != nil{
+        return Home{}, err
+    }
+    markReactions(r, posts)
+
+    home := Home{
+        Entries: entries,
+        Posts: posts,
+    }
+
+    if len(entries.Entries) > 0{
+        home.DefaultEntry = &entries.Entries[0]
+    }
+
+    return home, nil
+}
+
+func loadEntries(r *http.Request, after repository.Cursor) (EntriesPage, error){
+    entries, err := store.GetPortfolioEntries(r.Context(), repository.Page{
+        Limit: entriesPageSize + 1,
+        After: after,
+    })
+    if err != nil{
+        return EntriesPage{}, err
+    }
+
+    entries, next := paginate(entries, entriesPageSize)
+
+    page := EntriesPage{Entries: entries}
+    if next != ""{
+        page.More = "/portfolio-entries?page=" + url.QueryEscape(next)
+    }
+    return page, nil
+}
+
+// handlePortfolioEntries serves the next page of the portfolio list.
+func handlePortfolioEntries(w http.ResponseWriter, r *http.Request){
+    after, err := pageCursor(r)
+    if err != nil{
+        http.Error(w, "Invalid page", http.StatusBadRequest)
         return
     }
 
-    entries, err := repository.GetPortfolioEntries()
+    page, err := loadEntries(r, after)
     if err != nil{
-        log.Printf("Error rendereing home template: %v\n", err)
-        http.Error(w, "Error rendering template", http.StatusInternalServerError)
+        log.Println(err)
+        http.Error(w, "Error fetching portfolio entries", http.StatusInternalServerError)
         return
     }
 
-    posts, err := repository.GetPosts()
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "portfolio-items", page); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// pageCursor reads where the requested page starts from the page parameter.
+// Without it the listing starts from the top.
+func pageCursor(r *http.Request) (repository.Cursor, error){
+    page := r.URL.Query().Get("page")
+    if page == ""{
+        return repository.Cursor{}, nil
+    }
+    return repository.ParseCursor(page)
+}
+
+// paginate trims items, fetched with a limit of size+1, down to size and
+// returns the cursor the next page starts after, or "" on the last page.
+func paginate[T interface{ Cursor() repository.Cursor }](items []T, size int) ([]T, string){
+    if len(items) <= size{
+        return items, ""
+    }
+
+    items = items[:size]
+    return items, items[size-1].Cursor().String()
+}
+
+func handleHome(w http.ResponseWriter, r *http.Request){
+
+    tmpl := templates.Get()
+
+    home, err := loadHome(r)
     if err != nil{
         log.Printf("Error rendereing home template: %v\n", err)
         http.Error(w, "Error rendering template", http.StatusInternalServerError)
         return
     }
 
-    home := Home{
-        Entries: entries,
-        DefaultEntry: entries[0],
-        Posts: posts[:5],
-    }
-
     if err := tmpl.ExecuteTemplate(w, "home", home); err != nil {
         log.Println(err)
         http.Error(w, "Error executing template.", http.StatusInternalServerError)
     }
 }
 
-func handleBlog(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
+// Listing is a page of posts, either the whole blog or the posts under a tag
+// or category. Searching from it stays within the same tag or category.
+type Listing struct{
+    Heading     string
+    Tag         string
+    Category    string
+    Posts       PostsPage
+    Tags        []CloudTag
+}
+
+// PostsPage is a page of a post listing. More is the URL of the next page,
+// if there is one.
+type PostsPage struct{
+    Cards   []PostCard
+    More    string
+}
+
+const blogPageSize = 10
+
+// loadPostsPage fetches the page of published posts matching q that starts
+// after the cursor in the request's page parameter.
+func loadPostsPage(r *http.Request, q repository.PostQuery) (PostsPage, error){
+    after, err := pageCursor(r)
+    if err != nil{
+        return PostsPage{}, err
     }
 
-    data, err := repository.GetPosts()
+    q.Status = repository.StatusPublished
+    q.After = after
+    q.Limit = blogPageSize + 1
+
+    posts, err := store.QueryPosts(r.Context(), q)
     if err != nil{
-        log.Printf("Error fetching Posts: %v\n", err)
-        http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
+        return PostsPage{}, err
     }
 
-    if err := tmpl.ExecuteTemplate(w, "blog", data); err != nil {
-        log.Println(err)
-        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    posts, next := paginate(posts, blogPageSize)
+    markReactions(r, posts)
+
+    page := PostsPage{Cards: postCards(posts)}
+    if next != ""{
+        page.More = listingPath(q) + "?page=" + url.QueryEscape(next)
     }
+    return page, nil
 }
 
-func handleLikeIncrement(w http.ResponseWriter, r *http.Request){
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
+// listingPath is the page listing the posts q filters on.
+func listingPath(q repository.PostQuery) string{
+    switch{
+    case q.Tag != "":
+        return "/tags/" + q.Tag
+    case q.Category != "":
+        return "/category/" + url.PathEscape(q.Category)
+    }
+    return "/blog"
+}
+
+// PostCard is a post in a listing. Match is set on search results and holds
+// the title and a snippet with the searched words highlighted.
+type PostCard struct{
+    repository.Post
+    Match *search.Result
+}
+
+func postCards(posts []repository.Post) []PostCard{
+    cards := make([]PostCard, 0, len(posts))
+    for _, post := range posts{
+        cards = append(cards, PostCard{Post: post})
+    }
+    return cards
+}
+
+// CloudTag is a tag in the tag cloud. Scale sizes it relative to the most
+// used tag, from 0.8 to 1.6.
+type CloudTag struct{
+    repository.TagCount
+    Scale float64
+}
+
+func tagCloud(counts []repository.TagCount) []CloudTag{
+    most := 1
+    for _, c := range counts{
+        most = max(most, c.Count)
+    }
+
+    cloud := make([]CloudTag, 0, len(counts))
+    for _, c := range counts{
+        cloud = append(cloud, CloudTag{
+            TagCount: c,
+            Scale: 0.8 + 0.8*float64(c.Count)/float64(most),
+        })
+    }
+    return cloud
+}
+
+// renderListing shows the published posts matching q. Tag and category pages
+// that match nothing are not found rather than empty. When htmx asks for a
+// later page only its posts are sent, to be appended to the list.
+func renderListing(w http.ResponseWriter, r *http.Request, heading string, q repository.PostQuery){
+    page, err := loadPostsPage(r, q)
+    if errors.As(err, new(*repository.CursorError)){
+        http.Error(w, "Invalid page", http.StatusBadRequest)
+        return
+    }
+    if err != nil{
+        log.Printf("Error fetching Posts: %v\n", err)
+        http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
         return
     }
 
-    if query := r.URL.Query(); !query.Has("id"){
-        http.Error(w, "Post id is required", http.StatusBadRequest)
+    later := r.URL.Query().Has("page")
+    if len(page.Cards) == 0 && !later && (q.Tag != "" || q.Category != ""){
+        http.NotFound(w, r)
         return
     }
 
-    idStr := r.URL.Query().Get("id")
+    if later && isHTMX(r){
+        tmpl := templates.Get()
+        if err := tmpl.ExecuteTemplate(w, "posts-page", page); err != nil{
+            log.Println(err)
+            http.Error(w, "Error executing template.", http.StatusInternalServerError)
+        }
+        return
+    }
 
-    id, err := primitive.ObjectIDFromHex(idStr)
+    counts, err := store.TagCounts(r.Context(), repository.PublishedPosts)
     if err != nil{
-        log.Println(err)
-        http.Error(w, "Invalid Id", http.StatusBadRequest)
+        log.Printf("Error counting tags: %v\n", err)
+        http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
         return
     }
 
-    data, err := repository.IncrementLike(id)
-    if err != nil{
-        log.Println(err)
-        http.Error(w, "Error incrementing likes", http.StatusInternalServerError)
+    meta := pageMeta(r)
+    if q.Tag != "" || q.Category != ""{
+        meta.Title = heading + " | " + settings.Site.Title
+    }
+
+    renderPage(w, r, "blog", Listing{
+        Heading: heading,
+        Tag: q.Tag,
+        Category: q.Category,
+        Posts: page,
+        Tags: tagCloud(counts),
+    }, meta)
+}
+
+func handleBlog(w http.ResponseWriter, r *http.Request){
+    renderListing(w, r, "Search My Posts!", repository.PostQuery{})
+}
+
+func handleTag(w http.ResponseWriter, r *http.Request){
+    tag := repository.NormalizeTag(r.PathValue("tag"))
+    if tag == ""{
+        http.NotFound(w, r)
         return
     }
 
-    if err := tmpl.ExecuteTemplate(w, "like-button", data); err != nil{ log.Println(err)
-    http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    if tag != r.PathValue("tag"){
+        http.Redirect(w, r, "/tags/"+tag, http.StatusMovedPermanently)
+        return
+    }
+
+    renderListing(w, r, "Posts tagged #"+tag, repository.PostQuery{Tag: tag})
 }
+
+func handleCategory(w http.ResponseWriter, r *http.Request){
+    category := repository.NormalizeCategory(r.PathValue("name"))
+    if category == ""{
+        http.NotFound(w, r)
+        return
+    }
+
+    renderListing(w, r, category, repository.PostQuery{Category: category})
 }
 
+// sidebarPostCount is how many other posts are suggested under a post.
+const sidebarPostCount = 3
+
 type PostReadData struct{
     Posts []repository.Post
     Post repository.Post
     MarkDown template.HTML
+    Comments []CommentThread
+    CommentForm CommentForm
 }
 
+// handleReadPost redirects the legacy /post?id= links to the post's permalink.
 func handleReadPost(w http.ResponseWriter, r *http.Request){
     if r.Method != http.MethodGet{
         http.Error(w, "Only get method is accepted", http.StatusMethodNotAllowed)
         return
     }
 
-
     if query := r.URL.Query(); !query.Has("id"){
         http.Error(w, "Post id is required", http.StatusBadRequest)
         return
     }
 
-    idStr := r.URL.Query().Get("id")
+    post, err := store.GetPost(r.Context(), r.URL.Query().Get("id"))
+    if errors.Is(err, repository.ErrNotFound) || (err == nil && !canRead(r, post)){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Invalid id provided", http.StatusBadRequest)
+        return
+    }
+
+    http.Redirect(w, r, post.Permalink(), http.StatusMovedPermanently)
+}
+
+func handlePermalink(w http.ResponseWriter, r *http.Request){
+    slug := r.PathValue("slug")
 
-    posts, err := repository.GetPosts()
+    post, err := store.GetPostBySlug(r.Context(), slug)
+    if errors.Is(err, repository.ErrNotFound) || (err == nil && !canRead(r, post)){
+        http.NotFound(w, r)
+        return
+    }
     if err != nil{
         log.Println(err)
-        http.Error(w, "Error trying to fetch posts", http.StatusInternalServerError)
+        http.Error(w, "Error trying to fetch post", http.StatusInternalServerError)
+        return
+    }
+
+    // Old slugs and a wrong year or month all lead to the canonical URL.
+    year := fmt.Sprintf("%04d", post.Date.Year())
+    month := fmt.Sprintf("%02d", post.Date.Month())
+    if slug != post.Slug || r.PathValue("year") != year || r.PathValue("month") != month{
+        http.Redirect(w, r, post.Permalink(), http.StatusMovedPermanently)
         return
     }
 
-    post, err := repository.GetPost(idStr)
+    // One more than shown in case the post being read is among them.
+    q := repository.PublishedPosts
+    q.Limit = sidebarPostCount + 1
+
+    posts, err := store.QueryPosts(r.Context(), q)
     if err != nil{
         log.Println(err)
-        http.Error(w, "Invalid id provided", http.StatusInternalServerError)
+        http.Error(w, "Error trying to fetch posts", http.StatusInternalServerError)
         return
     }
 
-    markDown := template.HTML(internal.MdToHtml([]byte(post.Body)))
+    posts = internal.RemovePostFromList(posts, post.Id.Hex())
+    if len(posts) > sidebarPostCount{
+        posts = posts[:sidebarPostCount]
+    }
+
+    // The post being read gets its reaction bar along with the others.
+    posts = append(posts, post)
+    markReactions(r, posts)
+    posts, post = posts[:len(posts)-1], posts[len(posts)-1]
+
+    comments, err := store.QueryComments(r.Context(), repository.CommentQuery{PostId: post.Id, Status: repository.CommentApproved})
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error trying to fetch comments", http.StatusInternalServerError)
+        return
+    }
 
     data := PostReadData{
-        Posts: internal.RemovePostFromList(posts, idStr),
+        Posts: posts,
         Post: post,
-        MarkDown: markDown,
+        MarkDown: template.HTML(internal.MdToHtml([]byte(post.Body))),
+        Comments: commentThreads(comments),
+        CommentForm: CommentForm{PostId: post.Id, Token: spamFilter.Tokens.Issue()},
     }
 
-    tmpl, err := internal.ParseTemplates()
+    renderPage(w, r, "read-post", data, postMeta(r, post))
+}
+
+// canRead hides posts that aren't published from everyone but signed in
+// admin users, who can use the permalink to preview them.
+func canRead(r *http.Request, post repository.Post) bool{
+    if post.IsPublished(){
+        return true
+    }
+    user, ok := currentUser(r)
+    return ok && user.Can(repository.RoleViewer)
+}
+
+// isHTMX tells whether the request was made by htmx to swap in a fragment.
+func isHTMX(r *http.Request) bool{
+    return r.Header.Get("HX-Request") == "true"
+}
+
+// renderPage writes the named template on its own for htmx requests and
+// wrapped in the full index page otherwise, so the same URL works both when
+// navigated to inside the site and when opened directly. meta goes in the
+// head of the full page.
+func renderPage(w http.ResponseWriter, r *http.Request, name string, data interface{}, meta repository.PageMeta){
+    tmpl := templates.Get()
+
+    if isHTMX(r){
+        if err := tmpl.ExecuteTemplate(w, name, data); err != nil{
+            log.Println(err)
+            http.Error(w, "Error executing template.", http.StatusInternalServerError)
+        }
+        return
+    }
+
+    content, err := internal.RenderTemplate(tmpl, name, data)
     if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
+        log.Printf("Error rendering %s template: %v\n", name, err)
+        http.Error(w, "Error rendering template.", http.StatusInternalServerError)
         return
     }
 
-    if err := tmpl.ExecuteTemplate(w, "read-post", data); err != nil{
+    page := repository.PageData{
+        Content: template.HTML(content),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
+        Meta: meta,
+    }
+
+    if err := tmpl.ExecuteTemplate(w, "index", page); err != nil {
         log.Println(err)
         http.Error(w, "Error executing template.", http.StatusInternalServerError)
     }
@@ -256,42 +569,88 @@ func handleSearchPosts(w http.ResponseWriter, r *http.Request){
         return
     }
 
-    search := r.URL.Query().Get("search-text")
+    query := r.URL.Query()
+    text := query.Get("search-text")
 
-    escapedSearch := regexp.QuoteMeta(search)
-    pattern := fmt.Sprintf("^%s", escapedSearch)
+    q := repository.PostQuery{
+        Status: repository.StatusPublished,
+        Tag: repository.NormalizeTag(query.Get("tag")),
+        Category: repository.NormalizeCategory(query.Get("category")),
+    }
 
-    var posts []repository.Post
+    var page PostsPage
     var err error
-
-    if len(escapedSearch) == 0{
-        posts, err = repository.GetPosts()
+    if strings.TrimSpace(text) == ""{
+        page, err = loadPostsPage(r, q)
     } else{
-        filter := bson.M{
-            "title": primitive.Regex{Pattern: pattern, Options: "i"},
-        }
-
-        posts, err = repository.QueryPosts(filter)
+        // Results come ranked by relevance rather than by date, so they
+        // aren't paged.
+        page.Cards, err = searchPosts(r, q, index.Search(text))
     }
 
     if err != nil{
         log.Println(err)
         http.Error(w, err.Error(), http.StatusInternalServerError)
-    }
-
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
         return
     }
 
-    if err := tmpl.ExecuteTemplate(w, "posts-list", posts); err != nil{
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "posts-list", page); err != nil{
         log.Println(err)
         http.Error(w, "Error executing template.", http.StatusInternalServerError)
     }
 }
 
+// maxSearchResults is how many posts a search shows at most, so that one
+// matching most of the blog doesn't load all of it on every keystroke.
+const maxSearchResults = 20
+
+// searchPosts loads the best ranked results that match q. The index also
+// holds drafts and posts outside the tag or category asked for, so results
+// are fetched a batch at a time until there are enough.
+func searchPosts(r *http.Request, q repository.PostQuery, results []search.Result) ([]PostCard, error){
+    var cards []PostCard
+    for len(results) > 0 && len(cards) < maxSearchResults{
+        batch := results[:min(len(results), maxSearchResults)]
+        results = results[len(batch):]
+
+        q.Ids = make([]primitive.ObjectID, len(batch))
+        for i, result := range batch{
+            q.Ids[i] = result.Id
+        }
+        posts, err := store.QueryPosts(r.Context(), q)
+        if err != nil{
+            return nil, err
+        }
+
+        markReactions(r, posts)
+        cards = append(cards, searchCards(posts, batch)...)
+    }
+
+    if len(cards) > maxSearchResults{
+        cards = cards[:maxSearchResults]
+    }
+    return cards, nil
+}
+
+// searchCards keeps the search results that are among posts, in the order
+// they were ranked.
+func searchCards(posts []repository.Post, results []search.Result) []PostCard{
+    byId := make(map[primitive.ObjectID]repository.Post, len(posts))
+    for _, post := range posts{
+        byId[post.Id] = post
+    }
+
+    var cards []PostCard
+    for _, result := range results{
+        if post, ok := byId[result.Id]; ok{
+            cards = append(cards, PostCard{Post: post, Match: &result})
+        }
+    }
+    return cards
+}
+
 func handlePortfolioCard(w http.ResponseWriter, r *http.Request){
     if r.Method != http.MethodGet{
         http.Error(w, "Only get method is accepted", http.StatusMethodNotAllowed)
@@ -305,19 +664,14 @@ func handlePortfolioCard(w http.ResponseWriter, r *http.Request){
 
     idStr := r.URL.Query().Get("id")
 
-    entry, err := repository.GetEntry(idStr)
+    entry, err := store.GetEntry(r.Context(), idStr)
     if err != nil{
         log.Println(err)
         http.Error(w, "Invalid id provided", http.StatusInternalServerError)
         return
     }
 
-    tmpl, err := internal.ParseTemplates()
-    if err != nil {
-        log.Printf("Error loading templates: %v\n", err)
-        http.Error(w, "Error loading templates.", http.StatusInternalServerError)
-        return
-    }
+    tmpl := templates.Get()
 
     if err := tmpl.ExecuteTemplate(w, "portfolio-card", entry); err != nil{
         log.Println(err)
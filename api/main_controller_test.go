@@ -0,0 +1,170 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+    "github.com/vinny-pereira/personal-blog/internal/search"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearchPosts(t *testing.T){
+    testSite(t)
+    index = search.NewIndex()
+
+    add := func(post repository.Post) repository.Post{
+        post, err := store.CreatePost(context.Background(), post)
+        if err != nil{
+            t.Fatal(err)
+        }
+        index.Add(post)
+        return post
+    }
+
+    // Drafts match best, but must never be shown.
+    for i := 0; i < 5; i++{
+        add(repository.Post{Title: fmt.Sprintf("Golang golang draft %d", i), Body: "golang", Status: repository.StatusDraft})
+    }
+    for i := 0; i < 3*maxSearchResults; i++{
+        add(repository.Post{Title: fmt.Sprintf("Post %d", i), Body: "About golang.", Tags: []string{fmt.Sprintf("tag%d", i%3)}})
+    }
+
+    tests := []struct{
+        name  string
+        query repository.PostQuery
+        want  int
+    }{
+        {"capped", repository.PublishedPosts, maxSearchResults},
+        {"filtered by tag", repository.PostQuery{Status: repository.StatusPublished, Tag: "tag1"}, maxSearchResults},
+        {"no match", repository.PostQuery{Status: repository.StatusPublished, Tag: "none"}, 0},
+    }
+    for _, test := range tests{
+        r := httptest.NewRequest(http.MethodGet, "/search-posts?search-text=golang", nil)
+        cards, err := searchPosts(r, test.query, index.Search("golang"))
+        if err != nil{
+            t.Fatal(err)
+        }
+        if len(cards) != test.want{
+            t.Errorf("%s: %d results, want %d", test.name, len(cards), test.want)
+        }
+        for _, card := range cards{
+            if card.Post.Status != repository.StatusPublished || card.Match == nil{
+                t.Errorf("%s: got %+v", test.name, card.Post)
+            }
+            if test.query.Tag != "" && card.Post.Tags[0] != test.query.Tag{
+                t.Errorf("%s: got a post tagged %q", test.name, card.Post.Tags)
+            }
+        }
+    }
+}
+
+func TestPaginate(t *testing.T){
+    posts := make([]repository.Post, 3)
+    for i := range posts{
+        posts[i] = repository.Post{Id: primitive.NewObjectID(), Date: time.Now()}
+    }
+
+    page, next := paginate(posts, 3)
+    if len(page) != 3 || next != ""{
+        t.Errorf("a full last page: %d posts, next %q, want 3 and none", len(page), next)
+    }
+
+    page, next = paginate(posts[:1], 3)
+    if len(page) != 1 || next != ""{
+        t.Errorf("a short last page: %d posts, next %q, want 1 and none", len(page), next)
+    }
+
+    page, next = paginate(posts, 2)
+    if len(page) != 2 || next != posts[1].Cursor().String(){
+        t.Errorf("a page with more after it: %d posts, next %q, want 2 and %q", len(page), next, posts[1].Cursor())
+    }
+}
+
+func TestLoadPostsPage(t *testing.T){
+    testSite(t)
+    ctx := context.Background()
+    date := time.Now().Add(-time.Hour)
+
+    // A page and a half of posts published at the same moment, then a few
+    // more under the same tag and category.
+    for i := 0; i < blogPageSize+blogPageSize/2; i++{
+        _, err := store.CreatePost(ctx, repository.Post{Title: fmt.Sprintf("Tie %d", i), Tags: []string{"tie"}, Category: "Ties", PublishAt: date})
+        if err != nil{
+            t.Fatal(err)
+        }
+    }
+    for i := 0; i < blogPageSize; i++{
+        _, err := store.CreatePost(ctx, repository.Post{Title: fmt.Sprintf("Older %d", i), Category: "Ties", PublishAt: date.Add(-time.Duration(i+1) * time.Minute)})
+        if err != nil{
+            t.Fatal(err)
+        }
+    }
+    if _, err := store.CreatePost(ctx, repository.Post{Title: "Draft", Tags: []string{"tie"}, Category: "Ties", Status: repository.StatusDraft}); err != nil{
+        t.Fatal(err)
+    }
+
+    tests := []struct{
+        name  string
+        query repository.PostQuery
+        path  string
+        want  int
+    }{
+        {"blog", repository.PostQuery{}, "/blog", 2*blogPageSize + blogPageSize/2},
+        {"tag", repository.PostQuery{Tag: "tie"}, "/tags/tie", blogPageSize + blogPageSize/2},
+        {"category", repository.PostQuery{Category: "Ties"}, "/category/Ties", 2*blogPageSize + blogPageSize/2},
+    }
+    for _, test := range tests{
+        seen := map[primitive.ObjectID]bool{}
+        target := test.path
+        var last repository.Cursor
+        for pages := 0; target != ""; pages++{
+            if pages > test.want{
+                t.Fatalf("%s: paging never ends", test.name)
+            }
+
+            page, err := loadPostsPage(httptest.NewRequest(http.MethodGet, target, nil), test.query)
+            if err != nil{
+                t.Fatalf("%s: %v", test.name, err)
+            }
+            if len(page.Cards) > blogPageSize{
+                t.Errorf("%s: %d posts on a page", test.name, len(page.Cards))
+            }
+            for _, card := range page.Cards{
+                if seen[card.Id]{
+                    t.Errorf("%s: %q shown twice", test.name, card.Title)
+                }
+                if !last.IsZero() && !last.Precedes(card.Cursor()){
+                    t.Errorf("%s: %q out of order", test.name, card.Title)
+                }
+                if card.Status != repository.StatusPublished{
+                    t.Errorf("%s: %q is %s", test.name, card.Title, card.Status)
+                }
+                seen[card.Id] = true
+                last = card.Cursor()
+            }
+
+            if page.More != "" && !strings.HasPrefix(page.More, test.path+"?page="){
+                t.Errorf("%s: next page at %q, want it under %s", test.name, page.More, test.path)
+            }
+            target = page.More
+        }
+        if len(seen) != test.want{
+            t.Errorf("%s: paged through %d posts, want %d", test.name, len(seen), test.want)
+        }
+    }
+
+    for _, page := range []string{"tampered", "1." + strings.Repeat("z", 24), "x." + primitive.NewObjectID().Hex()}{
+        r := httptest.NewRequest(http.MethodGet, "/blog?page="+url.QueryEscape(page), nil)
+        var cursorErr *repository.CursorError
+        if _, err := loadPostsPage(r, repository.PostQuery{}); !errors.As(err, &cursorErr){
+            t.Errorf("page %q: %v, want a CursorError", page, err)
+        }
+    }
+}
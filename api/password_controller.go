@@ -0,0 +1,349 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "html/template"
+    "log"
+    "net/http"
+    netmail "net/mail"
+    "net/url"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/login"
+    "github.com/vinny-pereira/personal-blog/internal/mail"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// mailTimeout is how long sending one email may take.
+const mailTimeout = 30 * time.Second
+
+var (
+    // mailer sends password reset links. It is set by HandleAdminEndpoints.
+    mailer mail.Mailer
+    // resetRequests throttles asking for reset links the way logins are
+    // throttled, so they can't be used to flood someone's inbox.
+    resetRequests *login.Limiter
+)
+
+// errNoSiteURL refuses to mail reset links without a configured site.url:
+// the Host of the request asking for one is up to whoever sent it.
+var errNoSiteURL = errors.New("site.url isn't set, so there is no link to send")
+
+// showAdminPage renders a page of the admin for people who aren't signed in.
+func showAdminPage(w http.ResponseWriter, r *http.Request, name string, content any){
+    tmpl := templates.Get()
+    html, err := internal.RenderTemplate(tmpl, name, content)
+    if err != nil {
+        log.Printf("Error rendering %s template: %v\n", name, err)
+        http.Error(w, "Error rendering template.", http.StatusInternalServerError)
+        return
+    }
+    data := repository.PageData{
+        Content: template.HTML(html),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
+        Meta: adminMeta,
+    }
+    if err := tmpl.ExecuteTemplate(w, "admin", data); err != nil {
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+func renderFragment(w http.ResponseWriter, name string, data any){
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, name, data); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// Account is the page where users change their password and email.
+type Account struct{
+    User      repository.User
+    MinLength int
+    Message   string
+    Error     string
+}
+
+func renderAccount(w http.ResponseWriter, user repository.User, message, problem string){
+    renderFragment(w, "account", Account{
+        User: user,
+        MinLength: settings.Password.MinLength,
+        Message: message,
+        Error: problem,
+    })
+}
+
+func handleAccount(w http.ResponseWriter, r *http.Request){
+    renderAccount(w, requestUser(r), "", "")
+}
+
+// checkCurrentPassword makes sure it is the user asking for a change to
+// their account, and not just someone at their unlocked screen. Wrong
+// passwords count as failed logins.
+func checkCurrentPassword(w http.ResponseWriter, r *http.Request, user repository.User) bool{
+    if wait, locked := logins.Wait(user.Username, clientIP(r)); wait > 0{
+        tooManyLogins(w, wait, locked)
+        return false
+    }
+    if !user.CheckPassword(r.FormValue("current")){
+        loginFailed(r, user.Username)
+        renderAccount(w, user, "", "Your current password is wrong.")
+        return false
+    }
+    return true
+}
+
+// handlePasswordChange sets a new password and ends every other session of
+// the user, in case the old password was why it was changed.
+func handlePasswordChange(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    if !checkCurrentPassword(w, r, user){
+        return
+    }
+
+    password := r.FormValue("password")
+    if password != r.FormValue("confirm"){
+        renderAccount(w, user, "", "The new passwords don't match.")
+        return
+    }
+
+    err := repository.ChangePassword(r.Context(), store, user, password, settings.Password)
+    if errors.Is(err, repository.ErrWeakPassword){
+        renderAccount(w, user, "", err.Error())
+        return
+    }
+    if err != nil{
+        log.Printf("Error changing password: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditPasswordChange,
+        Actor: user.Username,
+        Subject: user.Username,
+    })
+
+    if _, err := store.DeleteUserSessions(r.Context(), user.Id); err != nil{
+        log.Printf("Error ending sessions: %v\n", err)
+    }
+    if err := startSession(w, r, user.Id); err != nil{
+        log.Printf("Error storing session: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    renderAccount(w, user, "Your password was changed and you were logged out everywhere else.", "")
+}
+
+// handleEmailChange sets the email password reset links go to.
+func handleEmailChange(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    if !checkCurrentPassword(w, r, user){
+        return
+    }
+
+    email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+    if email != ""{
+        address, err := netmail.ParseAddress(email)
+        if err != nil || address.Address != email{
+            renderAccount(w, user, "", "That isn't an email address.")
+            return
+        }
+    }
+
+    err := store.SetUserEmail(r.Context(), user.Id, email)
+    if errors.Is(err, repository.ErrDuplicate){
+        renderAccount(w, user, "", "Another user has that email.")
+        return
+    }
+    if err != nil{
+        log.Printf("Error changing email: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditEmailChange,
+        Actor: user.Username,
+        Subject: user.Username,
+    })
+
+    user.Email = email
+    renderAccount(w, user, "Your email was saved.", "")
+}
+
+// PasswordReset is the page for choosing a new password with a reset link.
+type PasswordReset struct{
+    Token     string
+    MinLength int
+    // Sent is true once a link was asked for.
+    Sent      bool
+    Lifetime  time.Duration
+    Error     string
+}
+
+func handleForgotPassword(w http.ResponseWriter, r *http.Request){
+    showAdminPage(w, r, "forgot-password", PasswordReset{})
+}
+
+// handleResetRequest mails a reset link to the user with the username or
+// email, if there is one and they gave an email. The answer is the same
+// either way, so it can't be used to find out who has an account.
+func handleResetRequest(w http.ResponseWriter, r *http.Request){
+    name := strings.TrimSpace(r.FormValue("login"))
+    ip := clientIP(r)
+    if wait, locked := resetRequests.Wait(name, ip); wait > 0{
+        tooManyLogins(w, wait, locked)
+        return
+    }
+    resetRequests.Failure(name, ip)
+
+    user, err := store.GetUserByUsername(r.Context(), name)
+    if errors.Is(err, repository.ErrNotFound){
+        user, err = store.GetUserByEmail(r.Context(), strings.ToLower(name))
+    }
+    switch{
+    case err == nil && user.Email != "":
+        if err := sendPasswordReset(r, user); err != nil{
+            log.Printf("Error sending password reset to %s: %v\n", user.Username, err)
+        }
+    case err != nil && !errors.Is(err, repository.ErrNotFound):
+        log.Printf("Error looking up user for password reset: %v\n", err)
+    }
+
+    renderFragment(w, "forgot-password", PasswordReset{Sent: true, Lifetime: settings.Password.ResetLifetime.Duration})
+}
+
+// sendPasswordReset stores a reset for the user and mails them its link.
+// The mail goes out in the background, so answering doesn't take longer for
+// real users.
+func sendPasswordReset(r *http.Request, user repository.User) error{
+    if settings.Site.URL == ""{
+        return errNoSiteURL
+    }
+
+    token, hash, err := repository.NewToken()
+    if err != nil{
+        return err
+    }
+
+    now := time.Now()
+    err = store.CreatePasswordReset(r.Context(), repository.PasswordReset{
+        TokenHash: hash,
+        UserId: user.Id,
+        Created: now,
+        Expires: now.Add(settings.Password.ResetLifetime.Duration),
+    })
+    if err != nil{
+        return err
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditResetRequest,
+        Subject: user.Username,
+    })
+
+    link := strings.TrimSuffix(settings.Site.URL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+    msg := mail.Message{
+        To: user.Email,
+        Subject: "Reset your password for " + settings.Site.Title,
+        Body: fmt.Sprintf("Someone asked to reset the password of %s on %s.\n\n" +
+            "To choose a new one, open this link within %s:\n\n%s\n\n" +
+            "If it wasn't you, ignore this email and your password stays as it is.\n",
+            user.Username, settings.Site.Title, settings.Password.ResetLifetime, link),
+    }
+    go func(){
+        ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+        defer cancel()
+        if err := mailer.Send(ctx, msg); err != nil{
+            log.Printf("Error mailing password reset to %s: %v\n", user.Username, err)
+        }
+    }()
+    return nil
+}
+
+// usableReset is the reset behind a link's token, if it still works.
+func usableReset(r *http.Request, token string) (repository.PasswordReset, bool){
+    reset, err := store.GetPasswordReset(r.Context(), repository.HashToken(token))
+    if err != nil{
+        if !errors.Is(err, repository.ErrNotFound){
+            log.Printf("Error fetching password reset: %v\n", err)
+        }
+        return reset, false
+    }
+    return reset, reset.Usable(time.Now())
+}
+
+func handleResetForm(w http.ResponseWriter, r *http.Request){
+    token := r.URL.Query().Get("token")
+    page := PasswordReset{Token: token, MinLength: settings.Password.MinLength}
+    if _, ok := usableReset(r, token); !ok{
+        page.Error = repository.ErrInvalidReset.Error()
+    }
+    showAdminPage(w, r, "reset-password", page)
+}
+
+// handlePasswordReset sets the new password chosen with a reset link, which
+// is then used up, and logs the user out everywhere. Two-factor
+// authentication, if on, is still asked for at the next login.
+func handlePasswordReset(w http.ResponseWriter, r *http.Request){
+    token := r.FormValue("token")
+    page := PasswordReset{Token: token, MinLength: settings.Password.MinLength}
+
+    reset, ok := usableReset(r, token)
+    if !ok{
+        page.Error = repository.ErrInvalidReset.Error()
+        renderFragment(w, "reset-password", page)
+        return
+    }
+    user, err := store.GetUser(r.Context(), reset.UserId)
+    if err != nil{
+        log.Printf("Error fetching user for password reset: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    password := r.FormValue("password")
+    if password != r.FormValue("confirm"){
+        page.Error = "The passwords don't match."
+        renderFragment(w, "reset-password", page)
+        return
+    }
+    // Checked before the link is used up, so a weak password doesn't cost
+    // the user their link.
+    if err := repository.CheckPasswordStrength(settings.Password, user.Username, password); err != nil{
+        page.Error = err.Error()
+        renderFragment(w, "reset-password", page)
+        return
+    }
+
+    _, err = store.UsePasswordReset(r.Context(), repository.HashToken(token), time.Now())
+    if errors.Is(err, repository.ErrInvalidReset){
+        page.Error = err.Error()
+        renderFragment(w, "reset-password", page)
+        return
+    }
+    if err == nil{
+        err = repository.ChangePassword(r.Context(), store, user, password, settings.Password)
+    }
+    if err != nil{
+        log.Printf("Error resetting password: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditPasswordReset,
+        Subject: user.Username,
+    })
+
+    if _, err := store.DeleteUserSessions(r.Context(), user.Id); err != nil{
+        log.Printf("Error ending sessions: %v\n", err)
+    }
+    logins.Success(user.Username)
+
+    renderFragment(w, "reset-password", PasswordReset{Sent: true})
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+    "slices"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// markReactions fills in the reaction bars of posts as the visitor making the
+// request sees them.
+func markReactions(r *http.Request, posts []repository.Post){
+    if err := fillReactionBars(r.Context(), visitorFrom(r), posts); err != nil{
+        log.Printf("Error fetching reactions: %v\n", err)
+    }
+}
+
+// fillReactionBars sets the reaction bar of each post to the configured
+// reactions, marking those visitor reacted with. An empty visitor reacted
+// with none.
+func fillReactionBars(ctx context.Context, visitor string, posts []repository.Post) error{
+    type key struct{
+        postId primitive.ObjectID
+        emoji  string
+    }
+    mine := map[key]bool{}
+
+    var err error
+    if visitor != "" && len(posts) > 0{
+        var reactions []repository.Reaction
+        reactions, err = store.VisitorReactions(ctx, visitor)
+        for _, reaction := range reactions{
+            mine[key{reaction.PostId, reaction.Emoji}] = true
+        }
+    }
+
+    for i, post := range posts{
+        bar := make([]repository.ReactionButton, 0, len(settings.Reactions.Emoji))
+        for _, emoji := range settings.Reactions.Emoji{
+            bar = append(bar, repository.ReactionButton{
+                Emoji: emoji,
+                Count: post.Reactions[emoji],
+                Mine: mine[key{post.Id, emoji}],
+            })
+        }
+        posts[i].ReactionBar = bar
+    }
+    return err
+}
+
+// reactionTarget reads the post and reaction a request is about.
+func reactionTarget(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, string, bool){
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid Id", http.StatusBadRequest)
+        return id, "", false
+    }
+
+    emoji := r.URL.Query().Get("emoji")
+    if !slices.Contains(settings.Reactions.Emoji, emoji){
+        http.Error(w, "Unknown reaction", http.StatusBadRequest)
+        return id, "", false
+    }
+    return id, emoji, true
+}
+
+func handleReaction(w http.ResponseWriter, r *http.Request){
+    id, emoji, ok := reactionTarget(w, r)
+    if !ok{
+        return
+    }
+
+    post, err := store.GetPost(r.Context(), id.Hex())
+    if errors.Is(err, repository.ErrNotFound) || (err == nil && !post.IsPublished()){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error fetching post", http.StatusInternalServerError)
+        return
+    }
+
+    visitor := ensureVisitor(w, r)
+    if visitor == ""{
+        http.Error(w, "Error reacting to post", http.StatusInternalServerError)
+        return
+    }
+
+    // A reaction that doesn't count, because the visitor or someone at the
+    // same address already reacted so, leaves the bar as it was.
+    post, err = store.React(r.Context(), repository.Reaction{
+        PostId: id,
+        Visitor: visitor,
+        Emoji: emoji,
+        IPHash: hashIP(r),
+        Date: time.Now(),
+    }, time.Now().Add(-settings.Reactions.Window.Duration))
+    if err != nil && !errors.Is(err, repository.ErrAlreadyReacted){
+        log.Println(err)
+        http.Error(w, "Error reacting to post", http.StatusInternalServerError)
+        return
+    }
+
+    renderReactions(w, r, visitor, post)
+}
+
+func handleUnreaction(w http.ResponseWriter, r *http.Request){
+    id, emoji, ok := reactionTarget(w, r)
+    if !ok{
+        return
+    }
+
+    visitor := visitorFrom(r)
+
+    var post repository.Post
+    var err error
+    if visitor != ""{
+        post, err = store.Unreact(r.Context(), id, visitor, emoji)
+    } else{
+        post, err = store.GetPost(r.Context(), id.Hex())
+    }
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error taking back reaction", http.StatusInternalServerError)
+        return
+    }
+
+    renderReactions(w, r, visitor, post)
+}
+
+func renderReactions(w http.ResponseWriter, r *http.Request, visitor string, post repository.Post){
+    posts := []repository.Post{post}
+    if err := fillReactionBars(r.Context(), visitor, posts); err != nil{
+        log.Println(err)
+        http.Error(w, "Error fetching reactions", http.StatusInternalServerError)
+        return
+    }
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "reactions", posts[0]); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// ReactionStat is how often readers reacted with an emoji across all posts,
+// and the post they did so most on.
+type ReactionStat struct{
+    Emoji string
+    Count int
+    Posts int
+    Top   repository.Post
+}
+
+// reactionStats totals the reactions to posts, the configured ones first in
+// their order and then any no longer offered.
+func reactionStats(posts []repository.Post) []ReactionStat{
+    var stats []ReactionStat
+    index := map[string]int{}
+    stat := func(emoji string) *ReactionStat{
+        i, ok := index[emoji]
+        if !ok{
+            i = len(stats)
+            index[emoji] = i
+            stats = append(stats, ReactionStat{Emoji: emoji})
+        }
+        return &stats[i]
+    }
+
+    for _, emoji := range settings.Reactions.Emoji{
+        stat(emoji)
+    }
+
+    for _, post := range posts{
+        emojis := make([]string, 0, len(post.Reactions))
+        for emoji := range post.Reactions{
+            emojis = append(emojis, emoji)
+        }
+        slices.Sort(emojis)
+
+        for _, emoji := range emojis{
+            count := post.Reactions[emoji]
+            if count == 0{
+                continue
+            }
+
+            s := stat(emoji)
+            s.Count += count
+            s.Posts++
+            if count > s.Top.Reactions[emoji]{
+                s.Top = post
+            }
+        }
+    }
+    return stats
+}
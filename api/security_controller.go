@@ -0,0 +1,109 @@
+package api
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "net/http"
+    "strconv"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/login"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// auditLogSize is how many of the latest audit entries the security page
+// shows.
+const auditLogSize = 50
+
+// logins counts failed logins. It is set by HandleAdminEndpoints.
+var logins *login.Limiter
+
+// audit records entry in the audit log, at the time of the request and
+// from its address. Failing to is logged but doesn't fail the request.
+func audit(r *http.Request, entry repository.AuditEntry){
+    entry.Time = time.Now()
+    entry.IP = clientIP(r)
+    if err := store.Audit(r.Context(), entry); err != nil{
+        log.Printf("Error writing audit entry %s for %q: %v\n", entry.Action, entry.Subject, err)
+    }
+}
+
+// loginFailed counts a failed login and audits the lockouts it caused.
+func loginFailed(r *http.Request, username string){
+    for _, lock := range logins.Failure(username, clientIP(r)){
+        log.Printf("Locked out %s %q after %d failed logins\n", lock.Kind, lock.Key, lock.Failures)
+        audit(r, repository.AuditEntry{
+            Action: repository.AuditLockout,
+            Subject: lock.Key,
+            Detail: fmt.Sprintf("%s locked out after %d failed logins until %s", lock.Kind, lock.Failures, lock.Until.Format(time.RFC3339)),
+        })
+    }
+}
+
+// tooManyLogins turns away a login that came before its wait was over,
+// without saying whether the username or the address is to blame.
+func tooManyLogins(w http.ResponseWriter, wait time.Duration, locked bool){
+    w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+    message := "Too many failed logins, try again in %s"
+    if locked{
+        message = "Locked out after too many failed logins, try again in %s"
+    }
+    http.Error(w, fmt.Sprintf(message, wait.Round(time.Second)), http.StatusTooManyRequests)
+}
+
+// Security is the owner's page of lockouts and the audit log.
+type Security struct{
+    Site  repository.SiteSettings
+    Locks []login.Lock
+    Log   []repository.AuditEntry
+}
+
+func renderSecurity(w http.ResponseWriter, r *http.Request){
+    entries, err := store.AuditLog(r.Context(), auditLogSize)
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch audit log", http.StatusInternalServerError)
+        return
+    }
+
+    site, err := store.GetSettings(r.Context())
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch settings", http.StatusInternalServerError)
+        return
+    }
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "security", Security{Site: site, Locks: logins.Locked(), Log: entries}); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+func handleSecurity(w http.ResponseWriter, r *http.Request){
+    renderSecurity(w, r)
+}
+
+// handleUnlock lifts a lockout before it runs out.
+func handleUnlock(w http.ResponseWriter, r *http.Request){
+    kind := r.URL.Query().Get("kind")
+    key := r.URL.Query().Get("key")
+    if kind != login.KindUser && kind != login.KindIP{
+        http.Error(w, "Invalid lock kind", http.StatusBadRequest)
+        return
+    }
+
+    if !logins.Unlock(kind, key){
+        http.NotFound(w, r)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditUnlock,
+        Actor: requestUser(r).Username,
+        Subject: key,
+        Detail: kind + " unlocked",
+    })
+
+    renderSecurity(w, r)
+}
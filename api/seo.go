@@ -0,0 +1,135 @@
+package api
+
+import (
+    "errors"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// pageMeta describes a page with nothing more specific to say about itself
+// than what the site is.
+func pageMeta(r *http.Request) repository.PageMeta{
+    return repository.PageMeta{
+        Title: settings.Site.Title,
+        Description: settings.Site.Description,
+        Canonical: siteURL() + r.URL.Path,
+        SiteName: settings.Site.Title,
+        Type: "website",
+    }
+}
+
+// homeMeta describes the home page, with the author and the site as
+// schema.org Person and WebSite.
+func homeMeta(r *http.Request) repository.PageMeta{
+    base := siteURL()
+    meta := pageMeta(r)
+    meta.Canonical = base + "/"
+    meta.Image = base + "/dist/profile.jpg"
+    meta.LinkedData = []any{
+        map[string]any{
+            "@context": "https://schema.org",
+            "@type": "WebSite",
+            "name": settings.Site.Title,
+            "description": settings.Site.Description,
+            "url": base + "/",
+        },
+        withContext(person(base)),
+    }
+    return meta
+}
+
+// postMeta describes a post as an article, with schema.org BlogPosting.
+// Posts that aren't published, which only admins see, are never indexed.
+func postMeta(r *http.Request, post repository.Post) repository.PageMeta{
+    base := siteURL()
+
+    meta := pageMeta(r)
+    meta.Title = post.MetaTitle()
+    meta.Description = post.MetaDescription()
+    meta.Canonical = base + post.Permalink()
+    if post.SEO.Canonical != ""{
+        meta.Canonical = post.SEO.Canonical
+    }
+    if post.CoverImage != ""{
+        meta.Image = base + "/uploads/" + url.PathEscape(post.CoverImage)
+    }
+    meta.NoIndex = post.SEO.NoIndex || !post.IsPublished()
+    meta.Type = "article"
+    meta.Published = post.Date
+    meta.Modified = post.LastModified()
+    meta.Tags = post.Tags
+
+    posting := map[string]any{
+        "@context": "https://schema.org",
+        "@type": "BlogPosting",
+        "headline": meta.Title,
+        "description": meta.Description,
+        "url": base + post.Permalink(),
+        "mainEntityOfPage": meta.Canonical,
+        "datePublished": meta.Published.UTC().Format(time.RFC3339),
+        "dateModified": meta.Modified.UTC().Format(time.RFC3339),
+        "author": person(base),
+    }
+    if meta.Image != ""{
+        posting["image"] = meta.Image
+    }
+    if len(post.Tags) > 0{
+        posting["keywords"] = post.Tags
+    }
+    if post.Category != ""{
+        posting["articleSection"] = post.Category
+    }
+    meta.LinkedData = []any{posting}
+
+    return meta
+}
+
+// postSEO reads the SEO fields of the post form.
+func postSEO(r *http.Request) (repository.PostSEO, error){
+    seo := repository.PostSEO{
+        Title: strings.TrimSpace(r.FormValue("meta-title")),
+        Description: strings.TrimSpace(r.FormValue("meta-description")),
+        Canonical: strings.TrimSpace(r.FormValue("canonical")),
+        NoIndex: r.FormValue("noindex") == "on",
+    }
+
+    if seo.Canonical != ""{
+        if err := checkCanonical(seo.Canonical); err != nil{
+            return seo, err
+        }
+    }
+    return seo, nil
+}
+
+// checkCanonical makes sure a post's canonical URL is one search engines
+// take.
+func checkCanonical(canonical string) error{
+    u, err := url.Parse(canonical)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == ""{
+        return errors.New("Canonical URL must be an absolute http or https URL")
+    }
+    return nil
+}
+
+// withContext makes a schema.org object a JSON-LD document of its own.
+func withContext(thing map[string]any) map[string]any{
+    thing["@context"] = "https://schema.org"
+    return thing
+}
+
+// person is the site's author as a schema.org Person.
+func person(base string) map[string]any{
+    p := map[string]any{
+        "@type": "Person",
+        "name": settings.Site.Author,
+        "url": base + "/",
+        "image": base + "/dist/profile.jpg",
+    }
+    if len(settings.Site.Links) > 0{
+        p["sameAs"] = settings.Site.Links
+    }
+    return p
+}
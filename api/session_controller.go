@@ -0,0 +1,235 @@
+package api
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+const sessionCookie = "session_token"
+
+// sessionTouchInterval spares the store a write on every request: sessions
+// are only extended once this long has passed since they last were.
+const sessionTouchInterval = time.Minute
+
+// startSession signs the user in with a new session, ending the one the
+// request came with, if any, so that a token planted in the browser before
+// signing in is worth nothing after.
+func startSession(w http.ResponseWriter, r *http.Request, userId primitive.ObjectID) error {
+    if cookie, err := r.Cookie(sessionCookie); err == nil {
+        err := store.DeleteSession(r.Context(), repository.HashToken(cookie.Value))
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return err
+        }
+    }
+
+    token, hash, err := repository.NewToken()
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    session := repository.Session{
+        UserId:    userId,
+        TokenHash: hash,
+        Created:   now,
+        Expires:   now.Add(settings.Session.Lifetime.Duration),
+        LastSeen:  now,
+        UserAgent: r.UserAgent(),
+        IP:        clientIP(r),
+    }
+    if err := store.CreateSession(r.Context(), session); err != nil {
+        return err
+    }
+
+    setSessionCookie(w, r, token, session.Expires)
+    return nil
+}
+
+// keepAlive slides the session's expiry along as it is used, so it only
+// ends after going unused for the session lifetime.
+func keepAlive(w http.ResponseWriter, r *http.Request, session repository.Session){
+    now := time.Now()
+    if now.Sub(session.LastSeen) < sessionTouchInterval{
+        return
+    }
+
+    expires := now.Add(settings.Session.Lifetime.Duration)
+    if err := store.TouchSession(r.Context(), session.TokenHash, now, expires); err != nil{
+        log.Printf("Error extending session: %v\n", err)
+        return
+    }
+    // Only the hash is stored, so the cookie is sent back as it came.
+    if cookie, err := r.Cookie(sessionCookie); err == nil{
+        setSessionCookie(w, r, cookie.Value, expires)
+    }
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time){
+    http.SetCookie(w, &http.Cookie{
+        Name:     sessionCookie,
+        Value:    token,
+        Path:     "/",
+        Expires:  expires,
+        HttpOnly: true,
+        Secure:   secureCookies(),
+        SameSite: http.SameSiteLaxMode,
+    })
+}
+
+// signedOut forgets the browser's session cookie and sends it back to the
+// login form.
+func signedOut(w http.ResponseWriter, r *http.Request){
+    http.SetCookie(w, &http.Cookie{
+        Name:     sessionCookie,
+        Path:     "/",
+        MaxAge:   -1,
+        HttpOnly: true,
+        Secure:   secureCookies(),
+        SameSite: http.SameSiteLaxMode,
+    })
+
+    if isHTMX(r){
+        w.Header().Set("HX-Redirect", "/admin")
+        w.WriteHeader(http.StatusOK)
+        return
+    }
+    http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request){
+    err := store.DeleteSession(r.Context(), requestSession(r).TokenHash)
+    if err != nil && !errors.Is(err, repository.ErrNotFound){
+        log.Printf("Error ending session: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    signedOut(w, r)
+}
+
+// handleLogoutEverywhere ends every session of the user, this one included.
+func handleLogoutEverywhere(w http.ResponseWriter, r *http.Request){
+    ended, err := store.DeleteUserSessions(r.Context(), requestUser(r).Id)
+    if err != nil{
+        log.Printf("Error ending sessions: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    log.Printf("User %s logged out of %d sessions\n", requestUser(r).Username, ended)
+
+    signedOut(w, r)
+}
+
+// ActiveSession is a session as the sessions page shows it.
+type ActiveSession struct{
+    repository.Session
+    Device  string
+    // Current is the session the page is looked at with.
+    Current bool
+}
+
+func renderSessions(w http.ResponseWriter, r *http.Request){
+    sessions, err := store.UserSessions(r.Context(), requestUser(r).Id)
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch sessions", http.StatusInternalServerError)
+        return
+    }
+
+    now := time.Now()
+    var active []ActiveSession
+    for _, session := range sessions{
+        if !session.Expires.After(now){
+            continue
+        }
+        active = append(active, ActiveSession{
+            Session: session,
+            Device: describeDevice(session.UserAgent),
+            Current: session.Id == requestSession(r).Id,
+        })
+    }
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "sessions", active); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+func handleSessions(w http.ResponseWriter, r *http.Request){
+    renderSessions(w, r)
+}
+
+// handleSessionRevocation ends one of the user's other sessions.
+func handleSessionRevocation(w http.ResponseWriter, r *http.Request){
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid session id", http.StatusBadRequest)
+        return
+    }
+
+    err = store.RevokeSession(r.Context(), requestUser(r).Id, id)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error revoking session", http.StatusInternalServerError)
+        return
+    }
+
+    if id == requestSession(r).Id{
+        signedOut(w, r)
+        return
+    }
+    renderSessions(w, r)
+}
+
+// browsers and systems are told apart by the first of their markers found
+// in a user agent. Order matters: Chrome's user agent also says Safari, and
+// Edge's and Opera's also say Chrome.
+var (
+    browsers = [][2]string{
+        {"Edg/", "Edge"},
+        {"OPR/", "Opera"},
+        {"Firefox/", "Firefox"},
+        {"Chrome/", "Chrome"},
+        {"Safari/", "Safari"},
+        {"curl/", "curl"},
+    }
+    systems = [][2]string{
+        {"iPhone", "iOS"},
+        {"iPad", "iPadOS"},
+        {"Android", "Android"},
+        {"Windows", "Windows"},
+        {"Mac OS X", "macOS"},
+        {"CrOS", "ChromeOS"},
+        {"Linux", "Linux"},
+    }
+)
+
+// describeDevice names the browser and system a user agent belongs to, as
+// far as it can tell.
+func describeDevice(userAgent string) string{
+    browser := "Unknown browser"
+    for _, b := range browsers{
+        if strings.Contains(userAgent, b[0]){
+            browser = b[1]
+            break
+        }
+    }
+
+    for _, s := range systems{
+        if strings.Contains(userAgent, s[0]){
+            return browser + " on " + s[1]
+        }
+    }
+    return browser
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+    "github.com/vinny-pereira/personal-blog/internal/sitemap"
+)
+
+// sitemapSize is how many URLs go in one sitemap. Beyond it /sitemap.xml
+// becomes an index of /sitemaps/1.xml, /sitemaps/2.xml and so on.
+const sitemapSize = sitemap.MaxURLs
+
+// crawlerExcluded are kept out of robots.txt's crawl along with every
+// adminRoute: the admin pages outside adminRoutes, the API and the endpoints
+// that only answer htmx with a fragment of a page.
+var crawlerExcluded = []string{
+    "/admin",
+    "/authenticate",
+    "/register",
+    "/verify-login",
+    "/forgot-password",
+    "/reset-password",
+    "/api/",
+    "/home",
+    "/search-posts",
+    "/portfolio-card",
+    "/portfolio-entries",
+    "/comment-form",
+    "/comments",
+    "/reactions",
+}
+
+// crawlerAllowed must stay crawlable whatever is excluded: uploads are the
+// images of posts and of their link previews, whose bots follow robots.txt.
+var crawlerAllowed = []string{"/uploads/"}
+
+// routePath is the path of a ServeMux pattern, without its method and up to
+// its first wildcard.
+func routePath(pattern string) string{
+    if _, path, ok := strings.Cut(pattern, " "); ok{
+        pattern = path
+    }
+    path, _, _ := strings.Cut(pattern, "{")
+    return path
+}
+
+// robotsExcluded lists the paths robots.txt disallows. Disallow rules match
+// path prefixes, so a path that starts one of crawlerAllowed, like /upload
+// does /uploads/, is anchored with $ to only match itself.
+func robotsExcluded() []string{
+    var paths []string
+    add := func(path string){
+        for _, allowed := range crawlerAllowed{
+            if strings.HasPrefix(allowed, path){
+                path += "$"
+                break
+            }
+        }
+        if !slices.Contains(paths, path){
+            paths = append(paths, path)
+        }
+    }
+
+    for _, path := range crawlerExcluded{
+        add(path)
+    }
+    for _, route := range adminRoutes{
+        add(routePath(route.Pattern))
+    }
+    return append(paths, settings.Robots.Disallow...)
+}
+
+func handleSitemaps(){
+    http.HandleFunc("GET /sitemap.xml", handleSitemap)
+    http.HandleFunc("GET /sitemaps/{page}", handleSitemapPage)
+}
+
+// sitemapURLs lists every page worth indexing: the main pages, each
+// published post and each tag's listing, dated to when they last changed.
+func sitemapURLs(r *http.Request) ([]sitemap.URL, error){
+    posts, err := store.QueryPosts(r.Context(), repository.PublishedPosts)
+    if err != nil{
+        return nil, err
+    }
+
+    base := siteURL()
+    var latest time.Time
+    tags := map[string]time.Time{}
+    var postURLs []sitemap.URL
+    for _, post := range posts{
+        // Sitemaps only list the canonical URLs of pages meant to be indexed.
+        if post.SEO.NoIndex || (post.SEO.Canonical != "" && post.SEO.Canonical != base+post.Permalink()){
+            continue
+        }
+
+        modified := post.LastModified()
+        if modified.After(latest){
+            latest = modified
+        }
+        for _, tag := range post.Tags{
+            if modified.After(tags[tag]){
+                tags[tag] = modified
+            }
+        }
+        postURLs = append(postURLs, sitemap.URL{Loc: base + post.Permalink(), LastMod: modified})
+    }
+
+    urls := []sitemap.URL{
+        {Loc: base + "/", LastMod: latest},
+        {Loc: base + "/blog", LastMod: latest},
+        {Loc: base + "/contact"},
+    }
+    urls = append(urls, postURLs...)
+
+    names := make([]string, 0, len(tags))
+    for tag := range tags{
+        names = append(names, tag)
+    }
+    slices.Sort(names)
+    for _, tag := range names{
+        urls = append(urls, sitemap.URL{Loc: base + "/tags/" + url.PathEscape(tag), LastMod: tags[tag]})
+    }
+
+    return urls, nil
+}
+
+// handleSitemap serves the sitemap, or an index of sitemaps when there are
+// too many pages for one.
+func handleSitemap(w http.ResponseWriter, r *http.Request){
+    urls, err := sitemapURLs(r)
+    if err != nil{
+        log.Printf("Error listing pages for the sitemap: %v\n", err)
+        http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
+        return
+    }
+
+    var body []byte
+    if len(urls) <= sitemapSize{
+        body, err = sitemap.Sitemap(urls)
+    } else{
+        var sitemaps []sitemap.URL
+        for i := 0; i*sitemapSize < len(urls); i++{
+            chunk := urls[i*sitemapSize : min((i+1)*sitemapSize, len(urls))]
+            sitemaps = append(sitemaps, sitemap.URL{
+                Loc: fmt.Sprintf("%s/sitemaps/%d.xml", siteURL(), i+1),
+                LastMod: sitemap.Latest(chunk),
+            })
+        }
+        body, err = sitemap.Index(sitemaps)
+    }
+
+    if err != nil{
+        log.Printf("Error writing the sitemap: %v\n", err)
+        http.Error(w, "Error writing sitemap.", http.StatusInternalServerError)
+        return
+    }
+
+    serveCached(w, r, "application/xml; charset=utf-8", sitemap.Latest(urls), body)
+}
+
+// handleSitemapPage serves one of the sitemaps listed in the index.
+func handleSitemapPage(w http.ResponseWriter, r *http.Request){
+    page, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("page"), ".xml"))
+    if err != nil || page < 1 || !strings.HasSuffix(r.PathValue("page"), ".xml"){
+        http.NotFound(w, r)
+        return
+    }
+
+    urls, err := sitemapURLs(r)
+    if err != nil{
+        log.Printf("Error listing pages for the sitemap: %v\n", err)
+        http.Error(w, "Error fetching posts.", http.StatusInternalServerError)
+        return
+    }
+
+    // A site small enough for a single sitemap has no index to page through.
+    start := (page - 1) * sitemapSize
+    if len(urls) <= sitemapSize || start >= len(urls){
+        http.NotFound(w, r)
+        return
+    }
+    urls = urls[start:min(start+sitemapSize, len(urls))]
+
+    body, err := sitemap.Sitemap(urls)
+    if err != nil{
+        log.Printf("Error writing the sitemap: %v\n", err)
+        http.Error(w, "Error writing sitemap.", http.StatusInternalServerError)
+        return
+    }
+
+    serveCached(w, r, "application/xml; charset=utf-8", sitemap.Latest(urls), body)
+}
+
+func handleRobots(w http.ResponseWriter, r *http.Request){
+    var b strings.Builder
+    b.WriteString("User-agent: *\n")
+    for _, path := range crawlerAllowed{
+        fmt.Fprintf(&b, "Allow: %s\n", path)
+    }
+    for _, path := range robotsExcluded(){
+        fmt.Fprintf(&b, "Disallow: %s\n", path)
+    }
+    if base := siteURL(); base != ""{
+        fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", base)
+    }
+
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Write([]byte(b.String()))
+}
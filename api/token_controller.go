@@ -0,0 +1,257 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// tokenTouchInterval spares the store a write on every API request, like
+// sessionTouchInterval: last use is only recorded once this long has passed
+// since it last was, or when the token is used from a new address.
+const tokenTouchInterval = time.Minute
+
+// maxTokenName is how long, in characters, the name of a token may be.
+const maxTokenName = 100
+
+// tokenLifetimes are the days a new token can be made to last for. Zero
+// never expires.
+var tokenLifetimes = []int{30, 90, 365, 0}
+
+// defaultTokenLifetime is the lifetime the form picks to begin with.
+const defaultTokenLifetime = 90
+
+// APITokens is the page where users make and revoke their API tokens.
+type APITokens struct{
+    Tokens    []repository.APIToken
+    // Scopes are the scopes the user's role lets tokens have.
+    Scopes    []string
+    Lifetimes []int
+    Default   int
+    // Created is the token just made. Only its hash is stored, so this is
+    // the one time it can be shown.
+    Created   string
+    Now       time.Time
+    Error     string
+}
+
+func renderAPITokens(w http.ResponseWriter, r *http.Request, created, problem string){
+    user := requestUser(r)
+    tokens, err := store.UserAPITokens(r.Context(), user.Id)
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch API tokens", http.StatusInternalServerError)
+        return
+    }
+
+    renderFragment(w, "api-tokens", APITokens{
+        Tokens: tokens,
+        Scopes: user.UserScopes(),
+        Lifetimes: tokenLifetimes,
+        Default: defaultTokenLifetime,
+        Created: created,
+        Now: time.Now(),
+        Error: problem,
+    })
+}
+
+func handleAPITokens(w http.ResponseWriter, r *http.Request){
+    renderAPITokens(w, r, "", "")
+}
+
+// handleAPITokenCreation makes a token with the scopes ticked on the form,
+// out of the ones the user's role allows.
+func handleAPITokenCreation(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    if err := r.ParseForm(); err != nil{
+        http.Error(w, "Unable to parse form", http.StatusBadRequest)
+        return
+    }
+
+    name := strings.TrimSpace(r.FormValue("name"))
+    if name == "" || utf8.RuneCountInString(name) > maxTokenName{
+        renderAPITokens(w, r, "", fmt.Sprintf("Give the token a name of at most %d characters.", maxTokenName))
+        return
+    }
+
+    var scopes []string
+    for _, scope := range r.Form["scope"]{
+        if !repository.IsScope(scope){
+            http.Error(w, "Invalid scope", http.StatusBadRequest)
+            return
+        }
+        if !user.CanUseScope(scope){
+            renderAPITokens(w, r, "", fmt.Sprintf("Your role can't give tokens the %s scope.", scope))
+            return
+        }
+    }
+    for _, scope := range repository.Scopes{
+        if slices.Contains(r.Form["scope"], scope){
+            scopes = append(scopes, scope)
+        }
+    }
+    if len(scopes) == 0{
+        renderAPITokens(w, r, "", "Give the token at least one scope.")
+        return
+    }
+
+    days, err := strconv.Atoi(r.FormValue("expires"))
+    if err != nil || !slices.Contains(tokenLifetimes, days){
+        http.Error(w, "Invalid token lifetime", http.StatusBadRequest)
+        return
+    }
+
+    raw, hash, err := repository.NewAPIToken()
+    if err != nil{
+        log.Printf("Error generating API token: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    now := time.Now()
+    token := repository.APIToken{
+        UserId: user.Id,
+        Name: name,
+        TokenHash: hash,
+        Scopes: scopes,
+        Created: now,
+    }
+    if days > 0{
+        token.Expires = now.AddDate(0, 0, days)
+    }
+    if _, err := store.CreateAPIToken(r.Context(), token); err != nil{
+        log.Printf("Error storing API token: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditTokenCreate,
+        Actor: user.Username,
+        Subject: user.Username,
+        Detail: fmt.Sprintf("%q with %s", name, strings.Join(scopes, ", ")),
+    })
+
+    renderAPITokens(w, r, raw, "")
+}
+
+// handleAPITokenRevocation deletes one of the user's tokens, which stops
+// working at once.
+func handleAPITokenRevocation(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid token id", http.StatusBadRequest)
+        return
+    }
+
+    err = store.RevokeAPIToken(r.Context(), user.Id, id)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error revoking API token", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditTokenRevoke,
+        Actor: user.Username,
+        Subject: user.Username,
+        Detail: "token " + id.Hex(),
+    })
+
+    renderAPITokens(w, r, "", "")
+}
+
+// bearerToken is the token in the request's Authorization header, if it
+// has one.
+func bearerToken(r *http.Request) (string, bool){
+    scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+    if !ok || !strings.EqualFold(scheme, "Bearer"){
+        return "", false
+    }
+    token = strings.TrimSpace(token)
+    return token, token != ""
+}
+
+// tokenRejected answers a request whose token won't do, saying why in the
+// WWW-Authenticate header the way RFC 6750 asks.
+func tokenRejected(w http.ResponseWriter, status int, challenge, message string){
+    w.Header().Set("WWW-Authenticate", "Bearer"+challenge)
+    apiError(w, status, message)
+}
+
+// authenticateToken lets through only requests with a valid API token
+// holding scope, whose user's role still allows it. The user is put in the
+// request's context for requestUser, as authenticate does.
+func authenticateToken(scope string, next http.Handler) http.Handler{
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        raw, ok := bearerToken(r)
+        if !ok{
+            tokenRejected(w, http.StatusUnauthorized, "", "An API token is required")
+            return
+        }
+
+        token, err := store.GetAPIToken(r.Context(), repository.HashToken(raw))
+        if errors.Is(err, repository.ErrNotFound){
+            tokenRejected(w, http.StatusUnauthorized, ` error="invalid_token"`, "Invalid API token")
+            return
+        }
+        if err != nil{
+            log.Printf("Error fetching API token: %v\n", err)
+            apiError(w, http.StatusInternalServerError, "Internal Server Error")
+            return
+        }
+
+        now := time.Now()
+        if token.Expired(now){
+            tokenRejected(w, http.StatusUnauthorized, ` error="invalid_token"`, "The API token has expired")
+            return
+        }
+
+        user, err := store.GetUser(r.Context(), token.UserId)
+        if errors.Is(err, repository.ErrNotFound){
+            tokenRejected(w, http.StatusUnauthorized, ` error="invalid_token"`, "Invalid API token")
+            return
+        }
+        if err != nil{
+            log.Printf("Error fetching user of API token: %v\n", err)
+            apiError(w, http.StatusInternalServerError, "Internal Server Error")
+            return
+        }
+
+        if !token.HasScope(scope) || !user.CanUseScope(scope){
+            tokenRejected(w, http.StatusForbidden, fmt.Sprintf(` error="insufficient_scope", scope="%s"`, scope),
+                "The API token doesn't have the "+scope+" scope")
+            return
+        }
+        if mustEnrol(r, user){
+            apiError(w, http.StatusForbidden, "Two-factor authentication is required")
+            return
+        }
+        touchAPIToken(r, token, now)
+
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+    })
+}
+
+// touchAPIToken records that the token was used, for its user to see.
+func touchAPIToken(r *http.Request, token repository.APIToken, now time.Time){
+    ip := clientIP(r)
+    if now.Sub(token.LastUsed) < tokenTouchInterval && token.LastIP == ip{
+        return
+    }
+    if err := store.TouchAPIToken(r.Context(), token.Id, now, ip); err != nil{
+        log.Printf("Error recording API token use: %v\n", err)
+    }
+}
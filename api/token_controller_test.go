@@ -0,0 +1,171 @@
+package api
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// testSite points the package at a fresh memory store and default settings.
+func testSite(t *testing.T){
+    t.Helper()
+    settings = config.Default()
+    settings.Site.URL = "https://example.com"
+    settings.Paths.Uploads = t.TempDir()
+    store = repository.NewMemoryStore()
+}
+
+func testUser(t *testing.T, username, role string) repository.User{
+    t.Helper()
+    user, err := store.CreateUser(context.Background(), repository.User{Username: username, Role: role})
+    if err != nil{
+        t.Fatal(err)
+    }
+    return user
+}
+
+// testToken gives user a token with scopes, expiring at expires unless it is
+// zero, and returns it with its id.
+func testToken(t *testing.T, user repository.User, expires time.Time, scopes ...string) (string, repository.APIToken){
+    t.Helper()
+    raw, hash, err := repository.NewAPIToken()
+    if err != nil{
+        t.Fatal(err)
+    }
+    token, err := store.CreateAPIToken(context.Background(), repository.APIToken{
+        UserId: user.Id,
+        Name: "test",
+        TokenHash: hash,
+        Scopes: scopes,
+        Created: time.Now(),
+        Expires: expires,
+    })
+    if err != nil{
+        t.Fatal(err)
+    }
+    return raw, token
+}
+
+func TestAuthenticateToken(t *testing.T){
+    testSite(t)
+    ctx := context.Background()
+
+    author := testUser(t, "author", repository.RoleAuthor)
+    valid, _ := testToken(t, author, time.Time{}, repository.ScopePostsWrite)
+    expired, _ := testToken(t, author, time.Now().Add(-time.Minute), repository.ScopePostsWrite)
+    revoked, token := testToken(t, author, time.Time{}, repository.ScopePostsWrite)
+    if err := store.RevokeAPIToken(ctx, author.Id, token.Id); err != nil{
+        t.Fatal(err)
+    }
+    wrongScope, _ := testToken(t, author, time.Time{}, repository.ScopePortfolioRead)
+
+    // A token made while its user could write posts, who no longer can.
+    demoted := testUser(t, "demoted", repository.RoleAuthor)
+    downgraded, _ := testToken(t, demoted, time.Time{}, repository.ScopePostsWrite)
+    if err := store.SetUserRole(ctx, demoted.Id, repository.RoleViewer); err != nil{
+        t.Fatal(err)
+    }
+
+    var seen repository.User
+    handler := authenticateToken(repository.ScopePostsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        seen = requestUser(r)
+        w.WriteHeader(http.StatusNoContent)
+    }))
+
+    tests := []struct{
+        name          string
+        authorization string
+        want          int
+        challenge     string
+    }{
+        {"valid", "Bearer " + valid, http.StatusNoContent, ""},
+        {"scheme in another case", "bearer " + valid, http.StatusNoContent, ""},
+        {"missing", "", http.StatusUnauthorized, "Bearer"},
+        {"not bearer", "Basic " + valid, http.StatusUnauthorized, "Bearer"},
+        {"unknown", "Bearer " + repository.APITokenPrefix + "nope", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+        {"expired", "Bearer " + expired, http.StatusUnauthorized, `Bearer error="invalid_token"`},
+        {"revoked", "Bearer " + revoked, http.StatusUnauthorized, `Bearer error="invalid_token"`},
+        {"wrong scope", "Bearer " + wrongScope, http.StatusForbidden, `Bearer error="insufficient_scope", scope="posts:write"`},
+        {"downgraded role", "Bearer " + downgraded, http.StatusForbidden, `Bearer error="insufficient_scope", scope="posts:write"`},
+    }
+
+    for _, test := range tests{
+        t.Run(test.name, func(t *testing.T){
+            seen = repository.User{}
+            r := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+            if test.authorization != ""{
+                r.Header.Set("Authorization", test.authorization)
+            }
+
+            w := httptest.NewRecorder()
+            handler.ServeHTTP(w, r)
+            if w.Code != test.want{
+                t.Fatalf("got status %d, want %d: %s", w.Code, test.want, w.Body)
+            }
+            if got := w.Header().Get("WWW-Authenticate"); got != test.challenge{
+                t.Errorf("WWW-Authenticate %q, want %q", got, test.challenge)
+            }
+            if test.want == http.StatusNoContent && seen.Id != author.Id{
+                t.Errorf("handler saw user %q, want the token's", seen.Username)
+            }
+        })
+    }
+}
+
+func TestAuthenticateTokenTwoFactor(t *testing.T){
+    testSite(t)
+    ctx := context.Background()
+
+    author := testUser(t, "author", repository.RoleAuthor)
+    raw, _ := testToken(t, author, time.Time{}, repository.ScopePostsWrite)
+    if err := store.SaveSettings(ctx, repository.SiteSettings{RequireTwoFactor: true}); err != nil{
+        t.Fatal(err)
+    }
+
+    handler := authenticateToken(repository.ScopePostsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    request := func() int{
+        r := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+        r.Header.Set("Authorization", "Bearer "+raw)
+        w := httptest.NewRecorder()
+        handler.ServeHTTP(w, r)
+        return w.Code
+    }
+
+    if got := request(); got != http.StatusForbidden{
+        t.Errorf("user who must enrol got status %d, want %d", got, http.StatusForbidden)
+    }
+
+    if err := store.SetTwoFactor(ctx, author.Id, "JBSWY3DPEHPK3PXP", nil); err != nil{
+        t.Fatal(err)
+    }
+    if got := request(); got != http.StatusNoContent{
+        t.Errorf("enrolled user got status %d, want %d", got, http.StatusNoContent)
+    }
+}
+
+func TestAuthenticateTokenRecordsUse(t *testing.T){
+    testSite(t)
+    ctx := context.Background()
+
+    author := testUser(t, "author", repository.RoleAuthor)
+    raw, token := testToken(t, author, time.Time{}, repository.ScopePostsWrite)
+    handler := authenticateToken(repository.ScopePostsWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){}))
+
+    r := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+    r.Header.Set("Authorization", "Bearer "+raw)
+    handler.ServeHTTP(httptest.NewRecorder(), r)
+
+    tokens, err := store.UserAPITokens(ctx, author.Id)
+    if err != nil || len(tokens) != 1{
+        t.Fatalf("tokens %v, %v", tokens, err)
+    }
+    if tokens[0].Id != token.Id || tokens[0].LastUsed.IsZero() || tokens[0].LastIP == ""{
+        t.Errorf("use wasn't recorded: %+v", tokens[0])
+    }
+}
@@ -0,0 +1,467 @@
+package api
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "html/template"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal"
+    "github.com/vinny-pereira/personal-blog/internal/login"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+    "github.com/vinny-pereira/personal-blog/internal/totp"
+)
+
+// challengeCookie holds the token of a login waiting for its second factor.
+const challengeCookie = "login_challenge"
+
+// challenges are the logins waiting for their second factor, and
+// enrolments the secrets being set up. They are set by HandleAdminEndpoints.
+var (
+    challenges *login.Challenges
+    enrolments *login.Enrolments
+)
+
+// startChallenge asks for the second factor of a user whose password was
+// right. No session is started until it is given.
+func startChallenge(w http.ResponseWriter, r *http.Request, user repository.User){
+    token, err := challenges.Start(user.Id)
+    if err != nil{
+        log.Printf("Error starting login challenge: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    setChallengeCookie(w, r, token, int(login.ChallengeLifetime / time.Second))
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "two-factor-login", nil); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// setChallengeCookie sets the challenge cookie, or forgets it when maxAge is
+// negative.
+func setChallengeCookie(w http.ResponseWriter, r *http.Request, token string, maxAge int){
+    http.SetCookie(w, &http.Cookie{
+        Name:     challengeCookie,
+        Value:    token,
+        Path:     "/",
+        MaxAge:   maxAge,
+        HttpOnly: true,
+        Secure:   secureCookies(),
+        SameSite: http.SameSiteLaxMode,
+    })
+}
+
+// handleLoginVerification finishes a login with a code from the user's
+// authenticator app or one of their recovery codes. Wrong codes count as
+// failed logins, and too many of them send the user back to the password.
+func handleLoginVerification(w http.ResponseWriter, r *http.Request){
+    cookie, err := r.Cookie(challengeCookie)
+    if err != nil{
+        unauthorized(w, r)
+        return
+    }
+    id, ok := challenges.User(cookie.Value)
+    if !ok{
+        setChallengeCookie(w, r, "", -1)
+        unauthorized(w, r)
+        return
+    }
+
+    user, err := store.GetUser(r.Context(), id)
+    if err != nil{
+        log.Printf("Error fetching user for login challenge: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    if wait, locked := logins.Wait(user.Username, clientIP(r)); wait > 0{
+        tooManyLogins(w, wait, locked)
+        return
+    }
+
+    recovery, err := repository.VerifySecondFactor(r.Context(), store, user, r.FormValue("code"), time.Now())
+    if errors.Is(err, repository.ErrInvalidCredentials){
+        loginFailed(r, user.Username)
+        if !challenges.Fail(cookie.Value){
+            setChallengeCookie(w, r, "", -1)
+            unauthorized(w, r)
+            return
+        }
+        http.Error(w, "Invalid code", http.StatusUnauthorized)
+        return
+    }
+    if err != nil{
+        log.Printf("Error verifying second factor: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    challenges.Finish(cookie.Value)
+    setChallengeCookie(w, r, "", -1)
+    logins.Success(user.Username)
+    if recovery{
+        audit(r, repository.AuditEntry{
+            Action: repository.AuditRecoveryCodeUsed,
+            Actor: user.Username,
+            Subject: user.Username,
+            Detail: fmt.Sprintf("%d recovery codes left", len(user.RecoveryCodes) - 1),
+        })
+    }
+
+    if err := startSession(w, r, user.Id); err != nil{
+        log.Printf("Error storing session: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    if isHTMX(r){
+        w.Header().Set("HX-Redirect", "/admin")
+        return
+    }
+    http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// twoFactorRequired tells whether the owner requires two-factor
+// authentication of everyone. It errs on requiring it when the setting can't
+// be read.
+func twoFactorRequired(r *http.Request) bool{
+    site, err := store.GetSettings(r.Context())
+    if err != nil{
+        log.Printf("Error fetching site settings: %v\n", err)
+        return true
+    }
+    return site.RequireTwoFactor
+}
+
+// mustEnrol tells whether the user has to turn on two-factor authentication
+// before doing anything else.
+func mustEnrol(r *http.Request, user repository.User) bool{
+    return !user.HasTwoFactor() && twoFactorRequired(r)
+}
+
+// TwoFactor is the page where users turn two-factor authentication on and
+// off.
+type TwoFactor struct{
+    User          repository.User
+    // Secret and QRCode enrol an authenticator app while it is off.
+    Secret        string
+    QRCode        template.URL
+    // RecoveryCodes were just made. Only their hashes are stored, so this is
+    // the one time they can be shown.
+    RecoveryCodes []string
+    // Required is whether the owner requires it of everyone.
+    Required      bool
+    Error         string
+}
+
+// twoFactorIssuer names the site in authenticator apps, which take
+// everything before a colon for the issuer.
+func twoFactorIssuer() string{
+    issuer := strings.ReplaceAll(settings.Site.Title, ":", "")
+    if issuer == ""{
+        return "personal-blog"
+    }
+    return issuer
+}
+
+// twoFactorPage is the page for user. While two-factor authentication is
+// off it enrols secret, or else a new one kept for the session until it is
+// confirmed.
+func twoFactorPage(r *http.Request, session repository.Session, user repository.User, secret string) (TwoFactor, error){
+    data := TwoFactor{User: user, Required: twoFactorRequired(r)}
+    if user.HasTwoFactor(){
+        return data, nil
+    }
+
+    if secret == ""{
+        var err error
+        secret, err = totp.NewSecret()
+        if err != nil{
+            return data, err
+        }
+        enrolments.Start(session.Id, secret)
+    }
+
+    png, err := totp.QRCode(totp.URL(twoFactorIssuer(), user.Username, secret))
+    if err != nil{
+        return data, err
+    }
+    data.Secret = secret
+    data.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+    return data, nil
+}
+
+func renderTwoFactor(w http.ResponseWriter, data TwoFactor){
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "two-factor", data); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// showTwoFactorSetup is the admin page of users who must turn on two-factor
+// authentication before anything else.
+func showTwoFactorSetup(w http.ResponseWriter, r *http.Request, session repository.Session, user repository.User){
+    twoFactor, err := twoFactorPage(r, session, user, "")
+    if err != nil{
+        log.Printf("Error preparing two-factor setup: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+
+    tmpl := templates.Get()
+    content, err := internal.RenderTemplate(tmpl, "two-factor", twoFactor)
+    if err != nil {
+        log.Printf("Error rendering two-factor template: %v\n", err)
+        http.Error(w, "Error rendering template.", http.StatusInternalServerError)
+        return
+    }
+    data := repository.PageData{
+        Content: template.HTML(content),
+        Version: assets.Version,
+        CSRF: csrfToken(r),
+        Meta: adminMeta,
+        User: user,
+    }
+    if err := tmpl.ExecuteTemplate(w, "admin", data); err != nil {
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+// showTwoFactor renders the page for user, with a new secret to enrol while
+// two-factor authentication is off.
+func showTwoFactor(w http.ResponseWriter, r *http.Request, user repository.User){
+    data, err := twoFactorPage(r, requestSession(r), user, "")
+    if err != nil{
+        log.Printf("Error preparing two-factor setup: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    renderTwoFactor(w, data)
+}
+
+func handleTwoFactor(w http.ResponseWriter, r *http.Request){
+    showTwoFactor(w, r, requestUser(r))
+}
+
+// handleTwoFactorSetup turns on two-factor authentication once the user
+// proves their app has the secret shown to their session by entering a code
+// from it.
+func handleTwoFactorSetup(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    session := requestSession(r)
+    if user.HasTwoFactor(){
+        http.Error(w, "Two-factor authentication is already on", http.StatusConflict)
+        return
+    }
+
+    secret, ok := enrolments.Secret(session.Id)
+    if !ok{
+        data, err := twoFactorPage(r, session, user, "")
+        if err != nil{
+            log.Printf("Error preparing two-factor setup: %v\n", err)
+            http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+            return
+        }
+        data.Error = "That setup expired. Scan this new code and enter the one your app shows."
+        renderTwoFactor(w, data)
+        return
+    }
+
+    step, ok := totp.Validate(secret, strings.TrimSpace(r.FormValue("code")), time.Now())
+    if !ok{
+        data, err := twoFactorPage(r, session, user, secret)
+        if err != nil{
+            log.Printf("Error preparing two-factor setup: %v\n", err)
+            http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+            return
+        }
+        data.Error = "That code is wrong. Check the time on your device and try the next one."
+        renderTwoFactor(w, data)
+        return
+    }
+
+    codes, hashes, err := repository.NewRecoveryCodes()
+    if err != nil{
+        log.Printf("Error making recovery codes: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    if err := store.SetTwoFactor(r.Context(), user.Id, secret, hashes); err != nil{
+        log.Printf("Error turning on two-factor authentication: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    enrolments.Finish(session.Id)
+    if err := store.UseTOTPStep(r.Context(), user.Id, step); err != nil{
+        log.Printf("Error recording TOTP step: %v\n", err)
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditTwoFactorOn,
+        Actor: user.Username,
+        Subject: user.Username,
+    })
+
+    user.TOTPSecret = secret
+    user.RecoveryCodes = hashes
+    renderTwoFactor(w, TwoFactor{User: user, RecoveryCodes: codes, Required: twoFactorRequired(r)})
+}
+
+// verifyOwnCode checks the code users confirm changes to their two-factor
+// authentication with. When it is wrong, the page is shown again saying so.
+func verifyOwnCode(w http.ResponseWriter, r *http.Request, user repository.User) bool{
+    _, err := repository.VerifySecondFactor(r.Context(), store, user, r.FormValue("code"), time.Now())
+    if errors.Is(err, repository.ErrInvalidCredentials){
+        renderTwoFactor(w, TwoFactor{User: user, Required: twoFactorRequired(r), Error: "That code is wrong."})
+        return false
+    }
+    if err != nil{
+        log.Printf("Error verifying second factor: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return false
+    }
+    return true
+}
+
+// handleTwoFactorDisable turns off two-factor authentication, unless the
+// owner requires it.
+func handleTwoFactorDisable(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    if !user.HasTwoFactor(){
+        http.Error(w, "Two-factor authentication is already off", http.StatusConflict)
+        return
+    }
+    if twoFactorRequired(r){
+        renderTwoFactor(w, TwoFactor{User: user, Required: true, Error: "Two-factor authentication is required on this site."})
+        return
+    }
+    if !verifyOwnCode(w, r, user){
+        return
+    }
+
+    if err := store.SetTwoFactor(r.Context(), user.Id, "", nil); err != nil{
+        log.Printf("Error turning off two-factor authentication: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditTwoFactorOff,
+        Actor: user.Username,
+        Subject: user.Username,
+    })
+
+    user.TOTPSecret = ""
+    user.RecoveryCodes = nil
+    showTwoFactor(w, r, user)
+}
+
+// handleRecoveryCodes replaces the user's recovery codes with new ones.
+func handleRecoveryCodes(w http.ResponseWriter, r *http.Request){
+    user := requestUser(r)
+    if !user.HasTwoFactor(){
+        http.Error(w, "Two-factor authentication is off", http.StatusConflict)
+        return
+    }
+    if !verifyOwnCode(w, r, user){
+        return
+    }
+
+    codes, hashes, err := repository.NewRecoveryCodes()
+    if err != nil{
+        log.Printf("Error making recovery codes: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    if err := store.SetRecoveryCodes(r.Context(), user.Id, hashes); err != nil{
+        log.Printf("Error storing recovery codes: %v\n", err)
+        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditRecoveryCodesNew,
+        Actor: user.Username,
+        Subject: user.Username,
+    })
+
+    user.RecoveryCodes = hashes
+    renderTwoFactor(w, TwoFactor{User: user, RecoveryCodes: codes, Required: twoFactorRequired(r)})
+}
+
+// handleTwoFactorReset turns off two-factor authentication for another user
+// who lost their app and recovery codes, so they can sign in with their
+// password and set it up again.
+func handleTwoFactorReset(w http.ResponseWriter, r *http.Request){
+    me := requestUser(r)
+
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid user id", http.StatusBadRequest)
+        return
+    }
+    if id == me.Id{
+        http.Error(w, "Turn off your own two-factor authentication from its page", http.StatusBadRequest)
+        return
+    }
+
+    user, err := store.GetUser(r.Context(), id)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err == nil{
+        err = store.SetTwoFactor(r.Context(), id, "", nil)
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error resetting two-factor authentication", http.StatusInternalServerError)
+        return
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditTwoFactorOff,
+        Actor: me.Username,
+        Subject: user.Username,
+        Detail: "reset by owner",
+    })
+
+    renderUsers(w, r, me, "")
+}
+
+// handleRequireTwoFactor turns requiring two-factor authentication of every
+// user on or off.
+func handleRequireTwoFactor(w http.ResponseWriter, r *http.Request){
+    site, err := store.GetSettings(r.Context())
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch settings", http.StatusInternalServerError)
+        return
+    }
+
+    site.RequireTwoFactor = r.FormValue("require") == "on"
+    if err := store.SaveSettings(r.Context(), site); err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't save settings", http.StatusInternalServerError)
+        return
+    }
+
+    detail := "off"
+    if site.RequireTwoFactor{
+        detail = "on"
+    }
+    audit(r, repository.AuditEntry{
+        Action: repository.AuditRequireTwoFactor,
+        Actor: requestUser(r).Username,
+        Detail: detail,
+    })
+
+    renderSecurity(w, r)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+// pngHeader is enough of a PNG for its type to be told from its content.
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func TestSaveUpload(t *testing.T){
+    settings = config.Default()
+    settings.Paths.Uploads = t.TempDir()
+
+    tests := []struct{
+        name    string
+        content string
+        ext     string
+        err     error
+    }{
+        {"png", pngHeader, ".png", nil},
+        {"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF\x00", ".jpg", nil},
+        {"gif", "GIF89a\x01\x00\x01\x00", ".gif", nil},
+        {"webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", ".webp", nil},
+        {"html", "<!DOCTYPE html><script>alert(1)</script>", "", errUnsupportedUpload},
+        {"svg", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, "", errUnsupportedUpload},
+        {"bare svg", `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`, "", errUnsupportedUpload},
+        {"javascript", "alert(document.cookie)", "", errUnsupportedUpload},
+        {"empty", "", "", errUnsupportedUpload},
+    }
+
+    for _, test := range tests{
+        t.Run(test.name, func(t *testing.T){
+            name, err := saveUpload(strings.NewReader(test.content))
+            if !errors.Is(err, test.err){
+                t.Fatalf("got error %v, want %v", err, test.err)
+            }
+            if err != nil{
+                return
+            }
+            if filepath.Ext(name) != test.ext{
+                t.Errorf("saved as %s, want a %s file", name, test.ext)
+            }
+            saved, err := os.ReadFile(filepath.Join(settings.Paths.Uploads, name))
+            if err != nil || string(saved) != test.content{
+                t.Errorf("saved %q, %v, want the whole upload", saved, err)
+            }
+        })
+    }
+
+    entries, _ := os.ReadDir(settings.Paths.Uploads)
+    if len(entries) != 4{
+        t.Errorf("%d files saved, want only the 4 images", len(entries))
+    }
+}
+
+func TestUploadsHeaders(t *testing.T){
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "old.html"), []byte("<script>alert(1)</script>"), 0o644)
+    os.WriteFile(filepath.Join(dir, "cover.png"), []byte(pngHeader), 0o644)
+    handler := Uploads(dir)
+
+    tests := []struct{
+        path       string
+        attachment bool
+    }{
+        {"/uploads/old.html", true},
+        {"/uploads/cover.png", false},
+    }
+    for _, test := range tests{
+        w := httptest.NewRecorder()
+        handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+        if w.Code != http.StatusOK{
+            t.Fatalf("%s: status %d", test.path, w.Code)
+        }
+        if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff"{
+            t.Errorf("%s: X-Content-Type-Options %q", test.path, got)
+        }
+        if got := w.Header().Get("Content-Security-Policy"); !strings.Contains(got, "sandbox"){
+            t.Errorf("%s: Content-Security-Policy %q doesn't sandbox", test.path, got)
+        }
+        if got := w.Header().Get("Content-Disposition") == "attachment"; got != test.attachment{
+            t.Errorf("%s: sent as an attachment %v, want %v", test.path, got, test.attachment)
+        }
+    }
+}
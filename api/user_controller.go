@@ -0,0 +1,168 @@
+package api
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// inviteDays are how long a new invite may be given to be used.
+var inviteDays = []int{1, 3, 7}
+
+// UserManagement is the owner's page for users, their roles and invites.
+type UserManagement struct{
+    Me      repository.User
+    Users   []repository.User
+    Invites []repository.Invite
+    // Link is the invite just made. Only its hash is stored, so this is the
+    // one time it can be shown.
+    Link    string
+    Now     time.Time
+}
+
+func (m UserManagement) Roles() []string{
+    return repository.Roles
+}
+
+func (m UserManagement) Days() []int{
+    return inviteDays
+}
+
+func renderUsers(w http.ResponseWriter, r *http.Request, me repository.User, link string){
+    users, err := store.ListUsers(r.Context())
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch users", http.StatusInternalServerError)
+        return
+    }
+
+    invites, err := store.Invites(r.Context())
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Couldn't fetch invites", http.StatusInternalServerError)
+        return
+    }
+
+    data := UserManagement{
+        Me: me,
+        Users: users,
+        Invites: invites,
+        Link: link,
+        Now: time.Now(),
+    }
+
+    tmpl := templates.Get()
+
+    if err := tmpl.ExecuteTemplate(w, "users", data); err != nil{
+        log.Println(err)
+        http.Error(w, "Error executing template.", http.StatusInternalServerError)
+    }
+}
+
+func handleUserManagement(w http.ResponseWriter, r *http.Request){
+    renderUsers(w, r, requestUser(r), "")
+}
+
+// handleInviteCreation makes an invite and shows its link to the owner, who
+// passes it on to whoever should register with it.
+func handleInviteCreation(w http.ResponseWriter, r *http.Request){
+    me := requestUser(r)
+
+    role := r.FormValue("role")
+    if !repository.IsRole(role){
+        http.Error(w, "Invalid role", http.StatusBadRequest)
+        return
+    }
+
+    days, err := strconv.Atoi(r.FormValue("days"))
+    if err != nil || days < 1 || days > inviteDays[len(inviteDays)-1]{
+        http.Error(w, "Invalid expiry", http.StatusBadRequest)
+        return
+    }
+
+    token, hash, err := repository.NewToken()
+    if err != nil{
+        log.Printf("Error making invite token: %v\n", err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+
+    now := time.Now()
+    _, err = store.CreateInvite(r.Context(), repository.Invite{
+        TokenHash: hash,
+        Role: role,
+        CreatedBy: me.Id,
+        Created: now,
+        Expires: now.AddDate(0, 0, days),
+    })
+    if err != nil{
+        log.Printf("Error storing invite: %v\n", err)
+        http.Error(w, "Internal server error", http.StatusInternalServerError)
+        return
+    }
+
+    renderUsers(w, r, me, siteURL() + "/register?invite=" + url.QueryEscape(token))
+}
+
+func handleInviteDeletion(w http.ResponseWriter, r *http.Request){
+    me := requestUser(r)
+
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid invite id", http.StatusBadRequest)
+        return
+    }
+
+    err = store.DeleteInvite(r.Context(), id)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error deleting invite", http.StatusInternalServerError)
+        return
+    }
+
+    renderUsers(w, r, me, "")
+}
+
+// handleRoleChange gives a user another role. Owners can't change their own,
+// so the site always keeps at least one.
+func handleRoleChange(w http.ResponseWriter, r *http.Request){
+    me := requestUser(r)
+
+    id, err := primitive.ObjectIDFromHex(r.URL.Query().Get("id"))
+    if err != nil{
+        http.Error(w, "Invalid user id", http.StatusBadRequest)
+        return
+    }
+    if id == me.Id{
+        http.Error(w, "You can't change your own role", http.StatusBadRequest)
+        return
+    }
+
+    role := r.FormValue("role")
+    if !repository.IsRole(role){
+        http.Error(w, "Invalid role", http.StatusBadRequest)
+        return
+    }
+
+    err = store.SetUserRole(r.Context(), id, role)
+    if errors.Is(err, repository.ErrNotFound){
+        http.NotFound(w, r)
+        return
+    }
+    if err != nil{
+        log.Println(err)
+        http.Error(w, "Error changing role", http.StatusInternalServerError)
+        return
+    }
+
+    renderUsers(w, r, me, "")
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "log"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// secret signs visitor cookies and salts hashed addresses. It is set by
+// HandleEndpoints.
+var secret []byte
+
+// initSecret takes the configured secret or makes up a random one.
+func initSecret(configured string){
+    if configured != ""{
+        secret = []byte(configured)
+        return
+    }
+
+    secret = make([]byte, 32)
+    if _, err := rand.Read(secret); err != nil{
+        log.Fatalf("Could not generate a secret: %v\n", err)
+    }
+    log.Println("No secret configured, visitors will be forgotten on restart")
+}
+
+// sign is the MAC of value under the secret.
+func sign(value string) string{
+    mac := hmac.New(sha256.New, secret)
+    mac.Write([]byte(value))
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+const (
+    visitorCookie   = "visitor"
+    visitorLifetime = 365 * 24 * time.Hour
+)
+
+// visitorFrom is the id in the request's visitor cookie, or empty when it
+// has none or it wasn't signed by us.
+func visitorFrom(r *http.Request) string{
+    cookie, err := r.Cookie(visitorCookie)
+    if err != nil{
+        return ""
+    }
+
+    id, signature, ok := strings.Cut(cookie.Value, ".")
+    if !ok || !hmac.Equal([]byte(signature), []byte(sign(id))){
+        return ""
+    }
+    return id
+}
+
+// ensureVisitor returns the visitor id of the request, handing out a new
+// anonymous one if it has none. Nothing about the visitor is kept but the
+// things they react to.
+func ensureVisitor(w http.ResponseWriter, r *http.Request) string{
+    if id := visitorFrom(r); id != ""{
+        return id
+    }
+
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil{
+        log.Printf("Error generating visitor id: %v\n", err)
+        return ""
+    }
+    id := hex.EncodeToString(b)
+
+    http.SetCookie(w, &http.Cookie{
+        Name: visitorCookie,
+        Value: id + "." + sign(id),
+        Path: "/",
+        Expires: time.Now().Add(visitorLifetime),
+        HttpOnly: true,
+        Secure: secureCookies(),
+        SameSite: http.SameSiteLaxMode,
+    })
+    return id
+}
+
+// hashIP salts and hashes the request's address so reactions can be told
+// apart by it without storing it.
+func hashIP(r *http.Request) string{
+    return sign("ip:" + clientIP(r))
+}
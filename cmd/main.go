@@ -1,21 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"time"
 	"github.com/vinny-pereira/personal-blog/api"
+	"github.com/vinny-pereira/personal-blog/internal"
+	"github.com/vinny-pereira/personal-blog/internal/config"
+	"github.com/vinny-pereira/personal-blog/internal/mail"
 	"github.com/vinny-pereira/personal-blog/internal/repository"
+	"github.com/vinny-pereira/personal-blog/internal/search"
+	"github.com/vinny-pereira/personal-blog/internal/spam"
+	"github.com/vinny-pereira/personal-blog/web"
 )
 
 
 func main() {
-    repository.ConnectMongoDB()
-    api.HandleEndpoints()
-	fs := http.FileServer(http.Dir("./web/wwwroot/uploads"))
-	http.Handle("/uploads/", http.StripPrefix("/uploads/", fs))
-    api.HandleAdminEndpoints()
-	log.Println("Server started at :8880")
-	if err := http.ListenAndServe("[::]:8880", nil); err != nil {
+    cfg, err := config.Load(os.Args[1:])
+    if errors.Is(err, flag.ErrHelp) {
+        return
+    }
+    if err != nil {
+        log.Fatalf("Invalid configuration:\n%v\n", err)
+    }
+
+    store, err := repository.Open(context.Background(), cfg)
+    if err != nil {
+        log.Fatalf("Could not open %s store: %v\n", cfg.Store, err)
+    }
+    defer store.Close(context.Background())
+
+    index := search.NewIndex()
+    if err := index.Build(context.Background(), store); err != nil {
+        log.Fatalf("Could not build the search index: %v\n", err)
+    }
+    store = search.NewStore(store, index)
+
+    classifier := spam.NewClassifier()
+    if err := classifier.Build(context.Background(), store); err != nil {
+        log.Fatalf("Could not train the spam classifier: %v\n", err)
+    }
+    store = spam.NewStore(store, classifier)
+
+    templateFS, err := web.Templates(cfg.Paths.Templates)
+    if err != nil {
+        log.Fatalf("Could not open templates: %v\n", err)
+    }
+
+    templates, err := internal.LoadTemplates(templateFS)
+    if err != nil {
+        log.Fatalf("Could not load templates: %v\n", err)
+    }
+
+    if cfg.Dev {
+        if cfg.Paths.Templates == "" {
+            log.Println("Dev mode: templates are embedded, set -templates-dir to reload them from disk")
+        } else {
+            log.Printf("Dev mode: watching %s for template changes\n", cfg.Paths.Templates)
+            go templates.Watch(context.Background(), 500*time.Millisecond)
+        }
+    }
+
+    staticFS, err := web.Static(cfg.Paths.Static)
+    if err != nil {
+        log.Fatalf("Could not open static assets: %v\n", err)
+    }
+
+    assets, err := internal.NewAssets(staticFS, cfg.Paths.Static == "")
+    if err != nil {
+        log.Fatalf("Could not read static assets: %v\n", err)
+    }
+
+    go internal.RunScheduler(context.Background(), store)
+    go internal.RunSessionSweeper(context.Background(), store)
+
+    api.HandleEndpoints(cfg, store, templates, assets, index, spam.NewFilter(cfg.Spam, classifier))
+	http.Handle("/uploads/", api.Uploads(cfg.Paths.Uploads))
+    api.HandleAdminEndpoints(mail.New(cfg.Mail))
+	log.Printf("Server started at %s\n", cfg.Server.Addr)
+	if err := http.ListenAndServe(cfg.Server.Addr, api.Handler()); err != nil {
 		log.Fatalf("Could not start server: %s\n", err)
 	}
 }
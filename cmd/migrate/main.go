@@ -0,0 +1,38 @@
+// Command migrate copies an existing MongoDB blog database into a SQLite file
+// that can then be served with -store sqlite.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+func main() {
+    mongoURI := flag.String("mongo-uri", "mongodb://localhost:27017", "MongoDB connection string to copy from")
+    mongoDatabase := flag.String("mongo-database", "blog", "MongoDB database to copy from")
+    sqlitePath := flag.String("sqlite-path", "./blog.db", "SQLite file to copy into; created if missing")
+    flag.Parse()
+
+    ctx := context.Background()
+
+    src, err := repository.NewMongoStore(ctx, *mongoURI, *mongoDatabase)
+    if err != nil {
+        log.Fatalf("Could not connect to MongoDB: %v\n", err)
+    }
+    defer src.Close(ctx)
+
+    dst, err := repository.NewSQLiteStore(ctx, *sqlitePath)
+    if err != nil {
+        log.Fatalf("Could not open %s: %v\n", *sqlitePath, err)
+    }
+    defer dst.Close(ctx)
+
+    stats, err := repository.CopyMongoToSQLite(ctx, src, dst)
+    if err != nil {
+        log.Fatalf("Migration failed after copying %s: %v\n", stats, err)
+    }
+
+    log.Printf("Copied %s into %s\n", stats, *sqlitePath)
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+    "html/template"
+    "regexp"
+    "strings"
+)
+
+var (
+    paragraphBreak = regexp.MustCompile(`\n[ \t]*\n`)
+    codeSpan       = regexp.MustCompile("`([^`\n]+)`")
+    commentLink    = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^\s()<>"]+)\)|https?://[^\s()<>"]+`)
+    strong         = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+    emphasis       = regexp.MustCompile(`\*([^*\n]+)\*`)
+)
+
+// CommentToHtml renders the small subset of markdown comments may use:
+// paragraphs, line breaks, **bold**, *italic*, `code` and links to http(s)
+// URLs. Everything else is escaped, so a comment can't add markup of its own.
+func CommentToHtml(body string) template.HTML {
+    body = strings.ReplaceAll(body, "\r\n", "\n")
+
+    var b strings.Builder
+    for _, paragraph := range paragraphBreak.Split(body, -1) {
+        paragraph = strings.TrimSpace(paragraph)
+        if paragraph == "" {
+            continue
+        }
+
+        b.WriteString("<p>")
+        for i, line := range strings.Split(paragraph, "\n") {
+            if i > 0 {
+                b.WriteString("<br>")
+            }
+            writeCodeSpans(&b, line)
+        }
+        b.WriteString("</p>")
+    }
+
+    return template.HTML(b.String())
+}
+
+// writeCodeSpans writes code spans verbatim and the text around them with
+// links and emphasis.
+func writeCodeSpans(b *strings.Builder, text string) {
+    pos := 0
+    for _, m := range codeSpan.FindAllStringSubmatchIndex(text, -1) {
+        writeLinks(b, text[pos:m[0]])
+        b.WriteString("<code>")
+        b.WriteString(template.HTMLEscapeString(text[m[2]:m[3]]))
+        b.WriteString("</code>")
+        pos = m[1]
+    }
+    writeLinks(b, text[pos:])
+}
+
+// writeLinks turns [text](url) and bare URLs into links that search engines
+// don't follow.
+func writeLinks(b *strings.Builder, text string) {
+    pos := 0
+    for _, m := range commentLink.FindAllStringSubmatchIndex(text, -1) {
+        writeEmphasis(b, text[pos:m[0]])
+
+        href, label := text[m[0]:m[1]], text[m[0]:m[1]]
+        if m[2] >= 0 {
+            label, href = text[m[2]:m[3]], text[m[4]:m[5]]
+        }
+
+        b.WriteString(`<a href="`)
+        b.WriteString(template.HTMLEscapeString(href))
+        b.WriteString(`" rel="nofollow ugc noopener" target="_blank">`)
+        writeEmphasis(b, label)
+        b.WriteString("</a>")
+        pos = m[1]
+    }
+    writeEmphasis(b, text[pos:])
+}
+
+func writeEmphasis(b *strings.Builder, text string) {
+    escaped := template.HTMLEscapeString(text)
+    escaped = strong.ReplaceAllString(escaped, "<strong>$1</strong>")
+    escaped = emphasis.ReplaceAllString(escaped, "<em>$1</em>")
+    b.WriteString(escaped)
+}
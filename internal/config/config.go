@@ -0,0 +1,696 @@
+package config
+
+import (
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "net"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const envPrefix = "BLOG_"
+
+const (
+    StoreMongo  = "mongo"
+    StoreSQLite = "sqlite"
+    StoreMemory = "memory"
+)
+
+type Config struct {
+    Server    ServerConfig    `json:"server"`
+    Site      SiteConfig      `json:"site"`
+    Robots    RobotsConfig    `json:"robots"`
+    // Store selects the storage backend: "mongo", "sqlite" or "memory".
+    Store     string          `json:"store"`
+    Mongo     MongoConfig     `json:"mongo"`
+    SQLite    SQLiteConfig    `json:"sqlite"`
+    Paths     PathsConfig     `json:"paths"`
+    Session   SessionConfig   `json:"session"`
+    Login     LoginConfig     `json:"login"`
+    Password  PasswordConfig  `json:"password"`
+    Mail      MailConfig      `json:"mail"`
+    Features  Features        `json:"features"`
+    Spam      SpamConfig      `json:"spam"`
+    Reactions ReactionsConfig `json:"reactions"`
+    // Secret signs the cookies handed to anonymous visitors. When empty a
+    // random one is made at startup and visitors are forgotten on restart.
+    Secret    string          `json:"secret"`
+    // Dev turns on conveniences for working on the site itself, such as
+    // reloading templates when they change on disk.
+    Dev       bool            `json:"dev"`
+}
+
+type ServerConfig struct {
+    Addr string `json:"addr"`
+}
+
+// SiteConfig describes the site to the outside world, in feeds and the like.
+type SiteConfig struct {
+    // URL is where the site is publicly reachable, e.g. https://example.com.
+    // Every absolute link is made from it; when empty feeds and the sitemap
+    // are off and other links are relative.
+    URL         string   `json:"url"`
+    Title       string   `json:"title"`
+    Description string   `json:"description"`
+    Author      string   `json:"author"`
+    // Links are the author's profiles elsewhere, e.g. on GitHub.
+    Links       []string `json:"links"`
+}
+
+// RobotsConfig adds to what robots.txt keeps crawlers out of. The admin pages
+// and the fragments htmx swaps into pages are always excluded.
+type RobotsConfig struct {
+    // Disallow lists more path prefixes crawlers should stay away from.
+    Disallow []string `json:"disallow"`
+}
+
+type MongoConfig struct {
+    URI      string `json:"uri"`
+    Database string `json:"database"`
+}
+
+type SQLiteConfig struct {
+    Path string `json:"path"`
+}
+
+// PathsConfig locates files on disk. Templates and Static are embedded in the
+// binary and only read from disk when set, e.g. while working on a theme.
+type PathsConfig struct {
+    Uploads   string `json:"uploads"`
+    Templates string `json:"templates"`
+    Static    string `json:"static"`
+}
+
+type SessionConfig struct {
+    Lifetime      Duration `json:"lifetime"`
+    // SecureCookies only lets cookies travel over HTTPS, as they do anyway
+    // when site.url is an https URL. One or the other is required outside
+    // dev mode.
+    SecureCookies bool     `json:"secure_cookies"`
+}
+
+// LoginConfig slows down password guessing on the admin login. Failures
+// are counted per username and per address; after FreeAttempts of them in a
+// row each attempt must wait twice as long as the one before, starting from
+// BaseDelay and up to MaxDelay, and once there are too many the username or
+// address is locked out for LockoutDuration.
+type LoginConfig struct {
+    FreeAttempts    int      `json:"free_attempts"`
+    BaseDelay       Duration `json:"base_delay"`
+    MaxDelay        Duration `json:"max_delay"`
+    // UserLockout and IPLockout are how many failures lock out a username
+    // and an address. Addresses get more, being shared by many people.
+    UserLockout     int      `json:"user_lockout"`
+    IPLockout       int      `json:"ip_lockout"`
+    LockoutDuration Duration `json:"lockout_duration"`
+}
+
+// PasswordConfig is the policy admin passwords are held to.
+type PasswordConfig struct {
+    // MinLength is how many characters passwords need at least.
+    MinLength     int      `json:"min_length"`
+    // Cost is the bcrypt cost passwords are hashed with. Passwords hashed
+    // with a lower one are rehashed the next time their user logs in.
+    Cost          int      `json:"cost"`
+    // ResetLifetime is how long a password reset link works.
+    ResetLifetime Duration `json:"reset_lifetime"`
+}
+
+// MailConfig is how the site sends email, such as password reset links.
+// Without an SMTP server emails are written to the log instead.
+type MailConfig struct {
+    From string     `json:"from"`
+    SMTP SMTPConfig `json:"smtp"`
+}
+
+type SMTPConfig struct {
+    // Addr is the server's host:port, e.g. localhost:1025 for a local
+    // stand-in such as Mailpit. Empty means no server.
+    Addr     string `json:"addr"`
+    Username string `json:"username"`
+    Password string `json:"password"`
+}
+
+// Features holds the switches that turn optional parts of the site on or off.
+type Features struct {
+    // Registration opens /register to everyone, who join as viewers. Invited
+    // users and the very first user can always register.
+    Registration bool `json:"registration"`
+}
+
+// SpamConfig tunes the checks comments go through before they are stored.
+type SpamConfig struct {
+    // MinDelay is the least time a person takes to fill in a form.
+    MinDelay   Duration `json:"min_delay"`
+    // RateLimit is how many comments one IP may send per RateWindow.
+    RateLimit  int      `json:"rate_limit"`
+    RateWindow Duration `json:"rate_window"`
+    // MaxLinks is how many links make a comment surely spam.
+    MaxLinks   int      `json:"max_links"`
+    // Threshold is the score from which comments skip the moderation queue
+    // and are filed as spam.
+    Threshold  float64  `json:"threshold"`
+}
+
+type ReactionsConfig struct {
+    // Emoji are the reactions offered on every post, in order.
+    Emoji  []string `json:"emoji"`
+    // Window is how long a reaction keeps the same reaction to the same post
+    // from the same address from counting, whatever cookie it comes with.
+    Window Duration `json:"window"`
+}
+
+// Duration is a time.Duration that reads from JSON as a string like "24h".
+type Duration struct {
+    time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+    var s string
+    if err := json.Unmarshal(b, &s); err != nil {
+        return fmt.Errorf("duration must be a string like \"24h\": %w", err)
+    }
+
+    parsed, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+
+    d.Duration = parsed
+    return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+    return json.Marshal(d.String())
+}
+
+func Default() *Config {
+    return &Config{
+        Server: ServerConfig{
+            Addr: "[::]:8880",
+        },
+        Site: SiteConfig{
+            Title:       "Vinny Pereira",
+            Description: "Posts and projects by Vinny Pereira",
+            Author:      "Vinny Pereira",
+            Links: []string{
+                "https://github.com/vinny-pereira",
+                "https://www.linkedin.com/in/vinny-pereira/",
+                "https://x.com/_vinny_dev",
+            },
+        },
+        Store: StoreMongo,
+        Mongo: MongoConfig{
+            URI:      "mongodb://localhost:27017",
+            Database: "blog",
+        },
+        SQLite: SQLiteConfig{
+            Path: "./blog.db",
+        },
+        Paths: PathsConfig{
+            Uploads: "./web/wwwroot/uploads",
+        },
+        Session: SessionConfig{
+            Lifetime: Duration{24 * time.Hour},
+        },
+        Login: LoginConfig{
+            FreeAttempts:    3,
+            BaseDelay:       Duration{time.Second},
+            MaxDelay:        Duration{time.Minute},
+            UserLockout:     10,
+            IPLockout:       50,
+            LockoutDuration: Duration{15 * time.Minute},
+        },
+        Password: PasswordConfig{
+            MinLength:     12,
+            Cost:          12,
+            ResetLifetime: Duration{time.Hour},
+        },
+        Features: Features{
+            Registration: false,
+        },
+        Spam: SpamConfig{
+            MinDelay:   Duration{3 * time.Second},
+            RateLimit:  5,
+            RateWindow: Duration{10 * time.Minute},
+            MaxLinks:   4,
+            Threshold:  0.9,
+        },
+        Reactions: ReactionsConfig{
+            Emoji:  []string{"\U0001F44D", "\U0001F389", "\U0001F914", "\u2764\ufe0f"},
+            Window: Duration{24 * time.Hour},
+        },
+    }
+}
+
+// setting binds one configuration value to a command line flag and to an
+// environment variable named after it (addr -> BLOG_ADDR).
+type setting struct {
+    name   string
+    usage  string
+    set    func(c *Config, value string) error
+    isBool bool
+}
+
+func (s setting) env() string {
+    return envPrefix + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+func stringSetting(name, usage string, field func(c *Config) *string) setting {
+    return setting{name: name, usage: usage, set: func(c *Config, v string) error {
+        *field(c) = v
+        return nil
+    }}
+}
+
+func durationSetting(name, usage string, field func(c *Config) *Duration) setting {
+    return setting{name: name, usage: usage, set: func(c *Config, v string) error {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            return err
+        }
+        field(c).Duration = d
+        return nil
+    }}
+}
+
+// listSetting takes a comma separated list.
+func listSetting(name, usage string, field func(c *Config) *[]string) setting {
+    return setting{name: name, usage: usage, set: func(c *Config, v string) error {
+        var list []string
+        for _, item := range strings.Split(v, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                list = append(list, item)
+            }
+        }
+        *field(c) = list
+        return nil
+    }}
+}
+
+// boolSetting may be given as a bare flag, so -dev means -dev=true.
+func boolSetting(name, usage string, field func(c *Config) *bool) setting {
+    return setting{name: name, usage: usage, isBool: true, set: func(c *Config, v string) error {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            return err
+        }
+        *field(c) = b
+        return nil
+    }}
+}
+
+// flagValue records a flag so it can be applied after the file and env.
+type flagValue struct {
+    name   string
+    flags  map[string]string
+    isBool bool
+}
+
+func (f *flagValue) String() string   { return "" }
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
+
+func (f *flagValue) Set(v string) error {
+    f.flags[f.name] = v
+    return nil
+}
+
+var settings = []setting{
+    stringSetting("addr", "address the HTTP server listens on", func(c *Config) *string {
+        return &c.Server.Addr
+    }),
+    stringSetting("site-url", "public URL of the site, e.g. https://example.com", func(c *Config) *string {
+        return &c.Site.URL
+    }),
+    stringSetting("site-title", "name of the site in feeds", func(c *Config) *string {
+        return &c.Site.Title
+    }),
+    stringSetting("store", "storage backend: mongo, sqlite or memory", func(c *Config) *string {
+        return &c.Store
+    }),
+    stringSetting("mongo-uri", "MongoDB connection string", func(c *Config) *string {
+        return &c.Mongo.URI
+    }),
+    stringSetting("mongo-database", "MongoDB database name", func(c *Config) *string {
+        return &c.Mongo.Database
+    }),
+    stringSetting("sqlite-path", "SQLite database file", func(c *Config) *string {
+        return &c.SQLite.Path
+    }),
+    stringSetting("upload-dir", "directory where uploaded files are stored", func(c *Config) *string {
+        return &c.Paths.Uploads
+    }),
+    stringSetting("templates-dir", "read HTML templates from this directory instead of the embedded ones", func(c *Config) *string {
+        return &c.Paths.Templates
+    }),
+    stringSetting("static-dir", "serve /dist/ from this directory instead of the embedded assets", func(c *Config) *string {
+        return &c.Paths.Static
+    }),
+    durationSetting("session-lifetime", "how long an admin session stays valid without being used", func(c *Config) *Duration {
+        return &c.Session.Lifetime
+    }),
+    boolSetting("secure-cookies", "only send cookies over HTTPS, for when site-url isn't set", func(c *Config) *bool {
+        return &c.Session.SecureCookies
+    }),
+    durationSetting("login-lockout", "how long too many failed logins lock out a username or address", func(c *Config) *Duration {
+        return &c.Login.LockoutDuration
+    }),
+    stringSetting("smtp-addr", "host:port of the SMTP server email is sent through, logged when empty", func(c *Config) *string {
+        return &c.Mail.SMTP.Addr
+    }),
+    stringSetting("smtp-username", "SMTP username", func(c *Config) *string {
+        return &c.Mail.SMTP.Username
+    }),
+    stringSetting("smtp-password", "SMTP password", func(c *Config) *string {
+        return &c.Mail.SMTP.Password
+    }),
+    stringSetting("mail-from", "address email is sent from", func(c *Config) *string {
+        return &c.Mail.From
+    }),
+    listSetting("robots-disallow", "comma separated paths robots.txt also excludes", func(c *Config) *[]string {
+        return &c.Robots.Disallow
+    }),
+    listSetting("reactions", "comma separated emoji readers can react to posts with", func(c *Config) *[]string {
+        return &c.Reactions.Emoji
+    }),
+    durationSetting("reaction-window", "how long a reaction blocks the same reaction to the post from the same address", func(c *Config) *Duration {
+        return &c.Reactions.Window
+    }),
+    stringSetting("secret", "key signing visitor cookies, random on every start when empty", func(c *Config) *string {
+        return &c.Secret
+    }),
+    boolSetting("dev", "development mode: reload templates when they change", func(c *Config) *bool {
+        return &c.Dev
+    }),
+    boolSetting("feature-registration", "let anyone register as a viewer, not just invited users", func(c *Config) *bool {
+        return &c.Features.Registration
+    }),
+}
+
+// Load builds the configuration from the defaults, then the JSON file given by
+// -config or BLOG_CONFIG, then BLOG_* environment variables and finally the
+// command line flags. Later sources win.
+func Load(args []string) (*Config, error) {
+    fs := flag.NewFlagSet("blog", flag.ContinueOnError)
+    path := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "path to a JSON config file")
+
+    flags := map[string]string{}
+    for _, s := range settings {
+        value := &flagValue{name: s.name, flags: flags, isBool: s.isBool}
+        fs.Var(value, s.name, fmt.Sprintf("%s (env %s)", s.usage, s.env()))
+    }
+
+    if err := fs.Parse(args); err != nil {
+        return nil, err
+    }
+
+    cfg := Default()
+
+    if *path != "" {
+        if err := cfg.loadFile(*path); err != nil {
+            return nil, err
+        }
+    }
+
+    var errs []error
+    for _, s := range settings {
+        if v, ok := os.LookupEnv(s.env()); ok {
+            if err := s.set(cfg, v); err != nil {
+                errs = append(errs, fmt.Errorf("%s: %w", s.env(), err))
+            }
+        }
+    }
+
+    for _, s := range settings {
+        if v, ok := flags[s.name]; ok {
+            if err := s.set(cfg, v); err != nil {
+                errs = append(errs, fmt.Errorf("-%s: %w", s.name, err))
+            }
+        }
+    }
+
+    if len(errs) > 0 {
+        return nil, errors.Join(errs...)
+    }
+
+    if err := cfg.Validate(); err != nil {
+        return nil, err
+    }
+
+    return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("reading config file: %w", err)
+    }
+    defer f.Close()
+
+    dec := json.NewDecoder(f)
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(c); err != nil {
+        return fmt.Errorf("parsing config file %s: %w", path, err)
+    }
+
+    return nil
+}
+
+// Validate reports every problem with the configuration at once so a bad
+// deploy can be fixed in one go.
+func (c *Config) Validate() error {
+    var errs []error
+
+    if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+        errs = append(errs, fmt.Errorf("server.addr %q: %w", c.Server.Addr, err))
+    }
+
+    errs = append(errs, c.Site.validate()...)
+
+    for _, path := range c.Robots.Disallow {
+        if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t\r\n") {
+            errs = append(errs, fmt.Errorf("robots.disallow %q must be a path starting with / and without spaces", path))
+        }
+    }
+
+    switch c.Store {
+    case StoreMongo:
+        errs = append(errs, c.Mongo.validate()...)
+    case StoreSQLite:
+        if c.SQLite.Path == "" {
+            errs = append(errs, errors.New("sqlite.path is required"))
+        }
+    case StoreMemory:
+    default:
+        errs = append(errs, fmt.Errorf("store %q must be one of %s, %s, %s", c.Store, StoreMongo, StoreSQLite, StoreMemory))
+    }
+
+    if c.Paths.Uploads == "" {
+        errs = append(errs, errors.New("paths.uploads is required"))
+    }
+
+    if c.Paths.Templates != "" {
+        if err := checkDir("paths.templates", c.Paths.Templates); err != nil {
+            errs = append(errs, err)
+        }
+    }
+
+    if c.Paths.Static != "" {
+        if err := checkDir("paths.static", c.Paths.Static); err != nil {
+            errs = append(errs, err)
+        }
+    }
+
+    if c.Session.Lifetime.Duration <= 0 {
+        errs = append(errs, fmt.Errorf("session.lifetime must be positive, got %s", c.Session.Lifetime))
+    }
+
+    if !c.Dev && c.Site.URL == "" && !c.Session.SecureCookies {
+        errs = append(errs, errors.New("site.url or session.secure_cookies is required outside dev mode"))
+    }
+
+    errs = append(errs, c.Login.validate()...)
+
+    errs = append(errs, c.Password.validate()...)
+
+    errs = append(errs, c.Mail.validate()...)
+
+    if c.Mail.SMTP.Addr != "" && c.Site.URL == "" {
+        errs = append(errs, errors.New("site.url is required to send email through SMTP, for the links in it"))
+    }
+
+    errs = append(errs, c.Spam.validate()...)
+
+    errs = append(errs, c.Reactions.validate()...)
+
+    return errors.Join(errs...)
+}
+
+func (s SiteConfig) validate() []error {
+    var errs []error
+
+    if s.URL != "" {
+        u, err := url.Parse(s.URL)
+        switch {
+        case err != nil:
+            errs = append(errs, fmt.Errorf("site.url %q: %w", s.URL, err))
+        case u.Scheme != "http" && u.Scheme != "https", u.Host == "":
+            errs = append(errs, fmt.Errorf("site.url %q must be an absolute http or https URL", s.URL))
+        case u.RawQuery != "" || u.Fragment != "":
+            errs = append(errs, fmt.Errorf("site.url %q must not have a query or fragment", s.URL))
+        }
+    }
+
+    for _, link := range s.Links {
+        if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            errs = append(errs, fmt.Errorf("site.links %q must be an absolute http or https URL", link))
+        }
+    }
+
+    if strings.TrimSpace(s.Title) == "" {
+        errs = append(errs, errors.New("site.title is required"))
+    }
+
+    return errs
+}
+
+func (m MongoConfig) validate() []error {
+    var errs []error
+
+    if !strings.HasPrefix(m.URI, "mongodb://") && !strings.HasPrefix(m.URI, "mongodb+srv://") {
+        errs = append(errs, fmt.Errorf("mongo.uri %q must start with mongodb:// or mongodb+srv://", m.URI))
+    }
+
+    if m.Database == "" {
+        errs = append(errs, errors.New("mongo.database is required"))
+    } else if strings.ContainsAny(m.Database, "/\\. \"$") {
+        errs = append(errs, fmt.Errorf("mongo.database %q contains characters MongoDB does not allow", m.Database))
+    }
+
+    return errs
+}
+
+func (l LoginConfig) validate() []error {
+    var errs []error
+
+    if l.FreeAttempts < 0 {
+        errs = append(errs, fmt.Errorf("login.free_attempts must not be negative, got %d", l.FreeAttempts))
+    }
+
+    if l.BaseDelay.Duration <= 0 || l.MaxDelay.Duration < l.BaseDelay.Duration {
+        errs = append(errs, fmt.Errorf("login.base_delay must be positive and at most login.max_delay, got %s and %s", l.BaseDelay, l.MaxDelay))
+    }
+
+    if l.UserLockout <= l.FreeAttempts || l.IPLockout <= l.FreeAttempts {
+        errs = append(errs, fmt.Errorf("login.user_lockout and login.ip_lockout must be above login.free_attempts, got %d and %d", l.UserLockout, l.IPLockout))
+    }
+
+    if l.LockoutDuration.Duration <= 0 {
+        errs = append(errs, fmt.Errorf("login.lockout_duration must be positive, got %s", l.LockoutDuration))
+    }
+
+    return errs
+}
+
+func (p PasswordConfig) validate() []error {
+    var errs []error
+
+    // bcrypt only looks at the first 72 bytes of a password.
+    if p.MinLength < 8 || p.MinLength > 72 {
+        errs = append(errs, fmt.Errorf("password.min_length must be between 8 and 72, got %d", p.MinLength))
+    }
+
+    // The bounds of bcrypt.MinCost and bcrypt.MaxCost.
+    if p.Cost < 4 || p.Cost > 31 {
+        errs = append(errs, fmt.Errorf("password.cost must be between 4 and 31, got %d", p.Cost))
+    }
+
+    if p.ResetLifetime.Duration <= 0 {
+        errs = append(errs, fmt.Errorf("password.reset_lifetime must be positive, got %s", p.ResetLifetime))
+    }
+
+    return errs
+}
+
+func (m MailConfig) validate() []error {
+    var errs []error
+
+    if m.SMTP.Addr != "" {
+        if _, _, err := net.SplitHostPort(m.SMTP.Addr); err != nil {
+            errs = append(errs, fmt.Errorf("mail.smtp.addr %q must be host:port: %w", m.SMTP.Addr, err))
+        }
+        if m.From == "" {
+            errs = append(errs, errors.New("mail.from is required to send email through SMTP"))
+        }
+    }
+
+    return errs
+}
+
+func (s SpamConfig) validate() []error {
+    var errs []error
+
+    if s.MinDelay.Duration < 0 {
+        errs = append(errs, fmt.Errorf("spam.min_delay must not be negative, got %s", s.MinDelay))
+    }
+
+    if s.RateLimit <= 0 || s.RateWindow.Duration <= 0 {
+        errs = append(errs, fmt.Errorf("spam.rate_limit and spam.rate_window must be positive, got %d per %s", s.RateLimit, s.RateWindow))
+    }
+
+    if s.MaxLinks <= 0 {
+        errs = append(errs, fmt.Errorf("spam.max_links must be positive, got %d", s.MaxLinks))
+    }
+
+    if s.Threshold <= 0 || s.Threshold > 1 {
+        errs = append(errs, fmt.Errorf("spam.threshold must be above 0 and at most 1, got %g", s.Threshold))
+    }
+
+    return errs
+}
+
+func (r ReactionsConfig) validate() []error {
+    var errs []error
+
+    if len(r.Emoji) == 0 {
+        errs = append(errs, errors.New("reactions.emoji must offer at least one reaction"))
+    }
+
+    seen := map[string]bool{}
+    for _, emoji := range r.Emoji {
+        switch {
+        case emoji == "" || len(emoji) > 32:
+            errs = append(errs, fmt.Errorf("reactions.emoji %q must be between 1 and 32 bytes", emoji))
+        case strings.ContainsAny(emoji, ".$\"\\ \t\n"):
+            // They end up in field names and JSON paths.
+            errs = append(errs, fmt.Errorf("reactions.emoji %q must not contain spaces, quotes, dots or dollar signs", emoji))
+        case seen[emoji]:
+            errs = append(errs, fmt.Errorf("reactions.emoji %q is listed twice", emoji))
+        }
+        seen[emoji] = true
+    }
+
+    if r.Window.Duration < 0 {
+        errs = append(errs, fmt.Errorf("reactions.window must not be negative, got %s", r.Window))
+    }
+
+    return errs
+}
+
+func checkDir(name, path string) error {
+    info, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("%s: %w", name, err)
+    }
+
+    if !info.IsDir() {
+        return fmt.Errorf("%s: %s is not a directory", name, path)
+    }
+
+    return nil
+}
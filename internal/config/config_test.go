@@ -0,0 +1,254 @@
+package config
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+)
+
+// writeConfig writes a config file for Load to read and returns its path.
+func writeConfig(t *testing.T, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "config.json")
+    if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+    cfg, err := Load([]string{"-dev"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    want := Default()
+    want.Dev = true
+    if !reflect.DeepEqual(cfg, want) {
+        t.Errorf("Load() = %+v, want the defaults %+v", cfg, want)
+    }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+    path := writeConfig(t, `{
+        "server": { "addr": "file:1" },
+        "site": { "url": "https://file.example", "title": "File" },
+        "store": "sqlite",
+        "secret": "file"
+    }`)
+
+    t.Setenv("BLOG_CONFIG", path)
+    t.Setenv("BLOG_ADDR", "env:2")
+    t.Setenv("BLOG_SITE_TITLE", "Env")
+    t.Setenv("BLOG_SECRET", "env")
+
+    cfg, err := Load([]string{"-addr", "flag:3", "-secret=flag"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name      string
+        got, want string
+    }{
+        {"default", cfg.Mongo.Database, "blog"},
+        {"file", cfg.Store, StoreSQLite},
+        {"file", cfg.Site.URL, "https://file.example"},
+        {"env over file", cfg.Site.Title, "Env"},
+        {"flag over env and file", cfg.Server.Addr, "flag:3"},
+        {"flag over env and file", cfg.Secret, "flag"},
+    }
+    for _, test := range tests {
+        if test.got != test.want {
+            t.Errorf("%s: got %q, want %q", test.name, test.got, test.want)
+        }
+    }
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+    t.Setenv("BLOG_CONFIG", writeConfig(t, `{"store": "sqlite"}`))
+    path := writeConfig(t, `{"store": "memory"}`)
+
+    cfg, err := Load([]string{"-config", path, "-dev"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Store != StoreMemory {
+        t.Errorf("store %q, want the one from -config over BLOG_CONFIG", cfg.Store)
+    }
+}
+
+func TestSettingTypes(t *testing.T) {
+    t.Setenv("BLOG_SECURE_COOKIES", "true")
+    t.Setenv("BLOG_REACTIONS", " 👍 , ,🎉,")
+
+    cfg, err := Load([]string{
+        "-site-url", "https://example.com",
+        "-session-lifetime", "90m",
+        "-reaction-window=0s",
+        "-robots-disallow", "/drafts,/private",
+        "-secure-cookies=false",
+        "-feature-registration",
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if cfg.Site.URL != "https://example.com" {
+        t.Errorf("string: site.url %q", cfg.Site.URL)
+    }
+    if cfg.Session.Lifetime.Duration != 90*time.Minute || cfg.Reactions.Window.Duration != 0 {
+        t.Errorf("duration: session.lifetime %s, reactions.window %s", cfg.Session.Lifetime, cfg.Reactions.Window)
+    }
+    if want := []string{"👍", "🎉"}; !slices.Equal(cfg.Reactions.Emoji, want) {
+        t.Errorf("list: reactions.emoji %q, want %q", cfg.Reactions.Emoji, want)
+    }
+    if want := []string{"/drafts", "/private"}; !slices.Equal(cfg.Robots.Disallow, want) {
+        t.Errorf("list: robots.disallow %q, want %q", cfg.Robots.Disallow, want)
+    }
+    if cfg.Session.SecureCookies || !cfg.Features.Registration {
+        t.Errorf("bool: secure_cookies %v, registration %v, want false and true", cfg.Session.SecureCookies, cfg.Features.Registration)
+    }
+}
+
+func TestSettingEnvNames(t *testing.T) {
+    seen := map[string]bool{}
+    for _, s := range settings {
+        env := s.env()
+        if seen[env] {
+            t.Errorf("%s is used by two settings", env)
+        }
+        seen[env] = true
+    }
+
+    if got := (setting{name: "feature-registration"}).env(); got != "BLOG_FEATURE_REGISTRATION" {
+        t.Errorf("env() = %q, want BLOG_FEATURE_REGISTRATION", got)
+    }
+}
+
+func TestLoadParseErrors(t *testing.T) {
+    t.Setenv("BLOG_SESSION_LIFETIME", "a day")
+    t.Setenv("BLOG_DEV", "sometimes")
+
+    _, err := Load([]string{"-login-lockout", "15", "-secure-cookies=maybe"})
+    if err == nil {
+        t.Fatal("Load accepted unparseable settings")
+    }
+
+    // Every bad value is reported, not just the first one.
+    for _, want := range []string{"BLOG_SESSION_LIFETIME", "BLOG_DEV", "-login-lockout", "-secure-cookies"} {
+        if !strings.Contains(err.Error(), want) {
+            t.Errorf("error %q doesn't mention %s", err, want)
+        }
+    }
+}
+
+func TestLoadFileErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+    }{
+        {"unknown field", `{"stroe": "sqlite"}`},
+        {"duration as a number", `{"session": {"lifetime": 86400}}`},
+        {"bad duration", `{"session": {"lifetime": "a day"}}`},
+        {"bool as a string", `{"dev": "true"}`},
+        {"list as a string", `{"reactions": {"emoji": "👍,🎉"}}`},
+        {"not JSON", `store = "sqlite"`},
+    }
+    for _, test := range tests {
+        if _, err := Load([]string{"-dev", "-config", writeConfig(t, test.content)}); err == nil {
+            t.Errorf("%s: Load accepted %s", test.name, test.content)
+        }
+    }
+
+    _, err := Load([]string{"-dev", "-config", filepath.Join(t.TempDir(), "missing.json")})
+    if !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("missing file: %v, want ErrNotExist", err)
+    }
+}
+
+func TestDurationJSON(t *testing.T) {
+    var d Duration
+    if err := d.UnmarshalJSON([]byte(`"1h30m"`)); err != nil || d.Duration != 90*time.Minute {
+        t.Errorf("UnmarshalJSON = %s, %v", d, err)
+    }
+
+    b, err := d.MarshalJSON()
+    if err != nil || string(b) != `"1h30m0s"` {
+        t.Errorf("MarshalJSON = %s, %v", b, err)
+    }
+}
+
+func TestValidate(t *testing.T) {
+    cfg := Default()
+    cfg.Site.URL = "https://example.com"
+    if err := cfg.Validate(); err != nil {
+        t.Errorf("the defaults with a site.url: %v", err)
+    }
+
+    cfg = Default()
+    cfg.Server.Addr = "no port"
+    cfg.Site.URL = "example.com"
+    cfg.Store = "postgres"
+    cfg.Session.Lifetime.Duration = 0
+    cfg.Login.MaxDelay.Duration = time.Millisecond
+    cfg.Password.MinLength = 4
+    cfg.Mail.SMTP.Addr = "localhost"
+    cfg.Spam.Threshold = 2
+    cfg.Reactions.Emoji = []string{"👍", "👍", "a.b"}
+    cfg.Robots.Disallow = []string{"admin"}
+
+    err := cfg.Validate()
+    if err == nil {
+        t.Fatal("Validate accepted a broken config")
+    }
+
+    want := []string{
+        "server.addr",
+        "site.url",
+        "store",
+        "session.lifetime",
+        "login.base_delay",
+        "password.min_length",
+        "mail.smtp.addr",
+        "mail.from",
+        "spam.threshold",
+        `reactions.emoji "👍" is listed twice`,
+        `reactions.emoji "a.b"`,
+        "robots.disallow",
+    }
+    joined, ok := err.(interface{ Unwrap() []error })
+    if !ok || len(joined.Unwrap()) != len(want) {
+        t.Errorf("Validate returned %q, want %d joined errors", err, len(want))
+    }
+    for _, w := range want {
+        if !strings.Contains(err.Error(), w) {
+            t.Errorf("error doesn't mention %s:\n%s", w, err)
+        }
+    }
+}
+
+func TestValidateCookies(t *testing.T) {
+    tests := []struct {
+        name  string
+        edit  func(c *Config)
+        valid bool
+    }{
+        {"nothing", func(c *Config) {}, false},
+        {"site.url", func(c *Config) { c.Site.URL = "http://localhost:8880" }, true},
+        {"secure cookies", func(c *Config) { c.Session.SecureCookies = true }, true},
+        {"dev", func(c *Config) { c.Dev = true }, true},
+    }
+    for _, test := range tests {
+        cfg := Default()
+        test.edit(cfg)
+        if err := cfg.Validate(); (err == nil) != test.valid {
+            t.Errorf("%s: Validate = %v", test.name, err)
+        }
+    }
+}
@@ -0,0 +1,98 @@
+package feed
+
+import (
+    "encoding/xml"
+    "time"
+)
+
+type atomFeed struct {
+    XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+    Title    string      `xml:"title"`
+    Subtitle string      `xml:"subtitle,omitempty"`
+    Id       string      `xml:"id"`
+    Updated  string      `xml:"updated"`
+    Links    []atomLink  `xml:"link"`
+    Author   *atomAuthor `xml:"author"`
+    Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+    Href string `xml:"href,attr"`
+    Rel  string `xml:"rel,attr,omitempty"`
+    Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+    Name string `xml:"name"`
+}
+
+type atomText struct {
+    Type  string `xml:"type,attr,omitempty"`
+    Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+    Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+    Title      string         `xml:"title"`
+    Id         string         `xml:"id"`
+    Links      []atomLink     `xml:"link"`
+    Published  string         `xml:"published"`
+    Updated    string         `xml:"updated"`
+    Summary    *atomText      `xml:"summary"`
+    Content    *atomText      `xml:"content"`
+    Categories []atomCategory `xml:"category"`
+}
+
+// Atom writes f as an Atom 1.0 document.
+func Atom(f Feed) ([]byte, error) {
+    doc := atomFeed{
+        Title:    f.Title,
+        Subtitle: f.Description,
+        Id:       f.Self,
+        Updated:  atomTime(f.Updated),
+        Links: []atomLink{
+            {Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+            {Href: f.Link, Rel: "alternate", Type: "text/html"},
+        },
+    }
+    if f.Author != "" {
+        doc.Author = &atomAuthor{Name: f.Author}
+    }
+
+    for _, item := range f.Items {
+        entry := atomEntry{
+            Title:     item.Title,
+            Id:        item.Id,
+            Links:     []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+            Published: atomTime(item.Published),
+            Updated:   atomTime(item.modified()),
+        }
+        if item.Image != "" {
+            entry.Links = append(entry.Links, atomLink{Href: item.Image, Rel: "enclosure", Type: imageType(item.Image)})
+        }
+        if item.Summary != "" {
+            entry.Summary = &atomText{Type: "text", Value: item.Summary}
+        }
+        if item.Content != "" {
+            entry.Content = &atomText{Type: "html", Value: item.Content}
+        }
+        for _, tag := range item.Tags {
+            entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+        }
+        doc.Entries = append(doc.Entries, entry)
+    }
+
+    return marshalXML(doc)
+}
+
+// atomTime formats t as Atom wants it. A feed with nothing in it yet is
+// dated to the start of the Unix epoch rather than left without a date.
+func atomTime(t time.Time) string {
+    if t.IsZero() {
+        t = time.Unix(0, 0)
+    }
+    return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,85 @@
+// Package feed writes lists of posts as RSS 2.0, Atom and JSON Feed
+// documents.
+package feed
+
+import (
+    "mime"
+    "net/url"
+    "path"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// Feed is what all three formats are made from. Every URL in it must be
+// absolute.
+type Feed struct {
+    Title       string
+    Description string
+    // Link is the page the feed follows, e.g. the blog or a tag's listing.
+    Link        string
+    // Self is the URL the feed itself is served at.
+    Self        string
+    Author      string
+    Updated     time.Time
+    Items       []Item
+}
+
+type Item struct {
+    // Id stays the same for as long as the post exists, even if it moves.
+    Id        string
+    Title     string
+    Link      string
+    Summary   string
+    // Content is the post's body rendered to HTML.
+    Content   string
+    Image     string
+    Published time.Time
+    Updated   time.Time
+    Tags      []string
+}
+
+// Format is one of the ways a Feed can be written.
+type Format struct {
+    // Name is the file name the format is served under, e.g. feed.xml.
+    Name        string
+    ContentType string
+    Encode      func(f Feed) ([]byte, error)
+}
+
+var (
+    RSSFormat  = Format{Name: "feed.xml", ContentType: "application/rss+xml; charset=utf-8", Encode: RSS}
+    AtomFormat = Format{Name: "atom.xml", ContentType: "application/atom+xml; charset=utf-8", Encode: Atom}
+    JSONFormat = Format{Name: "feed.json", ContentType: "application/feed+json; charset=utf-8", Encode: JSON}
+)
+
+var Formats = []Format{RSSFormat, AtomFormat, JSONFormat}
+
+var uploadsPattern = regexp.MustCompile(`(?i)\b(src|href|poster)=(["'])(?:\.?/)?uploads/`)
+
+// AbsoluteUploads rewrites links to uploaded files in html, which the site
+// writes as relative paths, to point at base, since feed readers show posts
+// away from the site.
+func AbsoluteUploads(html, base string) string {
+    base = strings.TrimSuffix(base, "/")
+    return uploadsPattern.ReplaceAllString(html, "${1}=${2}"+strings.ReplaceAll(base, "$", "$$")+"/uploads/")
+}
+
+// imageType guesses an image's media type from its URL's extension.
+func imageType(link string) string {
+    if u, err := url.Parse(link); err == nil {
+        if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+            return t
+        }
+    }
+    return "image/*"
+}
+
+// modified is when the item last changed, its publication if nothing later
+// is known.
+func (i Item) modified() time.Time {
+    if i.Updated.After(i.Published) {
+        return i.Updated
+    }
+    return i.Published
+}
@@ -0,0 +1,72 @@
+package feed
+
+import (
+    "bytes"
+    "encoding/json"
+    "time"
+)
+
+type jsonFeed struct {
+    Version     string       `json:"version"`
+    Title       string       `json:"title"`
+    HomePageURL string       `json:"home_page_url,omitempty"`
+    FeedURL     string       `json:"feed_url,omitempty"`
+    Description string       `json:"description,omitempty"`
+    Authors     []jsonAuthor `json:"authors,omitempty"`
+    Items       []jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+    Name string `json:"name"`
+}
+
+type jsonItem struct {
+    Id            string   `json:"id"`
+    URL           string   `json:"url,omitempty"`
+    Title         string   `json:"title,omitempty"`
+    ContentHTML   string   `json:"content_html,omitempty"`
+    Summary       string   `json:"summary,omitempty"`
+    Image         string   `json:"image,omitempty"`
+    DatePublished string   `json:"date_published,omitempty"`
+    DateModified  string   `json:"date_modified,omitempty"`
+    Tags          []string `json:"tags,omitempty"`
+}
+
+// JSON writes f as a JSON Feed 1.1 document.
+func JSON(f Feed) ([]byte, error) {
+    doc := jsonFeed{
+        Version:     "https://jsonfeed.org/version/1.1",
+        Title:       f.Title,
+        HomePageURL: f.Link,
+        FeedURL:     f.Self,
+        Description: f.Description,
+        Items:       []jsonItem{},
+    }
+    if f.Author != "" {
+        doc.Authors = []jsonAuthor{{Name: f.Author}}
+    }
+
+    for _, item := range f.Items {
+        doc.Items = append(doc.Items, jsonItem{
+            Id:            item.Id,
+            URL:           item.Link,
+            Title:         item.Title,
+            ContentHTML:   item.Content,
+            Summary:       item.Summary,
+            Image:         item.Image,
+            DatePublished: item.Published.UTC().Format(time.RFC3339),
+            DateModified:  item.modified().UTC().Format(time.RFC3339),
+            Tags:          item.Tags,
+        })
+    }
+
+    // Posts are full of markup, which needs no escaping outside of HTML.
+    var buf bytes.Buffer
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(doc); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package feed
+
+import (
+    "encoding/xml"
+    "time"
+)
+
+type rss struct {
+    XMLName xml.Name   `xml:"rss"`
+    Version string     `xml:"version,attr"`
+    Atom    string     `xml:"xmlns:atom,attr"`
+    Content string     `xml:"xmlns:content,attr"`
+    Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+    Title         string    `xml:"title"`
+    Link          string    `xml:"link"`
+    Description   string    `xml:"description"`
+    Self          atomLink  `xml:"atom:link"`
+    LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+    Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+    Title       string       `xml:"title"`
+    Link        string       `xml:"link"`
+    Guid        rssGuid      `xml:"guid"`
+    PubDate     string       `xml:"pubDate"`
+    Description string       `xml:"description,omitempty"`
+    Content     string       `xml:"content:encoded,omitempty"`
+    Categories  []string     `xml:"category"`
+    Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGuid struct {
+    Value       string `xml:",chardata"`
+    IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+    URL    string `xml:"url,attr"`
+    Length int    `xml:"length,attr"`
+    Type   string `xml:"type,attr"`
+}
+
+// RSS writes f as an RSS 2.0 document, with the full post in content:encoded.
+func RSS(f Feed) ([]byte, error) {
+    doc := rss{
+        Version: "2.0",
+        Atom:    "http://www.w3.org/2005/Atom",
+        Content: "http://purl.org/rss/1.0/modules/content/",
+        Channel: rssChannel{
+            Title:       f.Title,
+            Link:        f.Link,
+            Description: f.Description,
+            Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+        },
+    }
+    if !f.Updated.IsZero() {
+        doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+    }
+
+    for _, item := range f.Items {
+        entry := rssItem{
+            Title:       item.Title,
+            Link:        item.Link,
+            Guid:        rssGuid{Value: item.Id, IsPermaLink: item.Id == item.Link},
+            PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+            Description: item.Summary,
+            Content:     item.Content,
+            Categories:  item.Tags,
+        }
+        if item.Image != "" {
+            entry.Enclosure = &rssEnclosure{URL: item.Image, Type: imageType(item.Image)}
+        }
+        doc.Channel.Items = append(doc.Channel.Items, entry)
+    }
+
+    return marshalXML(doc)
+}
+
+func marshalXML(doc any) ([]byte, error) {
+    out, err := xml.MarshalIndent(doc, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append([]byte(xml.Header), append(out, '\n')...), nil
+}
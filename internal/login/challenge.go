@@ -0,0 +1,93 @@
+package login
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "sync"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    // ChallengeLifetime is how long users have to enter their second factor
+    // after their password.
+    ChallengeLifetime = 5 * time.Minute
+    // challengeAttempts is how many wrong codes a challenge takes before it
+    // is dropped and the password has to be entered again.
+    challengeAttempts = 5
+)
+
+type challenge struct {
+    user     primitive.ObjectID
+    expires  time.Time
+    failures int
+}
+
+// Challenges are logins whose password was right, waiting for the second
+// factor. They are known by a random token kept in a cookie until then.
+type Challenges struct {
+    lock    sync.Mutex
+    pending map[string]*challenge
+}
+
+func NewChallenges() *Challenges {
+    return &Challenges{pending: map[string]*challenge{}}
+}
+
+// Start makes a challenge for the user and returns its token.
+func (c *Challenges) Start(user primitive.ObjectID) (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    token := base64.RawURLEncoding.EncodeToString(b)
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    now := time.Now()
+    for t, ch := range c.pending {
+        if !now.Before(ch.expires) {
+            delete(c.pending, t)
+        }
+    }
+    c.pending[token] = &challenge{user: user, expires: now.Add(ChallengeLifetime)}
+    return token, nil
+}
+
+// User is who the challenge is for, if it is still pending.
+func (c *Challenges) User(token string) (primitive.ObjectID, bool) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    ch, ok := c.pending[token]
+    if !ok || !time.Now().Before(ch.expires) {
+        return primitive.ObjectID{}, false
+    }
+    return ch.user, true
+}
+
+// Fail counts a wrong code and tells whether the challenge is still pending.
+func (c *Challenges) Fail(token string) bool {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    ch, ok := c.pending[token]
+    if !ok {
+        return false
+    }
+    ch.failures++
+    if ch.failures >= challengeAttempts {
+        delete(c.pending, token)
+        return false
+    }
+    return true
+}
+
+// Finish ends a challenge once it was met.
+func (c *Challenges) Finish(token string) {
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    delete(c.pending, token)
+}
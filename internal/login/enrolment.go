@@ -0,0 +1,62 @@
+package login
+
+import (
+    "sync"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EnrolmentLifetime is how long users have to scan a new TOTP secret and
+// confirm it with a code.
+const EnrolmentLifetime = 15 * time.Minute
+
+type enrolment struct {
+    secret  string
+    expires time.Time
+}
+
+// Enrolments are the TOTP secrets shown to users setting up two-factor
+// authentication, kept on the server by session until confirmed, so that
+// the secret turned on is always one the server made.
+type Enrolments struct {
+    lock    sync.Mutex
+    pending map[primitive.ObjectID]enrolment
+}
+
+func NewEnrolments() *Enrolments {
+    return &Enrolments{pending: map[primitive.ObjectID]enrolment{}}
+}
+
+// Start keeps secret for the session, in place of any it had.
+func (e *Enrolments) Start(session primitive.ObjectID, secret string) {
+    e.lock.Lock()
+    defer e.lock.Unlock()
+
+    now := time.Now()
+    for s, en := range e.pending {
+        if !now.Before(en.expires) {
+            delete(e.pending, s)
+        }
+    }
+    e.pending[session] = enrolment{secret: secret, expires: now.Add(EnrolmentLifetime)}
+}
+
+// Secret is the secret being set up in the session, if it hasn't expired.
+func (e *Enrolments) Secret(session primitive.ObjectID) (string, bool) {
+    e.lock.Lock()
+    defer e.lock.Unlock()
+
+    en, ok := e.pending[session]
+    if !ok || !time.Now().Before(en.expires) {
+        return "", false
+    }
+    return en.secret, true
+}
+
+// Finish forgets the session's secret once it was turned on.
+func (e *Enrolments) Finish(session primitive.ObjectID) {
+    e.lock.Lock()
+    defer e.lock.Unlock()
+
+    delete(e.pending, session)
+}
@@ -0,0 +1,188 @@
+// Package login slows down and locks out password guessing on the admin
+// login, counting failures per username and per address.
+package login
+
+import (
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+// Kinds of keys failures are counted under.
+const (
+    KindUser = "user"
+    KindIP   = "ip"
+)
+
+// Lock is a username or address that may not try to log in until Until.
+type Lock struct {
+    Kind     string
+    Key      string
+    Failures int
+    Until    time.Time
+}
+
+type record struct {
+    failures int
+    // next is the earliest the next attempt may be made.
+    next     time.Time
+    locked   bool
+    last     time.Time
+}
+
+// Limiter keeps track of failed logins. Users are counted whether they
+// exist or not, so that being slowed down or locked out gives nothing away.
+type Limiter struct {
+    lock      sync.Mutex
+    cfg       config.LoginConfig
+    records   map[[2]string]*record
+    lastSweep time.Time
+    // now tells the time, which tests move along themselves.
+    now       func() time.Time
+}
+
+func NewLimiter(cfg config.LoginConfig) *Limiter {
+    return &Limiter{cfg: cfg, records: map[[2]string]*record{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func key(kind, value string) [2]string {
+    if kind == KindUser {
+        value = strings.ToLower(value)
+    }
+    return [2]string{kind, value}
+}
+
+// Wait is how long the username and address have to wait before they may
+// try to log in again, zero if they may now, and whether that is because
+// one of them is locked out.
+func (l *Limiter) Wait(username, ip string) (wait time.Duration, locked bool) {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    now := l.now()
+    for _, k := range [][2]string{key(KindUser, username), key(KindIP, ip)} {
+        r, ok := l.records[k]
+        if !ok || !now.Before(r.next) {
+            continue
+        }
+        if r.next.Sub(now) > wait {
+            wait = r.next.Sub(now)
+        }
+        locked = locked || r.locked
+    }
+    return wait, locked
+}
+
+// Failure counts a failed login and returns the locks it brought about.
+func (l *Limiter) Failure(username, ip string) []Lock {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    now := l.now()
+    l.sweep(now)
+
+    var locks []Lock
+    for _, k := range [][2]string{key(KindUser, username), key(KindIP, ip)} {
+        limit := l.cfg.UserLockout
+        if k[0] == KindIP {
+            limit = l.cfg.IPLockout
+        }
+
+        r, ok := l.records[k]
+        if !ok || l.expired(r, now) {
+            r = &record{}
+            l.records[k] = r
+        }
+        r.failures++
+        r.last = now
+
+        switch {
+        case r.failures >= limit:
+            if !r.locked {
+                r.locked = true
+                r.next = now.Add(l.cfg.LockoutDuration.Duration)
+                locks = append(locks, Lock{Kind: k[0], Key: k[1], Failures: r.failures, Until: r.next})
+            }
+        case r.failures > l.cfg.FreeAttempts:
+            r.next = now.Add(l.delay(r.failures - l.cfg.FreeAttempts))
+        }
+    }
+    return locks
+}
+
+// delay is the wait after the nth failure beyond the free ones.
+func (l *Limiter) delay(n int) time.Duration {
+    d := l.cfg.BaseDelay.Duration
+    for i := 1; i < n && d < l.cfg.MaxDelay.Duration; i++ {
+        d *= 2
+    }
+    return min(d, l.cfg.MaxDelay.Duration)
+}
+
+// Success forgets the failures of a username once its password was right.
+// The address's are kept, or one account would let it guess at the others.
+func (l *Limiter) Success(username string) {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    delete(l.records, key(KindUser, username))
+}
+
+// Locked lists the usernames and addresses locked out right now, the ones
+// locked out the longest to come first.
+func (l *Limiter) Locked() []Lock {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    now := l.now()
+    var locks []Lock
+    for k, r := range l.records {
+        if r.locked && now.Before(r.next) {
+            locks = append(locks, Lock{Kind: k[0], Key: k[1], Failures: r.failures, Until: r.next})
+        }
+    }
+    sort.Slice(locks, func(i, j int) bool {
+        return locks[i].Until.After(locks[j].Until)
+    })
+    return locks
+}
+
+// Unlock lifts a lockout and forgets the failures behind it. It tells
+// whether there was one.
+func (l *Limiter) Unlock(kind, value string) bool {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    k := key(kind, value)
+    r, ok := l.records[k]
+    if !ok || !r.locked {
+        return false
+    }
+    delete(l.records, k)
+    return true
+}
+
+// expired tells whether a record's failures no longer count: its lockout
+// ran out, or it has gone quiet for as long as a lockout lasts.
+func (l *Limiter) expired(r *record, now time.Time) bool {
+    if now.Before(r.next) {
+        return false
+    }
+    return r.locked || now.Sub(r.last) > l.cfg.LockoutDuration.Duration
+}
+
+// sweep forgets expired records so the map doesn't keep growing.
+func (l *Limiter) sweep(now time.Time) {
+    if now.Sub(l.lastSweep) < l.cfg.LockoutDuration.Duration {
+        return
+    }
+
+    for k, r := range l.records {
+        if l.expired(r, now) {
+            delete(l.records, k)
+        }
+    }
+    l.lastSweep = now
+}
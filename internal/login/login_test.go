@@ -0,0 +1,189 @@
+package login
+
+import (
+    "fmt"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+var testConfig = config.LoginConfig{
+    FreeAttempts:    2,
+    BaseDelay:       config.Duration{Duration: time.Second},
+    MaxDelay:        config.Duration{Duration: 8 * time.Second},
+    UserLockout:     6,
+    IPLockout:       10,
+    LockoutDuration: config.Duration{Duration: time.Minute},
+}
+
+// clock is a time that only moves when told to.
+type clock struct {
+    t time.Time
+}
+
+func (c *clock) Now() time.Time {
+    return c.t
+}
+
+func (c *clock) Advance(d time.Duration) {
+    c.t = c.t.Add(d)
+}
+
+func newTestLimiter() (*Limiter, *clock) {
+    c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+    l := NewLimiter(testConfig)
+    l.now = c.Now
+    l.lastSweep = c.Now()
+    return l, c
+}
+
+// ip is a different address for every attempt, so only the username's
+// failures count.
+func ip(i int) string {
+    return fmt.Sprintf("192.0.2.%d", i)
+}
+
+func TestBackoff(t *testing.T) {
+    l, _ := newTestLimiter()
+
+    want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second}
+    for i, delay := range want {
+        l.Failure("alice", "192.0.2.1")
+        wait, locked := l.Wait("alice", "192.0.2.1")
+        if wait != delay || locked {
+            t.Errorf("after %d failures waited %s, locked %v, want %s, not locked", i+1, wait, locked, delay)
+        }
+    }
+}
+
+func TestDelay(t *testing.T) {
+    l, _ := newTestLimiter()
+
+    tests := []struct {
+        n    int
+        want time.Duration
+    }{
+        {1, time.Second},
+        {2, 2 * time.Second},
+        {3, 4 * time.Second},
+        {4, 8 * time.Second},
+        {5, 8 * time.Second},
+        {40, 8 * time.Second},
+    }
+    for _, test := range tests {
+        if got := l.delay(test.n); got != test.want {
+            t.Errorf("delay(%d) = %s, want %s", test.n, got, test.want)
+        }
+    }
+}
+
+func TestLockout(t *testing.T) {
+    l, c := newTestLimiter()
+
+    for i := 1; i < testConfig.UserLockout; i++ {
+        if locks := l.Failure("alice", ip(i)); len(locks) != 0 {
+            t.Fatalf("failure %d locked out %v", i, locks)
+        }
+    }
+    locks := l.Failure("Alice", ip(testConfig.UserLockout))
+    if len(locks) != 1 || locks[0].Kind != KindUser || locks[0].Key != "alice" {
+        t.Fatalf("failure %d locked out %v, want alice", testConfig.UserLockout, locks)
+    }
+
+    wait, locked := l.Wait("ALICE", ip(100))
+    if wait != time.Minute || !locked {
+        t.Errorf("locked out user waited %s, locked %v, want 1m, locked", wait, locked)
+    }
+    if got := l.Locked(); len(got) != 1 || got[0].Until != c.Now().Add(time.Minute) {
+        t.Errorf("Locked() = %v, want alice until %s", got, c.Now().Add(time.Minute))
+    }
+
+    // More failures while locked out don't extend it.
+    if locks := l.Failure("alice", ip(101)); len(locks) != 0 {
+        t.Errorf("failure while locked out locked out %v again", locks)
+    }
+
+    c.Advance(time.Minute)
+    if wait, locked := l.Wait("alice", ip(102)); wait != 0 || locked {
+        t.Errorf("after the lockout waited %s, locked %v", wait, locked)
+    }
+    if got := l.Locked(); len(got) != 0 {
+        t.Errorf("Locked() = %v after the lockout", got)
+    }
+
+    l.Failure("alice", ip(103))
+    if wait, _ := l.Wait("alice", ip(103)); wait != 0 {
+        t.Errorf("first failure after the lockout waited %s", wait)
+    }
+}
+
+func TestIPLockout(t *testing.T) {
+    l, _ := newTestLimiter()
+
+    var locks []Lock
+    for i := 1; i <= testConfig.IPLockout; i++ {
+        locks = l.Failure(fmt.Sprintf("user%d", i), "192.0.2.1")
+    }
+    if len(locks) != 1 || locks[0].Kind != KindIP || locks[0].Key != "192.0.2.1" {
+        t.Fatalf("failure %d locked out %v, want the address", testConfig.IPLockout, locks)
+    }
+    if _, locked := l.Wait("someone", "192.0.2.1"); !locked {
+        t.Error("locked out address can still try")
+    }
+    if !l.Unlock(KindIP, "192.0.2.1") {
+        t.Error("Unlock didn't find the lockout")
+    }
+    if wait, locked := l.Wait("someone", "192.0.2.1"); wait != 0 || locked {
+        t.Errorf("unlocked address waited %s, locked %v", wait, locked)
+    }
+}
+
+func TestSuccess(t *testing.T) {
+    l, _ := newTestLimiter()
+
+    for i := 0; i < 4; i++ {
+        l.Failure("alice", "192.0.2.1")
+    }
+    l.Success("alice")
+
+    if wait, _ := l.Wait("alice", ip(100)); wait != 0 {
+        t.Errorf("username waited %s after logging in", wait)
+    }
+    if wait, _ := l.Wait("bob", "192.0.2.1"); wait != 2*time.Second {
+        t.Errorf("address waited %s after logging in, want its failures kept", wait)
+    }
+
+    l.Failure("alice", ip(101))
+    if wait, _ := l.Wait("alice", ip(102)); wait != 0 {
+        t.Errorf("failure after logging in waited %s, want counting from the start", wait)
+    }
+}
+
+func TestExpiry(t *testing.T) {
+    l, c := newTestLimiter()
+
+    for i := 1; i <= 4; i++ {
+        l.Failure("alice", ip(i))
+    }
+    if wait, _ := l.Wait("alice", ip(100)); wait != 2*time.Second {
+        t.Fatalf("waited %s, want 2s", wait)
+    }
+
+    // Failures a lockout's length apart still add up.
+    c.Advance(time.Minute)
+    l.Failure("alice", ip(5))
+    if wait, _ := l.Wait("alice", ip(100)); wait != 4*time.Second {
+        t.Errorf("waited %s, want 4s", wait)
+    }
+
+    // After going quiet for longer they are forgotten, and swept away.
+    c.Advance(time.Minute + time.Second)
+    l.Failure("bob", ip(6))
+    if _, ok := l.records[key(KindUser, "alice")]; ok {
+        t.Error("quiet username wasn't swept")
+    }
+    l.Failure("alice", ip(7))
+    if wait, _ := l.Wait("alice", ip(100)); wait != 0 {
+        t.Errorf("first failure after going quiet waited %s", wait)
+    }
+}
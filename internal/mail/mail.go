@@ -0,0 +1,139 @@
+// Package mail sends the site's emails, through an SMTP server or, when there
+// is none, to the log.
+package mail
+
+import (
+    "bytes"
+    "context"
+    "crypto/tls"
+    "fmt"
+    "log"
+    "mime"
+    "mime/quotedprintable"
+    "net"
+    "net/smtp"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+// Message is a plain text email to one recipient.
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Mailer sends messages. The site only ever goes through this, so any way of
+// sending email can be plugged in.
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// New is the Mailer cfg describes: SMTP when a server is set, the log
+// otherwise.
+func New(cfg config.MailConfig) Mailer {
+    if cfg.SMTP.Addr == "" {
+        return Log{}
+    }
+    return &SMTP{
+        Addr:     cfg.SMTP.Addr,
+        Username: cfg.SMTP.Username,
+        Password: cfg.SMTP.Password,
+        From:     cfg.From,
+    }
+}
+
+// Log writes messages to the log instead of sending them, for sites without
+// an SMTP server.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+    log.Printf("Email to %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+    return nil
+}
+
+// SMTP sends messages through an SMTP server, upgrading to TLS when the
+// server offers it and logging in when a username is set.
+type SMTP struct {
+    Addr     string
+    Username string
+    Password string
+    From     string
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+    host, _, err := net.SplitHostPort(s.Addr)
+    if err != nil {
+        return err
+    }
+
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+    if err != nil {
+        return err
+    }
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+
+    client, err := smtp.NewClient(conn, host)
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    defer client.Close()
+
+    if ok, _ := client.Extension("STARTTLS"); ok {
+        if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+            return err
+        }
+    }
+    // PlainAuth refuses to send the password unencrypted, except to
+    // localhost.
+    if s.Username != "" {
+        if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+            return err
+        }
+    }
+
+    if err := client.Mail(s.From); err != nil {
+        return err
+    }
+    if err := client.Rcpt(msg.To); err != nil {
+        return err
+    }
+
+    w, err := client.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(s.format(msg)); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return client.Quit()
+}
+
+// format writes out msg with its headers, quoted-printable so that any text
+// goes through.
+func (s *SMTP) format(msg Message) []byte {
+    // Line breaks in headers would let them smuggle in more headers.
+    header := strings.NewReplacer("\r", "", "\n", "")
+
+    var b bytes.Buffer
+    fmt.Fprintf(&b, "From: %s\r\n", header.Replace(s.From))
+    fmt.Fprintf(&b, "To: %s\r\n", header.Replace(msg.To))
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", header.Replace(msg.Subject)))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+    b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+    qp := quotedprintable.NewWriter(&b)
+    qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+    qp.Close()
+    return b.Bytes()
+}
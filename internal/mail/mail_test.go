@@ -0,0 +1,256 @@
+package mail
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "io"
+    "mime"
+    "mime/quotedprintable"
+    "net"
+    "net/mail"
+    "net/textproto"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// smtpServer is just enough of an SMTP server to take one message at a time
+// over a real connection.
+type smtpServer struct {
+    listener net.Listener
+    // auth makes the server offer AUTH PLAIN and take only this username
+    // and password.
+    auth     [2]string
+    // reject is a command, e.g. RCPT, that the server turns down.
+    reject   string
+
+    lock     sync.Mutex
+    commands []string
+    data     []byte
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+    t.Helper()
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { listener.Close() })
+
+    s := &smtpServer{listener: listener}
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            go s.serve(conn)
+        }
+    }()
+    return s
+}
+
+func (s *smtpServer) Addr() string {
+    return s.listener.Addr().String()
+}
+
+func (s *smtpServer) record(command string, data []byte) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    s.commands = append(s.commands, command)
+    if data != nil {
+        s.data = data
+    }
+}
+
+func (s *smtpServer) received() ([]string, []byte) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    return s.commands, s.data
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+    defer conn.Close()
+    text := textproto.NewConn(conn)
+    text.PrintfLine("220 localhost ESMTP test")
+
+    for {
+        line, err := text.ReadLine()
+        if err != nil {
+            return
+        }
+        verb, _, _ := strings.Cut(line, " ")
+        verb = strings.ToUpper(verb)
+        s.record(line, nil)
+
+        if verb == s.reject {
+            text.PrintfLine("550 no thanks")
+            continue
+        }
+
+        switch verb {
+        case "EHLO":
+            if s.auth[0] != "" {
+                text.PrintfLine("250-localhost")
+                text.PrintfLine("250 AUTH PLAIN")
+            } else {
+                text.PrintfLine("250 localhost")
+            }
+        case "AUTH":
+            want := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00"+s.auth[0]+"\x00"+s.auth[1]))
+            if line == want {
+                text.PrintfLine("235 ok")
+            } else {
+                text.PrintfLine("535 bad credentials")
+            }
+        case "MAIL", "RCPT":
+            text.PrintfLine("250 ok")
+        case "DATA":
+            text.PrintfLine("354 go ahead")
+            data, err := io.ReadAll(text.DotReader())
+            if err != nil {
+                return
+            }
+            s.record(".", data)
+            text.PrintfLine("250 queued")
+        case "QUIT":
+            text.PrintfLine("221 bye")
+            return
+        default:
+            text.PrintfLine("502 not implemented")
+        }
+    }
+}
+
+func send(t *testing.T, s *SMTP, msg Message) error {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    return s.Send(ctx, msg)
+}
+
+func TestSMTP(t *testing.T) {
+    server := newSMTPServer(t)
+    mailer := &SMTP{Addr: server.Addr(), From: "blog@example.com"}
+
+    msg := Message{
+        To:      "alice@example.com",
+        Subject: "Reset your password for Café",
+        Body:    "Open this link:\n\nhttps://example.com/reset-password?token=abc=def\n",
+    }
+    if err := send(t, mailer, msg); err != nil {
+        t.Fatal(err)
+    }
+
+    commands, data := server.received()
+    want := []string{"MAIL FROM:<blog@example.com>", "RCPT TO:<alice@example.com>", "DATA", ".", "QUIT"}
+    if got := commands[1:]; strings.Join(got, "|") != strings.Join(want, "|") {
+        t.Errorf("commands %q, want EHLO then %q", commands, want)
+    }
+
+    parsed, err := mail.ReadMessage(bytes.NewReader(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := parsed.Header.Get("From"); got != "blog@example.com" {
+        t.Errorf("From %q", got)
+    }
+    if got := parsed.Header.Get("To"); got != "alice@example.com" {
+        t.Errorf("To %q", got)
+    }
+    decoded, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+    if err != nil || decoded != msg.Subject {
+        t.Errorf("Subject %q, %v, want %q", decoded, err, msg.Subject)
+    }
+    if _, err := parsed.Header.Date(); err != nil {
+        t.Errorf("Date: %v", err)
+    }
+
+    body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := strings.ReplaceAll(string(body), "\r\n", "\n"); got != msg.Body {
+        t.Errorf("body %q, want %q", got, msg.Body)
+    }
+}
+
+func TestSMTPAuth(t *testing.T) {
+    server := newSMTPServer(t)
+    server.auth = [2]string{"blog", "secret"}
+    msg := Message{To: "alice@example.com", Subject: "Hi", Body: "Hello\n"}
+
+    mailer := &SMTP{Addr: server.Addr(), Username: "blog", Password: "secret", From: "blog@example.com"}
+    if err := send(t, mailer, msg); err != nil {
+        t.Fatal(err)
+    }
+    if _, data := server.received(); data == nil {
+        t.Error("message wasn't sent")
+    }
+
+    mailer.Password = "wrong"
+    if err := send(t, mailer, msg); err == nil {
+        t.Error("sent with the wrong password")
+    }
+}
+
+func TestSMTPRejected(t *testing.T) {
+    server := newSMTPServer(t)
+    server.reject = "RCPT"
+
+    mailer := &SMTP{Addr: server.Addr(), From: "blog@example.com"}
+    if err := send(t, mailer, Message{To: "nobody@example.com", Subject: "Hi", Body: "Hello\n"}); err == nil {
+        t.Error("rejected recipient gave no error")
+    }
+    if _, data := server.received(); data != nil {
+        t.Error("message was sent to a rejected recipient")
+    }
+}
+
+func TestSMTPHeaderInjection(t *testing.T) {
+    server := newSMTPServer(t)
+    mailer := &SMTP{Addr: server.Addr(), From: "blog@example.com"}
+
+    msg := Message{To: "alice@example.com", Subject: "Hi\r\nBcc: mallory@example.com", Body: "Hello\n"}
+    if err := send(t, mailer, msg); err != nil {
+        t.Fatal(err)
+    }
+
+    _, data := server.received()
+    parsed, err := mail.ReadMessage(bytes.NewReader(data))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if bcc := parsed.Header.Get("Bcc"); bcc != "" {
+        t.Errorf("subject smuggled in Bcc: %s", bcc)
+    }
+}
+
+func TestSMTPTimeout(t *testing.T) {
+    // A server that accepts connections and never says a word.
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            defer conn.Close()
+        }
+    }()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+    defer cancel()
+    mailer := &SMTP{Addr: listener.Addr().String(), From: "blog@example.com"}
+    if err := mailer.Send(ctx, Message{To: "alice@example.com", Subject: "Hi", Body: "Hello\n"}); err == nil {
+        t.Error("silent server gave no error")
+    }
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited actions.
+const (
+    AuditLockout          = "login.lockout"
+    AuditUnlock           = "login.unlock"
+    AuditRecoveryCodeUsed = "login.recovery_code"
+    AuditTwoFactorOn      = "2fa.enable"
+    AuditTwoFactorOff     = "2fa.disable"
+    AuditRecoveryCodesNew = "2fa.recovery_codes"
+    AuditRequireTwoFactor = "2fa.require"
+    AuditPasswordChange   = "password.change"
+    AuditResetRequest     = "password.reset_request"
+    AuditPasswordReset    = "password.reset"
+    AuditEmailChange      = "account.email"
+    AuditTokenCreate      = "token.create"
+    AuditTokenRevoke      = "token.revoke"
+)
+
+// AuditEntry records something that happened to the security of the admin
+// area, such as a username being locked out.
+type AuditEntry struct {
+    Id      primitive.ObjectID `bson:"_id,omitempty"`
+    Time    time.Time          `bson:"time"`
+    Action  string             `bson:"action"`
+    // Actor is the user who did it, empty when the site did.
+    Actor   string             `bson:"actor,omitempty"`
+    // Subject is the username or address it was done to.
+    Subject string             `bson:"subject"`
+    IP      string             `bson:"ip"`
+    Detail  string             `bson:"detail,omitempty"`
+}
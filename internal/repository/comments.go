@@ -0,0 +1,29 @@
+package repository
+
+import (
+    "fmt"
+    "slices"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newComment fills in what every store sets on a comment it creates.
+func newComment(comment Comment) (Comment, error) {
+    if comment.Status == "" {
+        comment.Status = CommentPending
+    }
+    if err := checkCommentStatus(comment.Status); err != nil {
+        return comment, err
+    }
+
+    comment.Id = primitive.NewObjectID()
+    comment.Date = time.Now()
+    return comment, nil
+}
+
+func checkCommentStatus(status string) error {
+    if !slices.Contains(CommentStatuses, status) {
+        return fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+    }
+    return nil
+}
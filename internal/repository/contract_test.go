@@ -0,0 +1,424 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "sync"
+    "testing"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// contract is what every Store has to do the same way, whichever backend it
+// keeps its data in.
+var contract = []struct {
+    name string
+    test func(t *testing.T, s Store)
+}{
+    {"post lifecycle", testPostLifecycle},
+    {"query posts", testQueryPosts},
+    {"paging", testPaging},
+    {"reactions", testReactions},
+    {"reaction dedupe", testReactionDedupe},
+    {"publish due", testPublishDue},
+    {"invites", testInvites},
+    {"session expiry", testSessionExpiry},
+}
+
+func TestStoreContract(t *testing.T) {
+    for _, c := range contract {
+        t.Run(c.name, func(t *testing.T) {
+            forEachStore(t, c.test)
+        })
+    }
+}
+
+func createPost(t *testing.T, s Store, post Post) Post {
+    t.Helper()
+    post, err := s.CreatePost(context.Background(), post)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return post
+}
+
+func testPostLifecycle(t *testing.T, s Store) {
+    ctx := context.Background()
+
+    post := createPost(t, s, Post{Title: "Hello World", Body: "First", Tags: []string{"Go"}, Category: "Notes"})
+    if post.Id.IsZero() || post.Slug != "hello-world" || post.Status != StatusPublished {
+        t.Fatalf("created %+v, want an id, slug hello-world and published", post)
+    }
+    if post.Date.IsZero() || post.PublishAt.IsZero() {
+        t.Errorf("created post isn't dated: %+v", post)
+    }
+
+    got, err := s.GetPost(ctx, post.Id.Hex())
+    if err != nil || got.Title != "Hello World" || got.Body != "First" || got.Slug != "hello-world" {
+        t.Fatalf("GetPost = %+v, %v", got, err)
+    }
+
+    post.Title = "Hello Again"
+    post.Body = "Second"
+    updated, err := s.UpdatePost(ctx, post)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if updated.Slug != "hello-again" || len(updated.OldSlugs) != 1 || updated.OldSlugs[0] != "hello-world" {
+        t.Errorf("updated slugs %q, %q, want hello-again after hello-world", updated.Slug, updated.OldSlugs)
+    }
+    if updated.Body != "Second" || !updated.PublishAt.Equal(got.PublishAt) {
+        t.Errorf("updated %+v, want the new body and the first publish time", updated)
+    }
+    if got, err := s.GetPostBySlug(ctx, "hello-world"); err != nil || got.Id != post.Id {
+        t.Errorf("old slug found %+v, %v", got, err)
+    }
+
+    if err := s.DeletePost(ctx, post.Id.Hex()); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.GetPost(ctx, post.Id.Hex()); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetPost after deleting: %v, want ErrNotFound", err)
+    }
+    if err := s.DeletePost(ctx, post.Id.Hex()); !errors.Is(err, ErrNotFound) {
+        t.Errorf("deleting twice: %v, want ErrNotFound", err)
+    }
+    if _, err := s.UpdatePost(ctx, post); !errors.Is(err, ErrNotFound) {
+        t.Errorf("updating a deleted post: %v, want ErrNotFound", err)
+    }
+}
+
+func testQueryPosts(t *testing.T, s Store) {
+    ctx := context.Background()
+    now := time.Now()
+
+    generics := createPost(t, s, Post{Title: "Go generics", Tags: []string{"go"}, Category: "code", PublishAt: now.Add(-3 * time.Hour)})
+    createPost(t, s, Post{Title: "Go modules", Tags: []string{"go", "tools"}, PublishAt: now.Add(-2 * time.Hour)})
+    gardening := createPost(t, s, Post{Title: "Gardening", Tags: []string{"garden"}, Category: "life", PublishAt: now.Add(-time.Hour)})
+    createPost(t, s, Post{Title: "Go draft", Tags: []string{"go"}, Category: "code", Status: StatusDraft})
+
+    tests := []struct {
+        name  string
+        query PostQuery
+        want  []string
+    }{
+        {"everything", PostQuery{}, []string{"Go draft", "Gardening", "Go modules", "Go generics"}},
+        {"published", PublishedPosts, []string{"Gardening", "Go modules", "Go generics"}},
+        {"title prefix", PostQuery{TitlePrefix: "go "}, []string{"Go draft", "Go modules", "Go generics"}},
+        {"tag", PostQuery{Status: StatusPublished, Tag: "go"}, []string{"Go modules", "Go generics"}},
+        {"category", PostQuery{Category: "code"}, []string{"Go draft", "Go generics"}},
+        {"limit", PostQuery{Page: Page{Limit: 2}}, []string{"Go draft", "Gardening"}},
+        {"ids", PostQuery{Ids: []primitive.ObjectID{generics.Id, gardening.Id}}, []string{"Gardening", "Go generics"}},
+        {"ids and tag", PostQuery{Tag: "go", Ids: []primitive.ObjectID{generics.Id, gardening.Id}}, []string{"Go generics"}},
+        {"no ids", PostQuery{Ids: []primitive.ObjectID{}}, nil},
+        {"no match", PostQuery{Tag: "rust"}, nil},
+    }
+    for _, test := range tests {
+        posts, err := s.QueryPosts(ctx, test.query)
+        if err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+        if got := titles(posts); !slices.Equal(got, test.want) {
+            t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+        }
+    }
+}
+
+func testPaging(t *testing.T, s Store) {
+    ctx := context.Background()
+    // Mongo keeps dates to the millisecond.
+    date := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+    // Posts published at the same moment are told apart by their ids.
+    for i := 0; i < 5; i++ {
+        createPost(t, s, Post{Title: fmt.Sprintf("Tie %d", i), Tags: []string{"tie"}, Category: "ties", PublishAt: date})
+    }
+    for i := 0; i < 3; i++ {
+        createPost(t, s, Post{Title: fmt.Sprintf("Older %d", i), Category: "ties", PublishAt: date.Add(-time.Duration(i+1) * time.Minute)})
+    }
+
+    tests := []struct {
+        name  string
+        query PostQuery
+    }{
+        {"everything", PublishedPosts},
+        {"tag", PostQuery{Status: StatusPublished, Tag: "tie"}},
+        {"category", PostQuery{Status: StatusPublished, Category: "ties"}},
+    }
+    for _, test := range tests {
+        all, err := s.QueryPosts(ctx, test.query)
+        if err != nil {
+            t.Fatalf("%s: %v", test.name, err)
+        }
+
+        var paged []Post
+        q := test.query
+        q.Limit = 2
+        for pages := 0; ; pages++ {
+            if pages > len(all) {
+                t.Fatalf("%s: paging never ends", test.name)
+            }
+            page, err := s.QueryPosts(ctx, q)
+            if err != nil {
+                t.Fatalf("%s: %v", test.name, err)
+            }
+            if len(page) == 0 {
+                break
+            }
+            paged = append(paged, page...)
+            q.After = page[len(page)-1].Cursor()
+        }
+
+        if got, want := titles(paged), titles(all); !slices.Equal(got, want) {
+            t.Errorf("%s: paged through %q, want %q", test.name, got, want)
+        }
+    }
+}
+
+func titles(posts []Post) []string {
+    var titles []string
+    for _, p := range posts {
+        titles = append(titles, p.Title)
+    }
+    return titles
+}
+
+func testReactions(t *testing.T, s Store) {
+    ctx := context.Background()
+    post := createPost(t, s, Post{Title: "Reactions"})
+    since := time.Now().Add(-time.Hour)
+
+    react := func(visitor, emoji, ip string) (Post, error) {
+        return s.React(ctx, Reaction{PostId: post.Id, Visitor: visitor, Emoji: emoji, IPHash: ip, Date: time.Now()}, since)
+    }
+
+    if _, err := react("alice", LikeReaction, "ip1"); err != nil {
+        t.Fatal(err)
+    }
+    got, err := react("alice", "🎉", "ip1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Likes != 2 || got.Reactions[LikeReaction] != 1 || got.Reactions["🎉"] != 1 {
+        t.Errorf("after two reactions likes %d, reactions %v", got.Likes, got.Reactions)
+    }
+
+    got, err = s.Unreact(ctx, post.Id, "alice", "🎉")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Likes != 1 || got.Reactions["🎉"] != 0 {
+        t.Errorf("after unreacting likes %d, reactions %v", got.Likes, got.Reactions)
+    }
+
+    reactions, err := s.VisitorReactions(ctx, "alice")
+    if err != nil || len(reactions) != 1 || reactions[0].Emoji != LikeReaction {
+        t.Errorf("VisitorReactions = %v, %v, want the like", reactions, err)
+    }
+
+    if _, err := s.React(ctx, Reaction{PostId: primitive.NewObjectID(), Visitor: "alice", Emoji: LikeReaction}, since); !errors.Is(err, ErrNotFound) {
+        t.Errorf("reacting to a missing post: %v, want ErrNotFound", err)
+    }
+}
+
+func testReactionDedupe(t *testing.T, s Store) {
+    ctx := context.Background()
+    post := createPost(t, s, Post{Title: "Dedupe"})
+    since := time.Now().Add(-time.Hour)
+
+    react := func(visitor, emoji, ip string) error {
+        _, err := s.React(ctx, Reaction{PostId: post.Id, Visitor: visitor, Emoji: emoji, IPHash: ip, Date: time.Now()}, since)
+        return err
+    }
+    counts := func(step string, likes int, reactions map[string]int) {
+        t.Helper()
+        got, err := s.GetPost(ctx, post.Id.Hex())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got.Likes != likes {
+            t.Errorf("%s: likes %d, want %d", step, got.Likes, likes)
+        }
+        for emoji, want := range reactions {
+            if got.Reactions[emoji] != want {
+                t.Errorf("%s: %s counted %d, want %d", step, emoji, got.Reactions[emoji], want)
+            }
+        }
+    }
+
+    if err := react("alice", LikeReaction, "ip1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := react("alice", LikeReaction, "ip2"); !errors.Is(err, ErrAlreadyReacted) {
+        t.Errorf("reacting twice: %v, want ErrAlreadyReacted", err)
+    }
+    counts("after reacting twice", 1, map[string]int{LikeReaction: 1})
+
+    if err := react("bob", LikeReaction, "ip1"); !errors.Is(err, ErrAlreadyReacted) {
+        t.Errorf("a second visitor on the same address: %v, want ErrAlreadyReacted", err)
+    }
+    if err := react("bob", "🎉", "ip1"); err != nil {
+        t.Errorf("a second visitor on the same address with another emoji: %v", err)
+    }
+    counts("after a second visitor", 2, map[string]int{LikeReaction: 1, "🎉": 1})
+
+    for i := 0; i < 2; i++ {
+        if _, err := s.Unreact(ctx, post.Id, "alice", LikeReaction); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if _, err := s.Unreact(ctx, post.Id, "carol", "🎉"); err != nil {
+        t.Fatal(err)
+    }
+    counts("after unreacting twice and unreacting a stranger", 1, map[string]int{LikeReaction: 0, "🎉": 1})
+
+    if _, err := s.Unreact(ctx, post.Id, "bob", "🎉"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.Unreact(ctx, post.Id, "bob", "🎉"); err != nil {
+        t.Fatal(err)
+    }
+    counts("after everything is taken back", 0, map[string]int{LikeReaction: 0, "🎉": 0})
+
+    // The address only blocks reactions since the window started.
+    if err := react("alice", LikeReaction, "ip1"); err != nil {
+        t.Errorf("reacting again after unreacting: %v", err)
+    }
+    _, err := s.React(ctx, Reaction{PostId: post.Id, Visitor: "dave", Emoji: LikeReaction, IPHash: "ip1", Date: time.Now()}, time.Now().Add(time.Minute))
+    if err != nil {
+        t.Errorf("the same address after the window: %v", err)
+    }
+    counts("after reacting again", 2, map[string]int{LikeReaction: 2})
+
+    // Concurrent reactions are all counted.
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            if err := react(fmt.Sprintf("visitor%d", i), "🤔", fmt.Sprintf("ip-%d", i)); err != nil {
+                t.Error(err)
+            }
+        }(i)
+    }
+    wg.Wait()
+    counts("after concurrent reactions", 12, map[string]int{LikeReaction: 2, "🤔": 10})
+}
+
+func testPublishDue(t *testing.T, s Store) {
+    ctx := context.Background()
+    now := time.Now()
+
+    due := createPost(t, s, Post{Title: "Due", Status: StatusScheduled, PublishAt: now.Add(-time.Minute)})
+    later := createPost(t, s, Post{Title: "Later", Status: StatusScheduled, PublishAt: now.Add(time.Hour)})
+    createPost(t, s, Post{Title: "Draft", Status: StatusDraft})
+
+    published, err := s.PublishDue(ctx, now)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(published) != 1 || published[0].Id != due.Id {
+        t.Fatalf("published %q, want only Due", titles(published))
+    }
+
+    got, err := s.GetPost(ctx, due.Id.Hex())
+    if err != nil || got.Status != StatusPublished || !got.Date.Equal(got.PublishAt) {
+        t.Errorf("due post is %+v, %v, want published and dated when it went live", got, err)
+    }
+    if got, _ := s.GetPost(ctx, later.Id.Hex()); got.Status != StatusScheduled {
+        t.Errorf("later post is %s, want still scheduled", got.Status)
+    }
+
+    if published, err := s.PublishDue(ctx, now); err != nil || len(published) != 0 {
+        t.Errorf("publishing again published %q, %v", titles(published), err)
+    }
+}
+
+func testInvites(t *testing.T, s Store) {
+    ctx := context.Background()
+    now := time.Now()
+    owner := createUser(t, s, "owner")
+
+    invite, err := s.CreateInvite(ctx, Invite{TokenHash: "hash", Role: RoleAuthor, CreatedBy: owner.Id, Created: now, Expires: now.Add(time.Hour)})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.CreateInvite(ctx, Invite{TokenHash: "old", Role: RoleAuthor, CreatedBy: owner.Id, Created: now, Expires: now.Add(-time.Minute)}); err != nil {
+        t.Fatal(err)
+    }
+
+    alice, bob := primitive.NewObjectID(), primitive.NewObjectID()
+    used, err := s.UseInvite(ctx, "hash", alice, now)
+    if err != nil || used.Id != invite.Id || used.UsedBy != alice || used.UsedAt.IsZero() {
+        t.Fatalf("UseInvite = %+v, %v", used, err)
+    }
+    if _, err := s.UseInvite(ctx, "hash", bob, now); !errors.Is(err, ErrInvalidInvite) {
+        t.Errorf("using twice: %v, want ErrInvalidInvite", err)
+    }
+    if _, err := s.UseInvite(ctx, "old", bob, now); !errors.Is(err, ErrInvalidInvite) {
+        t.Errorf("using an expired invite: %v, want ErrInvalidInvite", err)
+    }
+    if _, err := s.UseInvite(ctx, "unknown", bob, now); !errors.Is(err, ErrInvalidInvite) {
+        t.Errorf("using an unknown invite: %v, want ErrInvalidInvite", err)
+    }
+
+    // Only the user who used an invite can give it back.
+    if err := s.ReleaseInvite(ctx, "hash", bob); !errors.Is(err, ErrNotFound) {
+        t.Errorf("releasing someone else's invite: %v, want ErrNotFound", err)
+    }
+    if err := s.ReleaseInvite(ctx, "hash", alice); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := s.GetInvite(ctx, "hash"); err != nil || !got.Usable(now) {
+        t.Errorf("released invite is %+v, %v, want usable", got, err)
+    }
+    if used, err := s.UseInvite(ctx, "hash", bob, now); err != nil || used.UsedBy != bob {
+        t.Errorf("using a released invite: %+v, %v", used, err)
+    }
+
+    if err := s.DeleteInvite(ctx, invite.Id); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.GetInvite(ctx, "hash"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("GetInvite after deleting: %v, want ErrNotFound", err)
+    }
+}
+
+func testSessionExpiry(t *testing.T, s Store) {
+    ctx := context.Background()
+    now := time.Now()
+    user := createUser(t, s, "alice")
+
+    for _, session := range []Session{
+        {UserId: user.Id, TokenHash: "expired", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)},
+        {UserId: user.Id, TokenHash: "live", Created: now, Expires: now.Add(time.Hour)},
+        {UserId: user.Id, TokenHash: "touched", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Minute)},
+    } {
+        if err := s.CreateSession(ctx, session); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // Using a session keeps it alive.
+    if err := s.TouchSession(ctx, "touched", now, now.Add(time.Hour)); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.TouchSession(ctx, "unknown", now, now.Add(time.Hour)); !errors.Is(err, ErrNotFound) {
+        t.Errorf("touching an unknown session: %v, want ErrNotFound", err)
+    }
+
+    deleted, err := s.DeleteExpiredSessions(ctx, now)
+    if err != nil || deleted != 1 {
+        t.Fatalf("DeleteExpiredSessions = %d, %v, want 1", deleted, err)
+    }
+    if _, err := s.GetSession(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expired session: %v, want ErrNotFound", err)
+    }
+    for _, token := range []string{"live", "touched"} {
+        if got, err := s.GetSession(ctx, token); err != nil || !got.Expires.After(now) {
+            t.Errorf("%s session is %+v, %v, want kept", token, got, err)
+        }
+    }
+}
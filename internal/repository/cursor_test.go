@@ -0,0 +1,64 @@
+package repository
+
+import (
+    "errors"
+    "testing"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+    c := Cursor{Date: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC), Id: primitive.NewObjectID()}
+
+    got, err := ParseCursor(c.String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !got.Date.Equal(c.Date) || got.Id != c.Id {
+        t.Errorf("ParseCursor(%q) = %v, want %v", c.String(), got, c)
+    }
+}
+
+func TestCursorPrecedes(t *testing.T) {
+    date := time.Now()
+    low, _ := primitive.ObjectIDFromHex("000000000000000000000001")
+    high, _ := primitive.ObjectIDFromHex("000000000000000000000002")
+
+    tests := []struct {
+        name string
+        a, b Cursor
+        want bool
+    }{
+        {"newer first", Cursor{Date: date, Id: low}, Cursor{Date: date.Add(-time.Second), Id: high}, true},
+        {"older after", Cursor{Date: date.Add(-time.Second), Id: high}, Cursor{Date: date, Id: low}, false},
+        {"tie, higher id first", Cursor{Date: date, Id: high}, Cursor{Date: date, Id: low}, true},
+        {"tie, lower id after", Cursor{Date: date, Id: low}, Cursor{Date: date, Id: high}, false},
+        {"itself", Cursor{Date: date, Id: low}, Cursor{Date: date, Id: low}, false},
+    }
+    for _, test := range tests {
+        if got := test.a.Precedes(test.b); got != test.want {
+            t.Errorf("%s: Precedes = %v, want %v", test.name, got, test.want)
+        }
+    }
+}
+
+func TestParseCursorTampered(t *testing.T) {
+    id := primitive.NewObjectID().Hex()
+
+    for _, s := range []string{
+        "",
+        "1714566600000000000",
+        "1714566600000000000.",
+        "yesterday." + id,
+        "1714566600000000000." + id[:20],
+        "1714566600000000000." + id + "00",
+        "1714566600000000000.zzzzzzzzzzzzzzzzzzzzzzzz",
+        "99999999999999999999." + id,
+    } {
+        _, err := ParseCursor(s)
+        var cursorErr *CursorError
+        if !errors.As(err, &cursorErr) || cursorErr.Cursor != s {
+            t.Errorf("ParseCursor(%q) = %v, want a CursorError", s, err)
+        }
+    }
+}
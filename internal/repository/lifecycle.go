@@ -0,0 +1,77 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "time"
+)
+
+var ErrInvalidStatus = errors.New("invalid status")
+
+// lifecycle works out the status, publish time and date of next, a post being
+// saved over stored (the zero Post for new ones). Publishing a post dates it
+// to when it went live, which may be backdated but never in the future.
+func lifecycle(stored, next Post, now time.Time) (Post, error) {
+    status := next.Status
+    if status == "" {
+        status = StatusPublished
+    }
+
+    if !slices.Contains(PostStatuses, status) {
+        return next, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+    }
+
+    next.Status = status
+    next.Updated = now
+    next.Date = stored.Date
+    if next.Date.IsZero() {
+        next.Date = now
+    }
+
+    switch status {
+    case StatusScheduled:
+        if next.PublishAt.IsZero() {
+            return next, fmt.Errorf("%w: scheduled posts need a publish time", ErrInvalidStatus)
+        }
+    case StatusPublished:
+        if stored.Status == StatusPublished && !stored.PublishAt.IsZero() {
+            next.PublishAt = stored.PublishAt
+            break
+        }
+
+        if next.PublishAt.IsZero() || next.PublishAt.After(now) {
+            next.PublishAt = now
+        }
+        next.Date = next.PublishAt
+    }
+
+    return next, nil
+}
+
+// BackfillPosts brings posts written before slugs and statuses existed up to
+// date: they get a slug and count as published since their original date.
+func BackfillPosts(ctx context.Context, posts PostStore) error {
+    all, err := posts.GetPosts(ctx)
+    if err != nil {
+        return err
+    }
+
+    for _, post := range all {
+        if post.Slug != "" && post.Status != "" {
+            continue
+        }
+
+        if post.Status == "" {
+            post.Status = StatusPublished
+            post.PublishAt = post.Date
+        }
+
+        if _, err := posts.UpdatePost(ctx, post); err != nil {
+            return fmt.Errorf("upgrading post %s: %w", post.Id.Hex(), err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,1054 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "maps"
+    "slices"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore keeps everything in process memory. It is meant for local
+// development and tests; all data is lost when the process exits.
+type MemoryStore struct {
+    lock      sync.RWMutex
+    posts     map[primitive.ObjectID]Post
+    comments  map[primitive.ObjectID]Comment
+    reactions map[reactionKey]Reaction
+    portfolio map[primitive.ObjectID]PortfolioEntry
+    users     map[primitive.ObjectID]User
+    invites   map[primitive.ObjectID]Invite
+    sessions  map[string]Session
+    audit     []AuditEntry
+    settings  SiteSettings
+    resets    map[string]PasswordReset
+    tokens    map[string]APIToken
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{
+        posts:     map[primitive.ObjectID]Post{},
+        comments:  map[primitive.ObjectID]Comment{},
+        reactions: map[reactionKey]Reaction{},
+        portfolio: map[primitive.ObjectID]PortfolioEntry{},
+        users:     map[primitive.ObjectID]User{},
+        invites:   map[primitive.ObjectID]Invite{},
+        sessions:  map[string]Session{},
+        resets:    map[string]PasswordReset{},
+        tokens:    map[string]APIToken{},
+    }
+}
+
+func (s *MemoryStore) Close(ctx context.Context) error {
+    return nil
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, user User) (User, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for _, u := range s.users {
+        if u.Username == user.Username {
+            return user, ErrDuplicate
+        }
+    }
+
+    if user.Id.IsZero() {
+        user.Id = primitive.NewObjectID()
+    }
+    s.users[user.Id] = user
+    return user, nil
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, id primitive.ObjectID) (User, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return User{}, ErrNotFound
+    }
+    return user, nil
+}
+
+func (s *MemoryStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    for _, u := range s.users {
+        if u.Username == username {
+            return u, nil
+        }
+    }
+    return User{}, ErrNotFound
+}
+
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    for _, u := range s.users {
+        if email != "" && u.Email == email {
+            return u, nil
+        }
+    }
+    return User{}, ErrNotFound
+}
+
+func (s *MemoryStore) ListUsers(ctx context.Context) ([]User, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    users := make([]User, 0, len(s.users))
+    for _, u := range s.users {
+        users = append(users, u)
+    }
+    slices.SortFunc(users, func(a, b User) int {
+        return strings.Compare(a.Username, b.Username)
+    })
+    return users, nil
+}
+
+func (s *MemoryStore) CountUsers(ctx context.Context) (int, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    return len(s.users), nil
+}
+
+func (s *MemoryStore) SetUserRole(ctx context.Context, id primitive.ObjectID, role string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    user.Role = role
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    user.Password = hash
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) SetUserEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    for _, u := range s.users {
+        if email != "" && u.Email == email && u.Id != id {
+            return ErrDuplicate
+        }
+    }
+    user.Email = email
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) CreatePasswordReset(ctx context.Context, reset PasswordReset) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for hash, r := range s.resets {
+        if r.UserId == reset.UserId {
+            delete(s.resets, hash)
+        }
+    }
+    if reset.Id.IsZero() {
+        reset.Id = primitive.NewObjectID()
+    }
+    s.resets[reset.TokenHash] = reset
+    return nil
+}
+
+func (s *MemoryStore) GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    reset, ok := s.resets[tokenHash]
+    if !ok {
+        return PasswordReset{}, ErrNotFound
+    }
+    return reset, nil
+}
+
+func (s *MemoryStore) UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (PasswordReset, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    reset, ok := s.resets[tokenHash]
+    if !ok || !reset.Usable(now) {
+        return PasswordReset{}, ErrInvalidReset
+    }
+    reset.UsedAt = now
+    s.resets[tokenHash] = reset
+    return reset, nil
+}
+
+func (s *MemoryStore) DeleteUserPasswordResets(ctx context.Context, user primitive.ObjectID) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for hash, r := range s.resets {
+        if r.UserId == user {
+            delete(s.resets, hash)
+        }
+    }
+    return nil
+}
+
+func (s *MemoryStore) SetTwoFactor(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    user.TOTPSecret = secret
+    user.TOTPStep = 0
+    user.RecoveryCodes = recoveryCodes
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, recoveryCodes []string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    user.RecoveryCodes = recoveryCodes
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    if user.TOTPStep >= step {
+        return ErrCodeUsed
+    }
+    user.TOTPStep = step
+    s.users[id] = user
+    return nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    user, ok := s.users[id]
+    if !ok {
+        return ErrNotFound
+    }
+    for i, code := range user.RecoveryCodes {
+        if code == hash {
+            user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+            s.users[id] = user
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (s *MemoryStore) GetSettings(ctx context.Context) (SiteSettings, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    return s.settings, nil
+}
+
+func (s *MemoryStore) SaveSettings(ctx context.Context, settings SiteSettings) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    s.settings = settings
+    return nil
+}
+
+func (s *MemoryStore) CreateInvite(ctx context.Context, invite Invite) (Invite, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    invite.Id = primitive.NewObjectID()
+    s.invites[invite.Id] = invite
+    return invite, nil
+}
+
+func (s *MemoryStore) Invites(ctx context.Context) ([]Invite, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    invites := make([]Invite, 0, len(s.invites))
+    for _, i := range s.invites {
+        invites = append(invites, i)
+    }
+    slices.SortFunc(invites, func(a, b Invite) int {
+        return b.Created.Compare(a.Created)
+    })
+    return invites, nil
+}
+
+func (s *MemoryStore) GetInvite(ctx context.Context, tokenHash string) (Invite, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    for _, invite := range s.invites {
+        if invite.TokenHash == tokenHash {
+            return invite, nil
+        }
+    }
+    return Invite{}, ErrNotFound
+}
+
+func (s *MemoryStore) UseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID, now time.Time) (Invite, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for id, invite := range s.invites {
+        if invite.TokenHash != tokenHash {
+            continue
+        }
+        if !invite.Usable(now) {
+            break
+        }
+
+        invite.UsedBy = user
+        invite.UsedAt = now
+        s.invites[id] = invite
+        return invite, nil
+    }
+    return Invite{}, ErrInvalidInvite
+}
+
+func (s *MemoryStore) ReleaseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for id, invite := range s.invites {
+        if invite.TokenHash == tokenHash && invite.UsedBy == user {
+            invite.UsedBy = primitive.ObjectID{}
+            invite.UsedAt = time.Time{}
+            s.invites[id] = invite
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (s *MemoryStore) DeleteInvite(ctx context.Context, id primitive.ObjectID) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.invites[id]; !ok {
+        return ErrNotFound
+    }
+    delete(s.invites, id)
+    return nil
+}
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session Session) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if session.Id.IsZero() {
+        session.Id = primitive.NewObjectID()
+    }
+    s.sessions[session.TokenHash] = session
+    return nil
+}
+
+func (s *MemoryStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    session, ok := s.sessions[tokenHash]
+    if !ok {
+        return Session{}, ErrNotFound
+    }
+    return session, nil
+}
+
+func (s *MemoryStore) TouchSession(ctx context.Context, tokenHash string, seen, expires time.Time) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    session, ok := s.sessions[tokenHash]
+    if !ok {
+        return ErrNotFound
+    }
+    session.LastSeen = seen
+    session.Expires = expires
+    s.sessions[tokenHash] = session
+    return nil
+}
+
+func (s *MemoryStore) UserSessions(ctx context.Context, user primitive.ObjectID) ([]Session, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var sessions []Session
+    for _, session := range s.sessions {
+        if session.UserId == user {
+            sessions = append(sessions, session)
+        }
+    }
+    slices.SortFunc(sessions, func(a, b Session) int {
+        return b.LastSeen.Compare(a.LastSeen)
+    })
+    return sessions, nil
+}
+
+func (s *MemoryStore) DeleteSession(ctx context.Context, tokenHash string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.sessions[tokenHash]; !ok {
+        return ErrNotFound
+    }
+    delete(s.sessions, tokenHash)
+    return nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, user, id primitive.ObjectID) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for token, session := range s.sessions {
+        if session.Id == id && session.UserId == user {
+            delete(s.sessions, token)
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (s *MemoryStore) DeleteUserSessions(ctx context.Context, user primitive.ObjectID) (int, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    deleted := 0
+    for token, session := range s.sessions {
+        if session.UserId == user {
+            delete(s.sessions, token)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+func (s *MemoryStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    deleted := 0
+    for token, session := range s.sessions {
+        if session.Expires.Before(now) {
+            delete(s.sessions, token)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+func (s *MemoryStore) CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.tokens[token.TokenHash]; ok {
+        return token, ErrDuplicate
+    }
+    if token.Id.IsZero() {
+        token.Id = primitive.NewObjectID()
+    }
+    token.Scopes = slices.Clone(token.Scopes)
+    s.tokens[token.TokenHash] = token
+    return token, nil
+}
+
+func (s *MemoryStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    token, ok := s.tokens[tokenHash]
+    if !ok {
+        return APIToken{}, ErrNotFound
+    }
+    return token, nil
+}
+
+func (s *MemoryStore) UserAPITokens(ctx context.Context, user primitive.ObjectID) ([]APIToken, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var tokens []APIToken
+    for _, token := range s.tokens {
+        if token.UserId == user {
+            tokens = append(tokens, token)
+        }
+    }
+    slices.SortFunc(tokens, func(a, b APIToken) int {
+        if c := b.Created.Compare(a.Created); c != 0 {
+            return c
+        }
+        return strings.Compare(b.Id.Hex(), a.Id.Hex())
+    })
+    return tokens, nil
+}
+
+func (s *MemoryStore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, when time.Time, ip string) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for hash, token := range s.tokens {
+        if token.Id == id {
+            token.LastUsed = when
+            token.LastIP = ip
+            s.tokens[hash] = token
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (s *MemoryStore) RevokeAPIToken(ctx context.Context, user, id primitive.ObjectID) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    for hash, token := range s.tokens {
+        if token.Id == id && token.UserId == user {
+            delete(s.tokens, hash)
+            return nil
+        }
+    }
+    return ErrNotFound
+}
+
+func (s *MemoryStore) Audit(ctx context.Context, entry AuditEntry) error {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    entry.Id = primitive.NewObjectID()
+    s.audit = append(s.audit, entry)
+    return nil
+}
+
+func (s *MemoryStore) AuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var entries []AuditEntry
+    for i := len(s.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+        entries = append(entries, s.audit[i])
+    }
+    return entries, nil
+}
+
+func (s *MemoryStore) CreatePost(ctx context.Context, post Post) (Post, error) {
+    post, err := lifecycle(Post{}, taxonomy(post), time.Now())
+    if err != nil {
+        return post, err
+    }
+    post.Id = primitive.NewObjectID()
+    post.OldSlugs = nil
+
+    err = retrySlug(func() error {
+        slug, err := uniqueSlug(ctx, s, post.Title, post.Id)
+        if err != nil {
+            return err
+        }
+        post.Slug = slug
+
+        s.lock.Lock()
+        defer s.lock.Unlock()
+
+        if s.slugTaken(slug, post.Id) {
+            return ErrDuplicate
+        }
+        s.posts[post.Id] = post
+        return nil
+    })
+    return post, err
+}
+
+// slugTaken tells whether a post other than id has slug, the way a unique
+// index on slugs would.
+func (s *MemoryStore) slugTaken(slug string, id primitive.ObjectID) bool {
+    for _, post := range s.posts {
+        if post.Slug == slug && post.Id != id {
+            return true
+        }
+    }
+    return false
+}
+
+func (s *MemoryStore) GetPosts(ctx context.Context) ([]Post, error) {
+    return s.QueryPosts(ctx, PostQuery{})
+}
+
+func (s *MemoryStore) QueryPosts(ctx context.Context, q PostQuery) ([]Post, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var posts []Post
+    for _, p := range s.posts {
+        if matches(p, q) {
+            posts = append(posts, p)
+        }
+    }
+
+    sortPosts(posts)
+    return limit(posts, q.Limit), nil
+}
+
+// limit cuts items down to n, if n is set.
+func limit[T any](items []T, n int) []T {
+    if n > 0 && len(items) > n {
+        return items[:n]
+    }
+    return items
+}
+
+func matches(p Post, q PostQuery) bool {
+    if !q.After.IsZero() && !q.After.Precedes(p.Cursor()) {
+        return false
+    }
+    if q.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(p.Title), strings.ToLower(q.TitlePrefix)) {
+        return false
+    }
+    if q.Status != "" && p.Status != q.Status {
+        return false
+    }
+    if q.Tag != "" && !slices.Contains(p.Tags, q.Tag) {
+        return false
+    }
+    if q.Category != "" && p.Category != q.Category {
+        return false
+    }
+    if q.Ids != nil && !slices.Contains(q.Ids, p.Id) {
+        return false
+    }
+    return true
+}
+
+func (s *MemoryStore) TagCounts(ctx context.Context, q PostQuery) ([]TagCount, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    counts := map[string]int{}
+    for _, p := range s.posts {
+        if !matches(p, q) {
+            continue
+        }
+        for _, tag := range p.Tags {
+            counts[tag]++
+        }
+    }
+
+    var tags []TagCount
+    for tag, count := range counts {
+        tags = append(tags, TagCount{Tag: tag, Count: count})
+    }
+
+    sortTagCounts(tags)
+    return tags, nil
+}
+
+// sortPosts orders posts newest first, the same order the Mongo store uses.
+func sortPosts(posts []Post) {
+    sort.Slice(posts, func(i, j int) bool {
+        return posts[i].Cursor().Precedes(posts[j].Cursor())
+    })
+}
+
+func (s *MemoryStore) GetPost(ctx context.Context, id string) (Post, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return Post{}, err
+    }
+
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    post, ok := s.posts[objectId]
+    if !ok {
+        return Post{}, ErrNotFound
+    }
+    return post, nil
+}
+
+func (s *MemoryStore) GetPostBySlug(ctx context.Context, slug string) (Post, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    for _, post := range s.posts {
+        if post.Slug == slug || slices.Contains(post.OldSlugs, slug) {
+            return post, nil
+        }
+    }
+    return Post{}, ErrNotFound
+}
+
+func (s *MemoryStore) UpdatePost(ctx context.Context, p Post) (Post, error) {
+    stored, err := s.GetPost(ctx, p.Id.Hex())
+    if err != nil {
+        return Post{}, err
+    }
+
+    p, err = lifecycle(stored, taxonomy(p), time.Now())
+    if err != nil {
+        return Post{}, err
+    }
+
+    var post Post
+    err = retrySlug(func() error {
+        slug, oldSlugs, err := reslug(ctx, s, stored, p.Title)
+        if err != nil {
+            return err
+        }
+
+        s.lock.Lock()
+        defer s.lock.Unlock()
+
+        var ok bool
+        if post, ok = s.posts[p.Id]; !ok {
+            return ErrNotFound
+        }
+        if s.slugTaken(slug, p.Id) {
+            return ErrDuplicate
+        }
+
+        post.Slug = slug
+        post.OldSlugs = oldSlugs
+        post.Status = p.Status
+        post.PublishAt = p.PublishAt
+        post.Date = p.Date
+        post.Updated = p.Updated
+        post.Title = p.Title
+        post.Body = p.Body
+        post.Synopsys = p.Synopsys
+        post.CoverImage = p.CoverImage
+        post.Tags = p.Tags
+        post.Category = p.Category
+        post.SEO = p.SEO
+        s.posts[post.Id] = post
+        return nil
+    })
+    if err != nil {
+        return Post{}, err
+    }
+
+    return post, nil
+}
+
+func (s *MemoryStore) DeletePost(ctx context.Context, id string) error {
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.posts[objectID]; !ok {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    delete(s.posts, objectID)
+    for id, c := range s.comments {
+        if c.PostId == objectID {
+            delete(s.comments, id)
+        }
+    }
+    for key := range s.reactions {
+        if key.postId == objectID {
+            delete(s.reactions, key)
+        }
+    }
+    return nil
+}
+
+type reactionKey struct {
+    postId  primitive.ObjectID
+    visitor string
+    emoji   string
+}
+
+func (s *MemoryStore) React(ctx context.Context, reaction Reaction, since time.Time) (Post, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    post, ok := s.posts[reaction.PostId]
+    if !ok {
+        return Post{}, ErrNotFound
+    }
+
+    key := reactionKey{reaction.PostId, reaction.Visitor, reaction.Emoji}
+    if _, ok := s.reactions[key]; ok {
+        return post, ErrAlreadyReacted
+    }
+    for _, r := range s.reactions {
+        if r.PostId == reaction.PostId && r.Emoji == reaction.Emoji && r.IPHash == reaction.IPHash && !r.Date.Before(since) {
+            return post, ErrAlreadyReacted
+        }
+    }
+
+    s.reactions[key] = reaction
+    s.posts[post.Id] = addReaction(post, reaction.Emoji, 1)
+    return s.posts[post.Id], nil
+}
+
+func (s *MemoryStore) Unreact(ctx context.Context, postId primitive.ObjectID, visitor, emoji string) (Post, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    post, ok := s.posts[postId]
+    if !ok {
+        return Post{}, ErrNotFound
+    }
+
+    key := reactionKey{postId, visitor, emoji}
+    if _, ok := s.reactions[key]; ok {
+        delete(s.reactions, key)
+        post = addReaction(post, emoji, -1)
+        s.posts[postId] = post
+    }
+    return post, nil
+}
+
+// addReaction counts delta more reactions with emoji on post. The counts are
+// copied, since posts handed out before share them.
+func addReaction(post Post, emoji string, delta int) Post {
+    post.Reactions = maps.Clone(post.Reactions)
+    if post.Reactions == nil {
+        post.Reactions = map[string]int{}
+    }
+    post.Reactions[emoji] = max(post.Reactions[emoji]+delta, 0)
+    post.Likes = max(post.Likes+delta, 0)
+    return post
+}
+
+func (s *MemoryStore) VisitorReactions(ctx context.Context, visitor string) ([]Reaction, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var reactions []Reaction
+    for key, reaction := range s.reactions {
+        if key.visitor == visitor {
+            reactions = append(reactions, reaction)
+        }
+    }
+    return reactions, nil
+}
+
+func (s *MemoryStore) PublishDue(ctx context.Context, now time.Time) ([]Post, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    var published []Post
+    for id, post := range s.posts {
+        if post.Status != StatusScheduled || post.PublishAt.After(now) {
+            continue
+        }
+
+        post.Status = StatusPublished
+        post.Date = post.PublishAt
+        post.Updated = now
+        s.posts[id] = post
+        published = append(published, post)
+    }
+
+    return published, nil
+}
+
+func (s *MemoryStore) CreateComment(ctx context.Context, comment Comment) (Comment, error) {
+    comment, err := newComment(comment)
+    if err != nil {
+        return comment, err
+    }
+
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.posts[comment.PostId]; !ok {
+        return comment, ErrNotFound
+    }
+
+    s.comments[comment.Id] = comment
+    s.recountComments(comment.PostId)
+    return comment, nil
+}
+
+func (s *MemoryStore) GetComment(ctx context.Context, id primitive.ObjectID) (Comment, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    comment, ok := s.comments[id]
+    if !ok {
+        return Comment{}, ErrNotFound
+    }
+    return comment, nil
+}
+
+func (s *MemoryStore) QueryComments(ctx context.Context, q CommentQuery) ([]Comment, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var comments []Comment
+    for _, c := range s.comments {
+        if !q.PostId.IsZero() && c.PostId != q.PostId {
+            continue
+        }
+        if q.Status != "" && c.Status != q.Status {
+            continue
+        }
+        comments = append(comments, c)
+    }
+
+    sort.Slice(comments, func(i, j int) bool {
+        return comments[j].Date.After(comments[i].Date)
+    })
+    return comments, nil
+}
+
+func (s *MemoryStore) SetCommentStatus(ctx context.Context, id primitive.ObjectID, status string) (Comment, error) {
+    if err := checkCommentStatus(status); err != nil {
+        return Comment{}, err
+    }
+
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    comment, ok := s.comments[id]
+    if !ok {
+        return Comment{}, ErrNotFound
+    }
+
+    comment.Status = status
+    comment.ModeratedAt = time.Now()
+    s.comments[id] = comment
+    s.recountComments(comment.PostId)
+    return comment, nil
+}
+
+// recountComments sets the comment count of a post to its number of approved
+// comments. The lock must be held.
+func (s *MemoryStore) recountComments(postId primitive.ObjectID) {
+    post, ok := s.posts[postId]
+    if !ok {
+        return
+    }
+
+    post.Comments = 0
+    for _, c := range s.comments {
+        if c.PostId == postId && c.Status == CommentApproved {
+            post.Comments++
+        }
+    }
+    s.posts[postId] = post
+}
+
+func (s *MemoryStore) GetPortfolioEntries(ctx context.Context, page Page) ([]PortfolioEntry, error) {
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    var entries []PortfolioEntry
+    for _, e := range s.portfolio {
+        if page.After.IsZero() || page.After.Precedes(e.Cursor()) {
+            entries = append(entries, e)
+        }
+    }
+
+    sort.Slice(entries, func(i, j int) bool {
+        return entries[i].Cursor().Precedes(entries[j].Cursor())
+    })
+    return limit(entries, page.Limit), nil
+}
+
+func (s *MemoryStore) GetEntry(ctx context.Context, id string) (PortfolioEntry, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return PortfolioEntry{}, err
+    }
+
+    s.lock.RLock()
+    defer s.lock.RUnlock()
+
+    entry, ok := s.portfolio[objectId]
+    if !ok {
+        return PortfolioEntry{}, ErrNotFound
+    }
+    return entry, nil
+}
+
+func (s *MemoryStore) CreatePortfolioEntry(ctx context.Context, entry PortfolioEntry) (PortfolioEntry, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    entry.Id = primitive.NewObjectID()
+    entry.Date = time.Now()
+    s.portfolio[entry.Id] = entry
+    return entry, nil
+}
+
+func (s *MemoryStore) UpdateEntry(ctx context.Context, e PortfolioEntry) (PortfolioEntry, error) {
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    entry, ok := s.portfolio[e.Id]
+    if !ok {
+        return PortfolioEntry{}, ErrNotFound
+    }
+
+    entry.Title = e.Title
+    entry.Repo = e.Repo
+    entry.Url = e.Url
+    entry.CoverImage = e.CoverImage
+    s.portfolio[entry.Id] = entry
+
+    return entry, nil
+}
+
+func (s *MemoryStore) DeleteEntry(ctx context.Context, id string) error {
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    s.lock.Lock()
+    defer s.lock.Unlock()
+
+    if _, ok := s.portfolio[objectID]; !ok {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    delete(s.portfolio, objectID)
+    return nil
+}
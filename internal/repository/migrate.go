@@ -0,0 +1,162 @@
+package repository
+
+import (
+    "context"
+    "fmt"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrationStats counts the records copied from each collection.
+type MigrationStats struct {
+    Posts     int
+    Comments  int
+    Reactions int
+    Portfolio int
+    Users     int
+    Invites   int
+    Sessions  int
+    Audit     int
+    Tokens    int
+}
+
+func (s MigrationStats) String() string {
+    return fmt.Sprintf("%d posts, %d comments, %d reactions, %d portfolio entries, %d users, %d invites, %d sessions, %d audit entries, %d API tokens",
+        s.Posts, s.Comments, s.Reactions, s.Portfolio, s.Users, s.Invites, s.Sessions, s.Audit, s.Tokens)
+}
+
+// CopyMongoToSQLite copies every record of the Mongo database into dst. Ids
+// are kept so existing links keep working, and records that already exist in
+// dst are overwritten, which makes it safe to run again.
+func CopyMongoToSQLite(ctx context.Context, src *MongoStore, dst *SQLiteStore) (MigrationStats, error) {
+    var stats MigrationStats
+
+    var posts []Post
+    if err := readAll(ctx, src.db.Collection(posts_col), &posts); err != nil {
+        return stats, fmt.Errorf("reading posts: %w", err)
+    }
+    for _, post := range posts {
+        if err := dst.insertPost(ctx, post); err != nil {
+            return stats, fmt.Errorf("copying post %s: %w", post.Id.Hex(), err)
+        }
+        stats.Posts++
+    }
+
+    var comments []Comment
+    if err := readAll(ctx, src.db.Collection(comments_col), &comments); err != nil {
+        return stats, fmt.Errorf("reading comments: %w", err)
+    }
+    for _, comment := range comments {
+        if err := insertComment(ctx, dst.db, comment); err != nil {
+            return stats, fmt.Errorf("copying comment %s: %w", comment.Id.Hex(), err)
+        }
+        stats.Comments++
+    }
+
+    var reactions []Reaction
+    if err := readAll(ctx, src.db.Collection(reactions_col), &reactions); err != nil {
+        return stats, fmt.Errorf("reading reactions: %w", err)
+    }
+    for _, reaction := range reactions {
+        if _, err := insertReaction(ctx, dst.db, reaction); err != nil {
+            return stats, fmt.Errorf("copying reaction to post %s: %w", reaction.PostId.Hex(), err)
+        }
+        stats.Reactions++
+    }
+
+    var entries []PortfolioEntry
+    if err := readAll(ctx, src.db.Collection(portfolio_col), &entries); err != nil {
+        return stats, fmt.Errorf("reading portfolio: %w", err)
+    }
+    for _, entry := range entries {
+        if err := dst.insertEntry(ctx, entry); err != nil {
+            return stats, fmt.Errorf("copying portfolio entry %s: %w", entry.Id.Hex(), err)
+        }
+        stats.Portfolio++
+    }
+
+    var users []User
+    if err := readAll(ctx, src.db.Collection(users_col), &users); err != nil {
+        return stats, fmt.Errorf("reading users: %w", err)
+    }
+    for _, user := range users {
+        _, err := dst.db.ExecContext(ctx,
+            "INSERT OR REPLACE INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)", userValues(user)...)
+        if err != nil {
+            return stats, fmt.Errorf("copying user %s: %w", user.Username, err)
+        }
+        stats.Users++
+    }
+
+    var invites []Invite
+    if err := readAll(ctx, src.db.Collection(invites_col), &invites); err != nil {
+        return stats, fmt.Errorf("reading invites: %w", err)
+    }
+    for _, invite := range invites {
+        if err := insertInvite(ctx, dst.db, invite); err != nil {
+            return stats, fmt.Errorf("copying invite %s: %w", invite.Id.Hex(), err)
+        }
+        stats.Invites++
+    }
+
+    var sessions []Session
+    if err := readAll(ctx, src.db.Collection(sessions_col), &sessions); err != nil {
+        return stats, fmt.Errorf("reading sessions: %w", err)
+    }
+    for _, session := range sessions {
+        if err := insertSession(ctx, dst.db, session); err != nil {
+            return stats, fmt.Errorf("copying session: %w", err)
+        }
+        stats.Sessions++
+    }
+
+    var audit []AuditEntry
+    if err := readAll(ctx, src.db.Collection(audit_col), &audit); err != nil {
+        return stats, fmt.Errorf("reading audit log: %w", err)
+    }
+    for _, entry := range audit {
+        if err := insertAuditEntry(ctx, dst.db, entry); err != nil {
+            return stats, fmt.Errorf("copying audit entry %s: %w", entry.Id.Hex(), err)
+        }
+        stats.Audit++
+    }
+
+    var resets []PasswordReset
+    if err := readAll(ctx, src.db.Collection(resets_col), &resets); err != nil {
+        return stats, fmt.Errorf("reading password resets: %w", err)
+    }
+    for _, reset := range resets {
+        if err := insertPasswordReset(ctx, dst.db, reset); err != nil {
+            return stats, fmt.Errorf("copying password reset %s: %w", reset.Id.Hex(), err)
+        }
+    }
+
+    var tokens []APIToken
+    if err := readAll(ctx, src.db.Collection(tokens_col), &tokens); err != nil {
+        return stats, fmt.Errorf("reading API tokens: %w", err)
+    }
+    for _, token := range tokens {
+        if err := insertAPIToken(ctx, dst.db, token); err != nil {
+            return stats, fmt.Errorf("copying API token %s: %w", token.Id.Hex(), err)
+        }
+        stats.Tokens++
+    }
+
+    settings, err := src.GetSettings(ctx)
+    if err != nil {
+        return stats, fmt.Errorf("reading settings: %w", err)
+    }
+    if err := saveSettings(ctx, dst.db, settings); err != nil {
+        return stats, fmt.Errorf("copying settings: %w", err)
+    }
+
+    return stats, nil
+}
+
+func readAll(ctx context.Context, collection *mongo.Collection, v interface{}) error {
+    cur, err := collection.Find(ctx, bson.D{})
+    if err != nil {
+        return err
+    }
+    return cur.All(ctx, v)
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+    "fmt"
+    "html/template"
+    "net/url"
+    "strings"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "golang.org/x/crypto/bcrypt"
+)
+
+type PageData struct {
+	Content template.HTML
+	Version string
+	// CSRF is the token the page's requests carry, see api.protectCSRF.
+	CSRF    string
+	// Meta describes the page to search engines and link previews.
+	Meta    PageMeta
+	// User is who is signed in, on admin pages.
+	User    User
+}
+
+// PageMeta is what a page's head tells search engines and the sites showing
+// previews of links to it. Its URLs are absolute.
+type PageMeta struct {
+    Title       string
+    Description string
+    Canonical   string
+    Image       string
+    NoIndex     bool
+    SiteName    string
+    // Type is the page's OpenGraph type, "website" or "article".
+    Type        string
+    Published   time.Time
+    Modified    time.Time
+    Tags        []string
+    // LinkedData are schema.org objects, written out as JSON-LD.
+    LinkedData  []any
+}
+
+type PortfolioEntry struct {
+    Id         primitive.ObjectID `bson:"_id,omitempty"`
+    Title      string             `bson:"title,omitempty"`
+    Date       time.Time          `bson:"date"`
+    Repo       string             `bson:"repo"`
+    Url        string             `bson:"url"`
+    CoverImage string             `bson:"coverimage"`
+}
+
+func (e PortfolioEntry) Cursor() Cursor {
+    return Cursor{Date: e.Date, Id: e.Id}
+}
+
+type User struct {
+    Id       primitive.ObjectID `bson:"_id,omitempty"`
+    Username string             `bson:"username"`
+    Password string             `bson:"password"`
+    // Role is one of Roles and decides what the user may do in the admin.
+    Role     string             `bson:"role"`
+    // Email is where password reset links are sent, empty if the user
+    // hasn't given one. It is stored lower case.
+    Email    string             `bson:"email,omitempty"`
+    // TOTPSecret is the base32 secret of the user's authenticator app, empty
+    // unless they turned on two-factor authentication.
+    TOTPSecret string `bson:"totp_secret,omitempty"`
+    // TOTPStep is the time step of the last code accepted, so that no code
+    // is accepted twice.
+    TOTPStep int64 `bson:"totp_step,omitempty"`
+    // RecoveryCodes are the hashes of the codes the user has left to sign
+    // in with when they don't have their app.
+    RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+}
+
+// HashPassword replaces the plain text password with its bcrypt hash of the
+// given cost.
+func (u *User) HashPassword(cost int) error {
+    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), cost)
+    if err != nil {
+        return err
+    }
+    u.Password = string(hashedPassword)
+    return nil
+}
+
+func (u *User) CheckPassword(password string) bool {
+    err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+    return err == nil
+}
+
+// NeedsRehash tells whether the password was hashed with a lower cost than
+// cost, as passwords from before the cost was raised are.
+func (u User) NeedsRehash(cost int) bool {
+    current, err := bcrypt.Cost([]byte(u.Password))
+    return err == nil && current < cost
+}
+
+// Session is a browser signed in as a user. Its token is the secret kept in
+// the browser's cookie, of which only the HashToken hash is stored; its id
+// names it on the sessions page.
+type Session struct {
+    Id        primitive.ObjectID `bson:"_id,omitempty"`
+    UserId    primitive.ObjectID `bson:"user_id"`
+    TokenHash string             `bson:"token"`
+    Created   time.Time          `bson:"created"`
+    Expires   time.Time          `bson:"expires"`
+    LastSeen  time.Time          `bson:"last_seen"`
+    UserAgent string             `bson:"user_agent"`
+    IP        string             `bson:"ip"`
+}
+
+type Post struct{
+    Id         primitive.ObjectID `bson:"_id,omitempty"`
+    Title      string             `bson:"title,omitempty"`
+    Body       string             `bson:"body,omitempty"`
+    Date       time.Time          `bson:"date"`
+    Synopsys   string             `bson:"synopsys"`
+    // Likes counts every reaction to the post, whichever it is.
+    Likes      int                `bson:"likes"`
+    Reactions  map[string]int     `bson:"reactions,omitempty"`
+    Comments   int                `bson:"comments"`
+    CoverImage string             `bson:"coverimage"`
+    Slug       string             `bson:"slug"`
+    // OldSlugs are the slugs the post had before its title was edited.
+    OldSlugs   []string           `bson:"oldslugs,omitempty"`
+    Status     string             `bson:"status"`
+    // PublishAt is when a scheduled post goes live, or when a published one
+    // did.
+    PublishAt  time.Time          `bson:"publishat"`
+    // Updated is when the post was last saved or published, zero for posts
+    // not saved since it started being kept.
+    Updated    time.Time          `bson:"updated,omitempty"`
+    Tags       []string           `bson:"tags,omitempty"`
+    Category   string             `bson:"category,omitempty"`
+    SEO        PostSEO            `bson:"seo"`
+    // AuthorId is the user who wrote the post, zero for posts written before
+    // authors were recorded.
+    AuthorId   primitive.ObjectID `bson:"author_id,omitempty"`
+    // ReactionBar is the reactions offered on the post as the visitor
+    // reading the page sees them. It is filled in for each request and never
+    // stored.
+    ReactionBar []ReactionButton  `bson:"-"`
+}
+
+// PostSEO overrides how a post is described to search engines and in link
+// previews. Empty fields fall back to the post's own title and synopsis, and
+// to its permalink as the canonical URL.
+type PostSEO struct {
+    Title       string `bson:"title,omitempty"`
+    Description string `bson:"description,omitempty"`
+    Canonical   string `bson:"canonical,omitempty"`
+    // NoIndex asks search engines to leave the post out of their results.
+    NoIndex     bool   `bson:"noindex,omitempty"`
+}
+
+const (
+    StatusDraft     = "draft"
+    StatusScheduled = "scheduled"
+    StatusPublished = "published"
+    StatusArchived  = "archived"
+)
+
+// PostStatuses lists every status in the order the dashboard offers them.
+var PostStatuses = []string{StatusDraft, StatusScheduled, StatusPublished, StatusArchived}
+
+func (p Post) IsPublished() bool {
+    return p.Status == StatusPublished
+}
+
+// PublishAtInput formats PublishAt for an <input type="datetime-local">.
+func (p Post) PublishAtInput() string {
+    if p.PublishAt.IsZero() {
+        return ""
+    }
+    return p.PublishAt.Local().Format("2006-01-02T15:04")
+}
+
+// MetaTitle is the post's title for search engines and link previews.
+func (p Post) MetaTitle() string {
+    if p.SEO.Title != "" {
+        return p.SEO.Title
+    }
+    return p.Title
+}
+
+// MetaDescription is the post's description for search engines and link
+// previews.
+func (p Post) MetaDescription() string {
+    if p.SEO.Description != "" {
+        return p.SEO.Description
+    }
+    return p.Synopsys
+}
+
+// LastModified is when the post last changed, as far as is known.
+func (p Post) LastModified() time.Time {
+    if p.Updated.After(p.Date) {
+        return p.Updated
+    }
+    return p.Date
+}
+
+func (p Post) MainFormatDate() string {
+    return p.Date.Format(time.DateOnly)
+} 
+
+// Permalink is the canonical path of the post, e.g. /blog/2026/10/my-post.
+func (p Post) Permalink() string {
+    return fmt.Sprintf("/blog/%04d/%02d/%s", p.Date.Year(), p.Date.Month(), url.PathEscape(p.Slug))
+}
+
+// TagsInput formats Tags for the comma separated tags field of the post form.
+func (p Post) TagsInput() string {
+    return strings.Join(p.Tags, ", ")
+}
+
+// CategoryPath is the path of the listing of every post in the category.
+func (p Post) CategoryPath() string {
+    return "/category/" + url.PathEscape(p.Category)
+}
+
+func (p Post) Cursor() Cursor {
+    return Cursor{Date: p.Date, Id: p.Id}
+}
+
+// Reaction records that a visitor reacted to a post with an emoji. Visitor
+// is the id in their anonymous cookie and IPHash a salted hash of the address
+// they reacted from.
+type Reaction struct {
+    PostId  primitive.ObjectID `bson:"post_id"`
+    Visitor string             `bson:"visitor"`
+    Emoji   string             `bson:"emoji"`
+    IPHash  string             `bson:"ip_hash"`
+    Date    time.Time          `bson:"date"`
+}
+
+// LikeReaction is the reaction likes from before reactions existed became.
+const LikeReaction = "\u2764\ufe0f"
+
+// ReactionButton is one reaction in a post's reaction bar. Mine is set when
+// the visitor looking at it reacted so.
+type ReactionButton struct {
+    Emoji string
+    Count int
+    Mine  bool
+}
+
+// Comment is a reader's comment on a post. Replies point at the comment they
+// answer with ParentId, which is zero for top level comments. ModeratedAt is
+// when an admin last set its status, zero while nobody has.
+type Comment struct {
+    Id          primitive.ObjectID `bson:"_id,omitempty"`
+    PostId      primitive.ObjectID `bson:"post_id"`
+    ParentId    primitive.ObjectID `bson:"parent_id"`
+    Name        string             `bson:"name"`
+    Email       string             `bson:"email"`
+    Body        string             `bson:"body"`
+    Date        time.Time          `bson:"date"`
+    Status      string             `bson:"status"`
+    Verdict     Verdict            `bson:"verdict"`
+    ModeratedAt time.Time          `bson:"moderated_at,omitempty"`
+}
+
+// Verdict is what the spam checks made of a comment when it was submitted.
+// Score goes from 0, surely fine, to 1, surely spam.
+type Verdict struct {
+    Score   float64  `bson:"score"`
+    Reasons []string `bson:"reasons,omitempty"`
+}
+
+// Percent is the score as a whole percentage, for showing.
+func (v Verdict) Percent() int {
+    return int(v.Score*100 + 0.5)
+}
+
+// Comments wait for moderation; only approved ones are shown and counted in
+// Post.Comments.
+const (
+    CommentPending  = "pending"
+    CommentApproved = "approved"
+    CommentRejected = "rejected"
+    CommentSpam     = "spam"
+)
+
+var CommentStatuses = []string{CommentPending, CommentApproved, CommentRejected, CommentSpam}
+
+func (c Comment) IsReply() bool {
+    return !c.ParentId.IsZero()
+}
+
+func (c Comment) MainFormatDate() string {
+    return c.Date.Format(time.DateOnly)
+}
@@ -0,0 +1,1462 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const posts_col string = "posts"
+const portfolio_col string = "portfolio"
+const users_col string = "users"
+const sessions_col string = "sessions"
+const invites_col string = "invites"
+const comments_col string = "comments"
+const reactions_col string = "reactions"
+const audit_col string = "audit"
+const settings_col string = "settings"
+const resets_col string = "password_resets"
+const tokens_col string = "api_tokens"
+
+// siteSettingsId is the _id of the one document in settings_col.
+const siteSettingsId = "site"
+
+// likes_col held the likes recorded before reactions replaced them.
+const likes_col string = "likes"
+
+type MongoStore struct {
+    client *mongo.Client
+    db     *mongo.Database
+}
+
+func NewMongoStore(ctx context.Context, uri string, database string) (*MongoStore, error) {
+    ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+    defer cancel()
+
+    ClientOptions := options.Client().ApplyURI(uri)
+    client, err := mongo.Connect(ctx, ClientOptions)
+    if err != nil {
+        return nil, err
+    }
+
+    err = client.Ping(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    log.Println("Connected to MongoDB")
+
+    m := &MongoStore{client: client, db: client.Database(database)}
+    if err := m.upgradeSlugs(ctx); err != nil {
+        return nil, fmt.Errorf("making slugs unique: %w", err)
+    }
+
+    if err := m.ensureIndexes(ctx); err != nil {
+        return nil, fmt.Errorf("creating indexes: %w", err)
+    }
+
+    if err := m.upgradeLikes(ctx); err != nil {
+        return nil, fmt.Errorf("turning likes into reactions: %w", err)
+    }
+
+    if err := m.upgradeRoles(ctx); err != nil {
+        return nil, fmt.Errorf("giving users roles: %w", err)
+    }
+
+    if err := m.upgradeSessions(ctx); err != nil {
+        return nil, fmt.Errorf("ending sessions with plain tokens: %w", err)
+    }
+
+    return m, nil
+}
+
+func (m *MongoStore) ensureIndexes(ctx context.Context) error {
+    _, err := m.db.Collection(posts_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys: bson.D{{Key: "slug", Value: 1}},
+            Options: options.Index().SetName("slug_unique").SetUnique(true).
+                SetPartialFilterExpression(bson.M{"slug": bson.M{"$gt": ""}}),
+        },
+        {Keys: bson.D{{Key: "oldslugs", Value: 1}}},
+        {Keys: bson.D{{Key: "tags", Value: 1}}},
+        {Keys: bson.D{{Key: "category", Value: 1}}},
+        {Keys: newestFirst},
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(portfolio_col).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: newestFirst})
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(comments_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "status", Value: 1}, {Key: "date", Value: 1}}},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "date", Value: 1}}},
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(reactions_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "visitor", Value: 1}, {Key: "emoji", Value: 1}},
+            Options: options.Index().SetUnique(true),
+        },
+        {Keys: bson.D{{Key: "post_id", Value: 1}, {Key: "emoji", Value: 1}, {Key: "ip_hash", Value: 1}, {Key: "date", Value: 1}}},
+        {Keys: bson.D{{Key: "visitor", Value: 1}}},
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(users_col).Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "username", Value: 1}},
+        Options: options.Index().SetUnique(true),
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(invites_col).Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "token_hash", Value: 1}},
+        Options: options.Index().SetUnique(true),
+    })
+    if err != nil {
+        return err
+    }
+
+    // Mongo deletes sessions itself once they expire.
+    _, err = m.db.Collection(sessions_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "token", Value: 1}},
+            Options: options.Index().SetUnique(true),
+        },
+        {Keys: bson.D{{Key: "user_id", Value: 1}}},
+        {
+            Keys:    bson.D{{Key: "expires", Value: 1}},
+            Options: options.Index().SetExpireAfterSeconds(0),
+        },
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(audit_col).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "time", Value: -1}}})
+    if err != nil {
+        return err
+    }
+
+    // Only users who gave an email have one, so only they are indexed.
+    _, err = m.db.Collection(users_col).Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "email", Value: 1}},
+        Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$exists": true}}),
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(resets_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "token_hash", Value: 1}},
+            Options: options.Index().SetUnique(true),
+        },
+        {Keys: bson.D{{Key: "user_id", Value: 1}}},
+        {
+            Keys:    bson.D{{Key: "expires", Value: 1}},
+            Options: options.Index().SetExpireAfterSeconds(0),
+        },
+    })
+    if err != nil {
+        return err
+    }
+
+    // Expired API tokens are kept, so that their users see why they stopped
+    // working, until they are revoked.
+    _, err = m.db.Collection(tokens_col).Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {
+            Keys:    bson.D{{Key: "token_hash", Value: 1}},
+            Options: options.Index().SetUnique(true),
+        },
+        {Keys: bson.D{{Key: "user_id", Value: 1}}},
+    })
+    return err
+}
+
+// upgradeRoles makes owners of the users from before roles existed, who
+// could all do anything.
+func (m *MongoStore) upgradeRoles(ctx context.Context) error {
+    _, err := m.db.Collection(users_col).UpdateMany(ctx,
+        bson.M{"role": bson.M{"$exists": false}},
+        bson.M{"$set": bson.M{"role": RoleOwner}},
+    )
+    return err
+}
+
+// upgradeSlugs gets posts ready for their slugs to be unique: posts that
+// already shared one leave it to the oldest of them and get their id
+// appended, and the index that let them share it is dropped.
+func (m *MongoStore) upgradeSlugs(ctx context.Context) error {
+    collection := m.db.Collection(posts_col)
+    cur, err := collection.Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"slug": bson.M{"$gt": ""}}}},
+        {{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$slug"},
+            {Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+        }}},
+        {{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+    })
+    if err != nil {
+        return err
+    }
+
+    var shared []struct {
+        Slug string               `bson:"_id"`
+        Ids  []primitive.ObjectID `bson:"ids"`
+    }
+    if err := cur.All(ctx, &shared); err != nil {
+        return err
+    }
+
+    for _, s := range shared {
+        for _, id := range s.Ids[1:] {
+            _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"slug": s.Slug + "-" + id.Hex()}})
+            if err != nil {
+                return err
+            }
+        }
+    }
+
+    _, err = collection.Indexes().DropOne(ctx, "slug_1")
+    var cmdErr mongo.CommandError
+    if errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) {
+        // NamespaceNotFound or IndexNotFound: nothing to drop.
+        return nil
+    }
+    return err
+}
+
+// upgradeSessions ends the sessions from before only token hashes were
+// stored, whose tokens were UUIDs and so, unlike hashes, have dashes.
+func (m *MongoStore) upgradeSessions(ctx context.Context) error {
+    _, err := m.db.Collection(sessions_col).DeleteMany(ctx,
+        bson.M{"token": bson.M{"$regex": "-"}},
+    )
+    return err
+}
+
+// upgradeLikes turns the likes of posts from before reactions into
+// LikeReaction reactions. It does nothing once they are all converted.
+func (m *MongoStore) upgradeLikes(ctx context.Context) error {
+    _, err := m.db.Collection(posts_col).UpdateMany(ctx,
+        bson.M{"likes": bson.M{"$gt": 0}, "reactions": bson.M{"$exists": false}},
+        bson.A{bson.M{"$set": bson.M{"reactions": bson.M{LikeReaction: "$likes"}}}},
+    )
+    if err != nil {
+        return err
+    }
+
+    var likes []Reaction
+    if err := readAll(ctx, m.db.Collection(likes_col), &likes); err != nil {
+        return err
+    }
+    if len(likes) == 0 {
+        return nil
+    }
+
+    docs := make([]interface{}, 0, len(likes))
+    for _, like := range likes {
+        like.Emoji = LikeReaction
+        docs = append(docs, like)
+    }
+
+    _, err = m.db.Collection(reactions_col).InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+    if err != nil && !mongo.IsDuplicateKeyError(err) {
+        return err
+    }
+    return m.db.Collection(likes_col).Drop(ctx)
+}
+
+func (m *MongoStore) Close(ctx context.Context) error {
+    return m.client.Disconnect(ctx)
+}
+
+// findOne decodes a single document into v, translating a missing document
+// into ErrNotFound.
+func findOne(ctx context.Context, collection *mongo.Collection, filter interface{}, v interface{}) error {
+    err := collection.FindOne(ctx, filter).Decode(v)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return ErrNotFound
+    }
+    return err
+}
+
+func (m *MongoStore) CreateUser(ctx context.Context, user User) (User, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    count, err := collection.CountDocuments(ctx, bson.M{"username": user.Username})
+    if err != nil {
+        return user, err
+    }
+    if count > 0 {
+        return user, ErrDuplicate
+    }
+
+    if user.Id.IsZero() {
+        user.Id = primitive.NewObjectID()
+    }
+    _, err = collection.InsertOne(ctx, user)
+    if mongo.IsDuplicateKeyError(err) {
+        return user, ErrDuplicate
+    }
+    return user, err
+}
+
+func (m *MongoStore) GetUser(ctx context.Context, id primitive.ObjectID) (User, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var user User
+    err := findOne(ctx, collection, bson.M{"_id": id}, &user)
+    return user, err
+}
+
+func (m *MongoStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var user User
+    err := findOne(ctx, collection, bson.M{"username": username}, &user)
+    return user, err
+}
+
+func (m *MongoStore) ListUsers(ctx context.Context) ([]User, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    cur, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "username", Value: 1}}))
+    if err != nil {
+        return nil, err
+    }
+
+    var users []User
+    err = cur.All(ctx, &users)
+    return users, err
+}
+
+func (m *MongoStore) CountUsers(ctx context.Context) (int, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    count, err := collection.CountDocuments(ctx, bson.M{})
+    return int(count), err
+}
+
+func (m *MongoStore) SetUserRole(ctx context.Context, id primitive.ObjectID, role string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"role": role}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var user User
+    if email == "" {
+        return user, ErrNotFound
+    }
+    err := findOne(ctx, collection, bson.M{"email": email}, &user)
+    return user, err
+}
+
+func (m *MongoStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password": hash}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) SetUserEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    update := bson.M{"$set": bson.M{"email": email}}
+    if email == "" {
+        update = bson.M{"$unset": bson.M{"email": ""}}
+    }
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+    if mongo.IsDuplicateKeyError(err) {
+        return ErrDuplicate
+    }
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) CreatePasswordReset(ctx context.Context, reset PasswordReset) error {
+    collection := m.db.Collection(resets_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if _, err := collection.DeleteMany(ctx, bson.M{"user_id": reset.UserId}); err != nil {
+        return err
+    }
+    if reset.Id.IsZero() {
+        reset.Id = primitive.NewObjectID()
+    }
+    _, err := collection.InsertOne(ctx, reset)
+    return err
+}
+
+func (m *MongoStore) GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+    collection := m.db.Collection(resets_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var reset PasswordReset
+    err := findOne(ctx, collection, bson.M{"token_hash": tokenHash}, &reset)
+    return reset, err
+}
+
+func (m *MongoStore) UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (PasswordReset, error) {
+    collection := m.db.Collection(resets_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var reset PasswordReset
+    err := collection.FindOneAndUpdate(ctx,
+        bson.M{"token_hash": tokenHash, "used_at": bson.M{"$exists": false}, "expires": bson.M{"$gt": now}},
+        bson.M{"$set": bson.M{"used_at": now}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&reset)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return reset, ErrInvalidReset
+    }
+    return reset, err
+}
+
+func (m *MongoStore) DeleteUserPasswordResets(ctx context.Context, user primitive.ObjectID) error {
+    collection := m.db.Collection(resets_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    _, err := collection.DeleteMany(ctx, bson.M{"user_id": user})
+    return err
+}
+
+func (m *MongoStore) SetTwoFactor(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    update := bson.M{
+        "$set":   bson.M{"totp_secret": secret, "recovery_codes": recoveryCodes},
+        "$unset": bson.M{"totp_step": ""},
+    }
+    if secret == "" {
+        update = bson.M{"$unset": bson.M{"totp_secret": "", "totp_step": "", "recovery_codes": ""}}
+    }
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, recoveryCodes []string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"recovery_codes": recoveryCodes}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    // $not also matches users who never had a code accepted.
+    result, err := collection.UpdateOne(ctx,
+        bson.M{"_id": id, "totp_step": bson.M{"$not": bson.M{"$gte": step}}},
+        bson.M{"$set": bson.M{"totp_step": step}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrCodeUsed
+    }
+    return nil
+}
+
+func (m *MongoStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+    collection := m.db.Collection(users_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx,
+        bson.M{"_id": id, "recovery_codes": hash},
+        bson.M{"$pull": bson.M{"recovery_codes": hash}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) GetSettings(ctx context.Context) (SiteSettings, error) {
+    collection := m.db.Collection(settings_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var settings SiteSettings
+    err := collection.FindOne(ctx, bson.M{"_id": siteSettingsId}).Decode(&settings)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return SiteSettings{}, nil
+    }
+    return settings, err
+}
+
+func (m *MongoStore) SaveSettings(ctx context.Context, settings SiteSettings) error {
+    collection := m.db.Collection(settings_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    _, err := collection.ReplaceOne(ctx, bson.M{"_id": siteSettingsId}, settings, options.Replace().SetUpsert(true))
+    return err
+}
+
+func (m *MongoStore) CreateInvite(ctx context.Context, invite Invite) (Invite, error) {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    invite.Id = primitive.NewObjectID()
+    _, err := collection.InsertOne(ctx, invite)
+    return invite, err
+}
+
+func (m *MongoStore) Invites(ctx context.Context) ([]Invite, error) {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    cur, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created", Value: -1}}))
+    if err != nil {
+        return nil, err
+    }
+
+    var invites []Invite
+    err = cur.All(ctx, &invites)
+    return invites, err
+}
+
+func (m *MongoStore) GetInvite(ctx context.Context, tokenHash string) (Invite, error) {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var invite Invite
+    err := findOne(ctx, collection, bson.M{"token_hash": tokenHash}, &invite)
+    return invite, err
+}
+
+func (m *MongoStore) UseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID, now time.Time) (Invite, error) {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    // Matching on the invite being unused makes using it a single atomic
+    // step, so two registrations racing for it can't both win.
+    var invite Invite
+    err := collection.FindOneAndUpdate(ctx,
+        bson.M{"token_hash": tokenHash, "used_at": bson.M{"$exists": false}, "expires": bson.M{"$gt": now}},
+        bson.M{"$set": bson.M{"used_by": user, "used_at": now}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&invite)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return invite, ErrInvalidInvite
+    }
+    return invite, err
+}
+
+func (m *MongoStore) ReleaseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID) error {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx,
+        bson.M{"token_hash": tokenHash, "used_by": user},
+        bson.M{"$unset": bson.M{"used_by": "", "used_at": ""}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) DeleteInvite(ctx context.Context, id primitive.ObjectID) error {
+    collection := m.db.Collection(invites_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) CreateSession(ctx context.Context, session Session) error {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if session.Id.IsZero() {
+        session.Id = primitive.NewObjectID()
+    }
+    _, err := collection.InsertOne(ctx, session)
+    return err
+}
+
+func (m *MongoStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var session Session
+    err := findOne(ctx, collection, bson.M{"token": tokenHash}, &session)
+    return session, err
+}
+
+func (m *MongoStore) TouchSession(ctx context.Context, tokenHash string, seen, expires time.Time) error {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx, bson.M{"token": tokenHash},
+        bson.M{"$set": bson.M{"last_seen": seen, "expires": expires}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) UserSessions(ctx context.Context, user primitive.ObjectID) ([]Session, error) {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    cur, err := collection.Find(ctx, bson.M{"user_id": user}, options.Find().SetSort(bson.D{{Key: "last_seen", Value: -1}}))
+    if err != nil {
+        return nil, err
+    }
+
+    var sessions []Session
+    err = cur.All(ctx, &sessions)
+    return sessions, err
+}
+
+func (m *MongoStore) DeleteSession(ctx context.Context, tokenHash string) error {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"token": tokenHash})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) RevokeSession(ctx context.Context, user, id primitive.ObjectID) error {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": user})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) DeleteUserSessions(ctx context.Context, user primitive.ObjectID) (int, error) {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteMany(ctx, bson.M{"user_id": user})
+    if err != nil {
+        return 0, err
+    }
+    return int(result.DeletedCount), nil
+}
+
+func (m *MongoStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+    collection := m.db.Collection(sessions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteMany(ctx, bson.M{"expires": bson.M{"$lt": now}})
+    if err != nil {
+        return 0, err
+    }
+    return int(result.DeletedCount), nil
+}
+
+func (m *MongoStore) CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error) {
+    collection := m.db.Collection(tokens_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if token.Id.IsZero() {
+        token.Id = primitive.NewObjectID()
+    }
+    _, err := collection.InsertOne(ctx, token)
+    if mongo.IsDuplicateKeyError(err) {
+        return token, ErrDuplicate
+    }
+    return token, err
+}
+
+func (m *MongoStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+    collection := m.db.Collection(tokens_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var token APIToken
+    err := findOne(ctx, collection, bson.M{"token_hash": tokenHash}, &token)
+    return token, err
+}
+
+func (m *MongoStore) UserAPITokens(ctx context.Context, user primitive.ObjectID) ([]APIToken, error) {
+    collection := m.db.Collection(tokens_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    cur, err := collection.Find(ctx, bson.M{"user_id": user},
+        options.Find().SetSort(bson.D{{Key: "created", Value: -1}, {Key: "_id", Value: -1}}))
+    if err != nil {
+        return nil, err
+    }
+
+    var tokens []APIToken
+    err = cur.All(ctx, &tokens)
+    return tokens, err
+}
+
+func (m *MongoStore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, when time.Time, ip string) error {
+    collection := m.db.Collection(tokens_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx, bson.M{"_id": id},
+        bson.M{"$set": bson.M{"last_used": when, "last_ip": ip}})
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) RevokeAPIToken(ctx context.Context, user, id primitive.ObjectID) error {
+    collection := m.db.Collection(tokens_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": user})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MongoStore) Audit(ctx context.Context, entry AuditEntry) error {
+    collection := m.db.Collection(audit_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    entry.Id = primitive.NewObjectID()
+    _, err := collection.InsertOne(ctx, entry)
+    return err
+}
+
+func (m *MongoStore) AuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+    collection := m.db.Collection(audit_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(int64(limit))
+    cur, err := collection.Find(ctx, bson.M{}, opts)
+    if err != nil {
+        return nil, err
+    }
+
+    var entries []AuditEntry
+    err = cur.All(ctx, &entries)
+    return entries, err
+}
+
+func (m *MongoStore) CreatePost(ctx context.Context, post Post) (Post, error){
+    post, err := lifecycle(Post{}, taxonomy(post), time.Now())
+    if err != nil {
+        return post, err
+    }
+    post.Id = primitive.NewObjectID()
+    post.OldSlugs = nil
+
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    err = retrySlug(func() error {
+        slug, err := uniqueSlug(ctx, m, post.Title, post.Id)
+        if err != nil {
+            return err
+        }
+        post.Slug = slug
+
+        _, err = collection.InsertOne(ctx, post)
+        if mongo.IsDuplicateKeyError(err) {
+            return ErrDuplicate
+        }
+        return err
+    })
+    return post, err
+}
+
+func (m *MongoStore) GetPosts(ctx context.Context)([]Post, error){
+    return m.findPosts(ctx, bson.D{}, Page{})
+}
+
+// newestFirst is the order of every listing. The id breaks ties so pages
+// never overlap.
+var newestFirst = bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}
+
+// findOptions sorts newest first and applies the page's limit. The cursor is
+// part of the filter, see afterCursorFilter.
+func findOptions(page Page) *options.FindOptions {
+    opts := options.Find().SetSort(newestFirst)
+    if page.Limit > 0 {
+        opts.SetLimit(int64(page.Limit))
+    }
+    return opts
+}
+
+// afterCursorFilter matches the documents that come after c when ordered
+// newest first.
+func afterCursorFilter(c Cursor) bson.A {
+    return bson.A{
+        bson.M{"date": bson.M{"$lt": c.Date}},
+        bson.M{"date": c.Date, "_id": bson.M{"$lt": c.Id}},
+    }
+}
+
+func (m *MongoStore) findPosts(ctx context.Context, filter interface{}, page Page)([]Post, error){
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    opts := findOptions(page)
+
+    var posts []Post
+    cur, err := collection.Find(ctx, filter, opts)
+
+    if err != nil{
+        return posts, err
+    }
+
+    err = cur.All(ctx, &posts)
+
+    return posts, err
+}
+
+func (m *MongoStore) GetPost(ctx context.Context, id string) (Post, error) {
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil{
+        return Post{}, err
+    }
+
+    var post Post
+    err = findOne(ctx, collection, bson.M{"_id": objectId}, &post)
+
+    return post, err
+}
+
+func (m *MongoStore) GetPostBySlug(ctx context.Context, slug string) (Post, error) {
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    filter := bson.M{"$or": bson.A{
+        bson.M{"slug": slug},
+        bson.M{"oldslugs": slug},
+    }}
+
+    var post Post
+    err := findOne(ctx, collection, filter, &post)
+
+    return post, err
+}
+
+func (m *MongoStore) UpdatePost(ctx context.Context, p Post) (Post, error){
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    post, err := m.GetPost(ctx, p.Id.Hex())
+    if err != nil{
+        return post, err
+    }
+
+    p, err = lifecycle(post, taxonomy(p), time.Now())
+    if err != nil{
+        return post, err
+    }
+
+    var slug string
+    var oldSlugs []string
+    err = retrySlug(func() error{
+        slug, oldSlugs, err = reslug(ctx, m, post, p.Title)
+        if err != nil{
+            return err
+        }
+
+        update := bson.M{
+            "$set": bson.M{
+                "title": p.Title,
+                "body":  p.Body,
+                "synopsys": p.Synopsys,
+                "coverimage": p.CoverImage,
+                "slug": slug,
+                "oldslugs": oldSlugs,
+                "status": p.Status,
+                "publishat": p.PublishAt,
+                "date": p.Date,
+                "updated": p.Updated,
+                "tags": p.Tags,
+                "category": p.Category,
+                "seo": p.SEO,
+            },
+        }
+
+        _, err = collection.UpdateOne(
+            ctx,
+            bson.M{"_id": p.Id},
+            update,
+        )
+        if mongo.IsDuplicateKeyError(err){
+            return ErrDuplicate
+        }
+        return err
+    })
+
+    post.Title = p.Title
+    post.Body = p.Body
+    post.Synopsys = p.Synopsys
+    post.CoverImage = p.CoverImage
+    post.Slug = slug
+    post.OldSlugs = oldSlugs
+    post.Status = p.Status
+    post.PublishAt = p.PublishAt
+    post.Date = p.Date
+    post.Updated = p.Updated
+    post.Tags = p.Tags
+    post.Category = p.Category
+    post.SEO = p.SEO
+
+    return post, err
+}
+
+func (m *MongoStore) DeletePost(ctx context.Context, id string) error {
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
+    if err != nil {
+        return err
+    }
+
+    if result.DeletedCount == 0 {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    _, err = m.db.Collection(comments_col).DeleteMany(ctx, bson.M{"post_id": objectID})
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(reactions_col).DeleteMany(ctx, bson.M{"post_id": objectID})
+    return err
+}
+
+// addReaction changes the count of a reaction to a post by delta in a single
+// update, so concurrent reactions are never lost, and returns the post as it
+// is after.
+func (m *MongoStore) addReaction(ctx context.Context, id primitive.ObjectID, emoji string, delta int) (Post, error) {
+    field := "reactions." + emoji
+    filter := bson.M{"_id": id}
+    if delta < 0 {
+        filter[field] = bson.M{"$gte": -delta}
+    }
+
+    var post Post
+    err := m.db.Collection(posts_col).FindOneAndUpdate(ctx,
+        filter,
+        bson.M{"$inc": bson.M{field: delta, "likes": delta}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&post)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return m.GetPost(ctx, id.Hex())
+    }
+    return post, err
+}
+
+func (m *MongoStore) React(ctx context.Context, reaction Reaction, since time.Time) (Post, error) {
+    collection := m.db.Collection(reactions_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    post, err := m.GetPost(ctx, reaction.PostId.Hex())
+    if err != nil {
+        return post, err
+    }
+
+    recent, err := collection.CountDocuments(ctx, bson.M{
+        "post_id": reaction.PostId,
+        "emoji":   reaction.Emoji,
+        "ip_hash": reaction.IPHash,
+        "date":    bson.M{"$gte": since},
+    })
+    if err != nil {
+        return post, err
+    }
+    if recent > 0 {
+        return post, ErrAlreadyReacted
+    }
+
+    // The unique index on post, visitor and emoji settles concurrent
+    // reactions from the same visitor.
+    if _, err := collection.InsertOne(ctx, reaction); err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            return post, ErrAlreadyReacted
+        }
+        return post, err
+    }
+
+    return m.addReaction(ctx, reaction.PostId, reaction.Emoji, 1)
+}
+
+func (m *MongoStore) Unreact(ctx context.Context, postId primitive.ObjectID, visitor, emoji string) (Post, error) {
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    result, err := m.db.Collection(reactions_col).DeleteOne(ctx, bson.M{"post_id": postId, "visitor": visitor, "emoji": emoji})
+    if err != nil {
+        return Post{}, err
+    }
+
+    if result.DeletedCount == 0 {
+        return m.GetPost(ctx, postId.Hex())
+    }
+    return m.addReaction(ctx, postId, emoji, -1)
+}
+
+func (m *MongoStore) VisitorReactions(ctx context.Context, visitor string) ([]Reaction, error) {
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    cursor, err := m.db.Collection(reactions_col).Find(ctx, bson.M{"visitor": visitor})
+    if err != nil {
+        return nil, err
+    }
+
+    var reactions []Reaction
+    err = cursor.All(ctx, &reactions)
+    return reactions, err
+}
+
+func (m *MongoStore) QueryPosts(ctx context.Context, q PostQuery)([]Post, error){
+    return m.findPosts(ctx, postFilter(q), q.Page)
+}
+
+func postFilter(q PostQuery) bson.M {
+    filter := bson.M{}
+
+    if !q.After.IsZero() {
+        filter["$or"] = afterCursorFilter(q.After)
+    }
+
+    if q.TitlePrefix != "" {
+        pattern := fmt.Sprintf("^%s", regexp.QuoteMeta(q.TitlePrefix))
+        filter["title"] = primitive.Regex{Pattern: pattern, Options: "i"}
+    }
+
+    if q.Status != "" {
+        filter["status"] = q.Status
+    }
+
+    if q.Tag != "" {
+        filter["tags"] = q.Tag
+    }
+
+    if q.Category != "" {
+        filter["category"] = q.Category
+    }
+
+    if q.Ids != nil {
+        filter["_id"] = bson.M{"$in": q.Ids}
+    }
+
+    return filter
+}
+
+func (m *MongoStore) TagCounts(ctx context.Context, q PostQuery) ([]TagCount, error) {
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: postFilter(q)}},
+        {{Key: "$unwind", Value: "$tags"}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$tags"},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+        }}},
+        {{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+    }
+
+    var counts []TagCount
+    cur, err := collection.Aggregate(ctx, pipeline)
+    if err != nil {
+        return counts, err
+    }
+
+    err = cur.All(ctx, &counts)
+    return counts, err
+}
+
+func (m *MongoStore) PublishDue(ctx context.Context, now time.Time) ([]Post, error) {
+    due, err := m.findPosts(ctx, bson.M{
+        "status": StatusScheduled,
+        "publishat": bson.M{"$lte": now},
+    }, Page{})
+    if err != nil {
+        return nil, err
+    }
+
+    collection := m.db.Collection(posts_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var published []Post
+    for _, post := range due {
+        // Matching on the status too keeps a post that was just edited
+        // back to a draft from being published behind the author's back.
+        result, err := collection.UpdateOne(ctx,
+            bson.M{"_id": post.Id, "status": StatusScheduled},
+            bson.M{"$set": bson.M{"status": StatusPublished, "date": post.PublishAt, "updated": now}},
+        )
+        if err != nil {
+            return published, err
+        }
+
+        if result.ModifiedCount > 0 {
+            post.Status = StatusPublished
+            post.Date = post.PublishAt
+            post.Updated = now
+            published = append(published, post)
+        }
+    }
+
+    return published, nil
+}
+
+func (m *MongoStore) CreateComment(ctx context.Context, comment Comment) (Comment, error) {
+    comment, err := newComment(comment)
+    if err != nil {
+        return comment, err
+    }
+
+    if _, err := m.GetPost(ctx, comment.PostId.Hex()); err != nil {
+        return comment, err
+    }
+
+    collection := m.db.Collection(comments_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    if _, err := collection.InsertOne(ctx, comment); err != nil {
+        return comment, err
+    }
+
+    return comment, m.recountComments(ctx, comment.PostId)
+}
+
+func (m *MongoStore) GetComment(ctx context.Context, id primitive.ObjectID) (Comment, error) {
+    collection := m.db.Collection(comments_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var comment Comment
+    err := findOne(ctx, collection, bson.M{"_id": id}, &comment)
+    return comment, err
+}
+
+func (m *MongoStore) QueryComments(ctx context.Context, q CommentQuery) ([]Comment, error) {
+    collection := m.db.Collection(comments_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    filter := bson.M{}
+    if !q.PostId.IsZero() {
+        filter["post_id"] = q.PostId
+    }
+    if q.Status != "" {
+        filter["status"] = q.Status
+    }
+
+    opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}})
+
+    var comments []Comment
+    cur, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return comments, err
+    }
+
+    err = cur.All(ctx, &comments)
+    return comments, err
+}
+
+func (m *MongoStore) SetCommentStatus(ctx context.Context, id primitive.ObjectID, status string) (Comment, error) {
+    if err := checkCommentStatus(status); err != nil {
+        return Comment{}, err
+    }
+
+    collection := m.db.Collection(comments_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    var comment Comment
+    err := collection.FindOneAndUpdate(ctx,
+        bson.M{"_id": id},
+        bson.M{"$set": bson.M{"status": status, "moderated_at": time.Now()}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&comment)
+    if errors.Is(err, mongo.ErrNoDocuments) {
+        return comment, ErrNotFound
+    }
+    if err != nil {
+        return comment, err
+    }
+
+    return comment, m.recountComments(ctx, comment.PostId)
+}
+
+// recountComments sets the comment count of a post to its number of approved
+// comments.
+func (m *MongoStore) recountComments(ctx context.Context, postId primitive.ObjectID) error {
+    count, err := m.db.Collection(comments_col).CountDocuments(ctx, bson.M{
+        "post_id": postId,
+        "status": CommentApproved,
+    })
+    if err != nil {
+        return err
+    }
+
+    _, err = m.db.Collection(posts_col).UpdateOne(ctx,
+        bson.M{"_id": postId},
+        bson.M{"$set": bson.M{"comments": count}},
+    )
+    return err
+}
+
+func (m *MongoStore) GetPortfolioEntries(ctx context.Context, page Page)([]PortfolioEntry, error){
+    collection := m.db.Collection(portfolio_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    filter := bson.M{}
+    if !page.After.IsZero() {
+        filter["$or"] = afterCursorFilter(page.After)
+    }
+
+    var entries []PortfolioEntry
+    cur, err := collection.Find(ctx, filter, findOptions(page))
+
+    if err != nil{
+        return entries,err
+    }
+
+    err = cur.All(ctx, &entries)
+
+    return entries, err
+}
+
+func (m *MongoStore) CreatePortfolioEntry(ctx context.Context, entry PortfolioEntry)(PortfolioEntry, error){
+    entry.Id = primitive.NewObjectID()
+    entry.Date = time.Now()
+
+    collection := m.db.Collection(portfolio_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    _, err := collection.InsertOne(ctx, entry)
+    return entry, err
+}
+
+func (m *MongoStore) UpdateEntry(ctx context.Context, e PortfolioEntry)(PortfolioEntry, error){
+    collection := m.db.Collection(portfolio_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    entry, err := m.GetEntry(ctx, e.Id.Hex())
+    if err != nil{
+        return entry, err
+    }
+
+    update := bson.M{
+        "$set": bson.M{
+            "title": e.Title,
+            "repo":  e.Repo,
+            "url": e.Url,
+            "coverimage": e.CoverImage,
+        },
+    }
+
+    _, err = collection.UpdateOne(
+        ctx,
+        bson.M{"_id": e.Id},
+        update,
+    )
+
+    entry.Title = e.Title
+    entry.Repo = e.Repo
+    entry.Url = e.Url
+    entry.CoverImage = e.CoverImage
+
+    return entry, err
+}
+
+func (m *MongoStore) GetEntry(ctx context.Context, id string)(PortfolioEntry, error){
+    collection := m.db.Collection(portfolio_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil{
+        return PortfolioEntry{}, err
+    }
+
+    var entry PortfolioEntry
+    err = findOne(ctx, collection, bson.M{"_id": objectId}, &entry)
+
+    return entry, err
+}
+
+func (m *MongoStore) DeleteEntry(ctx context.Context, id string)(error){
+    collection := m.db.Collection(portfolio_col)
+    ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+    defer cancel()
+
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
+    if err != nil {
+        return err
+    }
+
+    if result.DeletedCount == 0 {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "unicode/utf8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+// PasswordReset lets a user who forgot their password set a new one, once,
+// before it expires.
+type PasswordReset struct {
+    Id        primitive.ObjectID `bson:"_id,omitempty"`
+    TokenHash string             `bson:"token_hash"`
+    UserId    primitive.ObjectID `bson:"user_id"`
+    Created   time.Time          `bson:"created"`
+    Expires   time.Time          `bson:"expires"`
+    UsedAt    time.Time          `bson:"used_at,omitempty"`
+}
+
+// Usable tells whether the reset can still be used at now.
+func (p PasswordReset) Usable(now time.Time) bool {
+    return p.UsedAt.IsZero() && now.Before(p.Expires)
+}
+
+// ErrWeakPassword is returned for passwords that fall short of the policy,
+// wrapped in an error saying how.
+var ErrWeakPassword = errors.New("password is too weak")
+
+// commonPasswords are turned down however long they are. Shorter ones don't
+// meet any allowed minimum length anyway.
+var commonPasswords = map[string]bool{
+    "password": true, "password1": true, "password12": true, "password123": true,
+    "password1234": true, "passw0rd": true, "12345678": true, "123456789": true,
+    "1234567890": true, "123456789012": true, "11111111": true, "00000000": true,
+    "qwertyuiop": true, "qwerty123": true, "qwerty12345": true, "1q2w3e4r": true,
+    "1q2w3e4r5t": true, "zaq12wsx": true, "iloveyou": true, "sunshine": true,
+    "princess": true, "football": true, "baseball": true, "superman": true,
+    "starwars": true, "whatever": true, "trustno1": true, "welcome1": true,
+    "welcome123": true, "letmein123": true, "changeme": true, "changeme123": true,
+    "administrator": true, "abc12345": true, "abcdefgh": true, "abcd1234": true,
+}
+
+// minDistinctRunes keeps out passwords that are one or two characters
+// repeated, like aaaaaaaaaaaa.
+const minDistinctRunes = 5
+
+// CheckPasswordStrength holds a new password to the policy. It needs to be
+// long enough, fit in what bcrypt looks at, and be neither the username, a
+// well known password nor a few characters over and over.
+func CheckPasswordStrength(policy config.PasswordConfig, username, password string) error {
+    if utf8.RuneCountInString(password) < policy.MinLength {
+        return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, policy.MinLength)
+    }
+    // bcrypt ignores everything after 72 bytes.
+    if len(password) > 72 {
+        return fmt.Errorf("%w: it must be at most 72 bytes long", ErrWeakPassword)
+    }
+
+    lower := strings.ToLower(password)
+    if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+        return fmt.Errorf("%w: it must not contain the username", ErrWeakPassword)
+    }
+    if commonPasswords[lower] {
+        return fmt.Errorf("%w: it is one of the most common passwords", ErrWeakPassword)
+    }
+
+    distinct := map[rune]bool{}
+    for _, r := range password {
+        distinct[r] = true
+    }
+    if len(distinct) < minDistinctRunes {
+        return fmt.Errorf("%w: it must have at least %d different characters", ErrWeakPassword, minDistinctRunes)
+    }
+
+    return nil
+}
+
+// ChangePassword holds a user's new password to the policy and stores its
+// hash. Reset links sent for the old password stop working.
+func ChangePassword(ctx context.Context, s Store, user User, password string, policy config.PasswordConfig) error {
+    if err := CheckPasswordStrength(policy, user.Username, password); err != nil {
+        return err
+    }
+
+    user.Password = password
+    if err := user.HashPassword(policy.Cost); err != nil {
+        return err
+    }
+    if err := s.SetPassword(ctx, user.Id, user.Password); err != nil {
+        return err
+    }
+    return s.DeleteUserPasswordResets(ctx, user.Id)
+}
+
+// RehashPassword hashes again the password a user just logged in with, when
+// the stored hash has a lower cost than the policy's. It tells whether it
+// did.
+func RehashPassword(ctx context.Context, users UserStore, user User, password string, policy config.PasswordConfig) (bool, error) {
+    if !user.NeedsRehash(policy.Cost) {
+        return false, nil
+    }
+
+    user.Password = password
+    if err := user.HashPassword(policy.Cost); err != nil {
+        return false, err
+    }
+    return true, users.SetPassword(ctx, user.Id, user.Password)
+}
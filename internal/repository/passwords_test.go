@@ -0,0 +1,116 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+// newReset stores a reset for user made at now, good for an hour.
+func newReset(t *testing.T, s Store, user User, now time.Time) PasswordReset {
+    t.Helper()
+    _, hash, err := NewToken()
+    if err != nil {
+        t.Fatal(err)
+    }
+    reset := PasswordReset{TokenHash: hash, UserId: user.Id, Created: now, Expires: now.Add(time.Hour)}
+    if err := s.CreatePasswordReset(context.Background(), reset); err != nil {
+        t.Fatal(err)
+    }
+    return reset
+}
+
+func TestPasswordResetSingleUse(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        now := time.Now().Truncate(time.Millisecond)
+        reset := newReset(t, s, createUser(t, s, "alice"), now)
+
+        used, err := s.UsePasswordReset(ctx, reset.TokenHash, now)
+        if err != nil {
+            t.Fatalf("first use: %v", err)
+        }
+        if !used.UsedAt.Equal(now) {
+            t.Errorf("used at %s, want %s", used.UsedAt, now)
+        }
+        if _, err := s.UsePasswordReset(ctx, reset.TokenHash, now); !errors.Is(err, ErrInvalidReset) {
+            t.Errorf("second use gave %v, want ErrInvalidReset", err)
+        }
+
+        stored, err := s.GetPasswordReset(ctx, reset.TokenHash)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if stored.Usable(now) {
+            t.Error("used reset is still usable")
+        }
+    })
+}
+
+func TestPasswordResetExpiry(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        now := time.Now().Truncate(time.Millisecond)
+
+        reset := newReset(t, s, createUser(t, s, "alice"), now)
+        if _, err := s.UsePasswordReset(ctx, reset.TokenHash, reset.Expires); !errors.Is(err, ErrInvalidReset) {
+            t.Errorf("use when it expires gave %v, want ErrInvalidReset", err)
+        }
+
+        reset = newReset(t, s, createUser(t, s, "bob"), now)
+        if _, err := s.UsePasswordReset(ctx, reset.TokenHash, reset.Expires.Add(-time.Millisecond)); err != nil {
+            t.Errorf("use just before it expires: %v", err)
+        }
+    })
+}
+
+func TestPasswordResetReplaced(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        now := time.Now().Truncate(time.Millisecond)
+        user := createUser(t, s, "alice")
+
+        first := newReset(t, s, user, now)
+        second := newReset(t, s, user, now)
+        if _, err := s.UsePasswordReset(ctx, first.TokenHash, now); !errors.Is(err, ErrInvalidReset) {
+            t.Errorf("earlier reset gave %v, want ErrInvalidReset", err)
+        }
+        if _, err := s.UsePasswordReset(ctx, second.TokenHash, now); err != nil {
+            t.Errorf("latest reset: %v", err)
+        }
+    })
+}
+
+func TestPasswordChangeInvalidatesResets(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        now := time.Now().Truncate(time.Millisecond)
+        alice := createUser(t, s, "alice")
+        bob := createUser(t, s, "bob")
+        reset := newReset(t, s, alice, now)
+        other := newReset(t, s, bob, now)
+
+        policy := config.Default().Password
+        policy.Cost = 4
+        if err := ChangePassword(ctx, s, alice, "Correct horse 1", policy); err != nil {
+            t.Fatal(err)
+        }
+
+        if _, err := s.UsePasswordReset(ctx, reset.TokenHash, now); !errors.Is(err, ErrInvalidReset) {
+            t.Errorf("reset from before the password changed gave %v, want ErrInvalidReset", err)
+        }
+        if _, err := s.UsePasswordReset(ctx, other.TokenHash, now); err != nil {
+            t.Errorf("another user's reset: %v", err)
+        }
+
+        alice, err := s.GetUser(ctx, alice.Id)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !alice.CheckPassword("Correct horse 1") {
+            t.Error("new password doesn't work")
+        }
+    })
+}
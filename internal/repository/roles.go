@@ -0,0 +1,93 @@
+package repository
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "slices"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// Roles from the most to the least trusted. Each may do everything the ones
+// after it may:
+//   - viewers see the admin pages and preview unpublished posts,
+//   - authors write posts and edit or delete their own,
+//   - editors edit every post and manage the portfolio and comments,
+//   - owners also invite users and choose their roles.
+const (
+    RoleOwner  = "owner"
+    RoleEditor = "editor"
+    RoleAuthor = "author"
+    RoleViewer = "viewer"
+)
+
+var Roles = []string{RoleOwner, RoleEditor, RoleAuthor, RoleViewer}
+
+func IsRole(role string) bool {
+    return slices.Contains(Roles, role)
+}
+
+// Can tells whether the user's role is role or a more trusted one. Users
+// without a known role can do nothing.
+func (u User) Can(role string) bool {
+    have := slices.Index(Roles, u.Role)
+    need := slices.Index(Roles, role)
+    return have >= 0 && need >= 0 && have <= need
+}
+
+// CanEditPost tells whether the user may edit or delete post.
+func (u User) CanEditPost(post Post) bool {
+    return u.Can(RoleEditor) || (u.Can(RoleAuthor) && !u.Id.IsZero() && post.AuthorId == u.Id)
+}
+
+// Invite lets whoever holds its token register once, with Role, until it
+// expires. Only a hash of the token is stored.
+type Invite struct {
+    Id        primitive.ObjectID `bson:"_id,omitempty"`
+    TokenHash string             `bson:"token_hash"`
+    Role      string             `bson:"role"`
+    CreatedBy primitive.ObjectID `bson:"created_by"`
+    Created   time.Time          `bson:"created"`
+    Expires   time.Time          `bson:"expires"`
+    UsedBy    primitive.ObjectID `bson:"used_by,omitempty"`
+    UsedAt    time.Time          `bson:"used_at,omitempty"`
+}
+
+// Usable tells whether the invite can still be used at now.
+func (i Invite) Usable(now time.Time) bool {
+    return i.UsedAt.IsZero() && now.Before(i.Expires)
+}
+
+// State is "used", "expired" or "open", for showing.
+func (i Invite) State(now time.Time) string {
+    switch {
+    case !i.UsedAt.IsZero():
+        return "used"
+    case !now.Before(i.Expires):
+        return "expired"
+    }
+    return "open"
+}
+
+// NewToken makes a random token to hand out and the hash to store in its
+// place.
+func NewToken() (token, hash string, err error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", err
+    }
+    token = base64.RawURLEncoding.EncodeToString(b)
+    return token, HashToken(token), nil
+}
+
+// HashToken is how tokens made by NewToken are stored and looked up. They
+// are random enough that a plain hash, unlike for passwords, is safe.
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
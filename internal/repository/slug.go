@@ -0,0 +1,131 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "golang.org/x/text/unicode/norm"
+)
+
+const maxSlugLength = 80
+
+// Slugify turns a title into a URL path segment: accents are dropped from
+// Latin letters, letters are lower cased and everything that isn't a letter or a digit becomes a
+// single dash. Letters outside the Latin alphabet are kept as they are.
+func Slugify(title string) string {
+    slug := slugify(title)
+    if slug == "" {
+        slug = "post"
+    }
+    return slug
+}
+
+func slugify(title string) string {
+    var b strings.Builder
+    // keepMarks is set after the letters whose combining marks stay.
+    dash, keepMarks := false, false
+
+    for _, r := range norm.NFKD.String(title) {
+        switch {
+        case unicode.Is(unicode.Mn, r):
+            // Combining marks left over from decomposing é into e + ´ are
+            // dropped, but only from Latin letters: й or ブ must stay whole.
+            if keepMarks {
+                b.WriteRune(r)
+            }
+        case unicode.IsLetter(r) || unicode.IsDigit(r):
+            if dash && b.Len() > 0 {
+                b.WriteByte('-')
+            }
+            dash = false
+            keepMarks = unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r)
+            b.WriteRune(unicode.ToLower(r))
+        default:
+            dash, keepMarks = true, false
+        }
+    }
+
+    slug := norm.NFC.String(b.String())
+    if len(slug) > maxSlugLength {
+        slug = strings.TrimRight(truncateRunes(slug, maxSlugLength), "-")
+    }
+    return slug
+}
+
+// truncateRunes cuts s to at most n bytes without splitting a rune.
+func truncateRunes(s string, n int) string {
+    if len(s) <= n {
+        return s
+    }
+    for n > 0 && !utf8.RuneStart(s[n]) {
+        n--
+    }
+    return s[:n]
+}
+
+// uniqueSlug slugifies title and appends -2, -3... until the slug isn't used,
+// either currently or in the past, by any post other than id.
+func uniqueSlug(ctx context.Context, posts PostStore, title string, id primitive.ObjectID) (string, error) {
+    base := Slugify(title)
+    slug := base
+
+    for i := 2; ; i++ {
+        post, err := posts.GetPostBySlug(ctx, slug)
+        if errors.Is(err, ErrNotFound) || (err == nil && post.Id == id) {
+            return slug, nil
+        }
+        if err != nil {
+            return "", err
+        }
+
+        slug = fmt.Sprintf("%s-%d", base, i)
+    }
+}
+
+// slugAttempts is how many times a post is saved before giving up when its
+// slug keeps being taken by other posts saved at the same time.
+const slugAttempts = 5
+
+// retrySlug calls save, which picks a slug and saves the post under it, again
+// for as long as it fails with ErrDuplicate: slugs are unique, and another
+// post may have taken the one picked between checking and saving. Picking
+// again then moves on to the next free suffix.
+func retrySlug(save func() error) error {
+    var err error
+    for i := 0; i < slugAttempts; i++ {
+        if err = save(); !errors.Is(err, ErrDuplicate) {
+            return err
+        }
+    }
+    return err
+}
+
+// reslug works out the slugs of stored once its title becomes title. The
+// previous slug is remembered so links to it can be redirected.
+func reslug(ctx context.Context, posts PostStore, stored Post, title string) (string, []string, error) {
+    if stored.Slug != "" && stored.Title == title {
+        return stored.Slug, stored.OldSlugs, nil
+    }
+
+    slug, err := uniqueSlug(ctx, posts, title, stored.Id)
+    if err != nil {
+        return "", nil, err
+    }
+
+    var old []string
+    for _, s := range stored.OldSlugs {
+        if s != slug {
+            old = append(old, s)
+        }
+    }
+
+    if stored.Slug != "" && stored.Slug != slug {
+        old = append(old, stored.Slug)
+    }
+
+    return slug, old, nil
+}
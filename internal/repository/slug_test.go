@@ -0,0 +1,227 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "unicode/utf8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSlugify(t *testing.T) {
+    tests := []struct {
+        title string
+        want  string
+    }{
+        {"Hello World", "hello-world"},
+        {"  Hello,   World!  ", "hello-world"},
+        {"Go 1.22 released", "go-1-22-released"},
+        {"Café Crème brûlée", "cafe-creme-brulee"},
+        {"Ærø and Straße", "ærø-and-straße"},
+        {"ﬁle names", "file-names"},
+        {"Привет, мир", "привет-мир"},
+        {"日本語のブログ", "日本語のブログ"},
+        {"Ελληνικά!", "ελληνικά"},
+        {"Мой блог", "мой-блог"},
+        {"", "post"},
+        {"!!! ???", "post"},
+        {"́", "post"},
+    }
+    for _, test := range tests {
+        if got := Slugify(test.title); got != test.want {
+            t.Errorf("Slugify(%q) = %q, want %q", test.title, got, test.want)
+        }
+    }
+}
+
+func TestSlugifyTruncates(t *testing.T) {
+    tests := []struct {
+        name  string
+        title string
+    }{
+        {"words", strings.Repeat("word ", 40)},
+        {"dash at the cut", strings.Repeat("a", maxSlugLength-1) + " b"},
+        {"multibyte", strings.Repeat("ж", maxSlugLength)},
+        {"one long word", strings.Repeat("x", 200)},
+    }
+    for _, test := range tests {
+        got := Slugify(test.title)
+        if len(got) > maxSlugLength || !utf8.ValidString(got) || strings.HasSuffix(got, "-") || got == "" {
+            t.Errorf("%s: Slugify = %q (%d bytes)", test.name, got, len(got))
+        }
+    }
+    if got := Slugify(strings.Repeat("a", maxSlugLength-1) + " b"); got != strings.Repeat("a", maxSlugLength-1) {
+        t.Errorf("cut at a dash gave %q", got)
+    }
+}
+
+func TestReslug(t *testing.T) {
+    ctx := context.Background()
+    s := NewMemoryStore()
+
+    post, err := s.CreatePost(ctx, Post{Title: "First title"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    other, err := s.CreatePost(ctx, Post{Title: "Taken"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    steps := []struct {
+        title string
+        slug  string
+        old   []string
+    }{
+        // An unchanged title keeps everything.
+        {"First title", "first-title", nil},
+        {"Second title", "second-title", []string{"first-title"}},
+        {"Third title", "third-title", []string{"first-title", "second-title"}},
+        // Going back to a former slug takes it out of the old ones.
+        {"First title", "first-title", []string{"second-title", "third-title"}},
+        // A slug another post has gets a suffix.
+        {"Taken", "taken-2", []string{"second-title", "third-title", "first-title"}},
+    }
+    for _, step := range steps {
+        post.Title = step.title
+        post, err = s.UpdatePost(ctx, post)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if post.Slug != step.slug || !slices.Equal(post.OldSlugs, step.old) {
+            t.Errorf("titled %q: slug %q, old %q, want %q, %q", step.title, post.Slug, post.OldSlugs, step.slug, step.old)
+        }
+    }
+
+    // Every former slug still finds the post.
+    for _, slug := range post.OldSlugs {
+        if got, err := s.GetPostBySlug(ctx, slug); err != nil || got.Id != post.Id {
+            t.Errorf("%s found %+v, %v", slug, got, err)
+        }
+    }
+    if got, _ := s.GetPostBySlug(ctx, "taken"); got.Id != other.Id {
+        t.Errorf("taken found %q, want the post that had it first", got.Title)
+    }
+
+    // Nor can a new post take a former slug.
+    if third, err := s.CreatePost(ctx, Post{Title: "Second title"}); err != nil || third.Slug != "second-title-2" {
+        t.Errorf("new post got %q, %v, want second-title-2", third.Slug, err)
+    }
+}
+
+func TestRetrySlug(t *testing.T) {
+    calls := 0
+    err := retrySlug(func() error {
+        calls++
+        if calls < 3 {
+            return ErrDuplicate
+        }
+        return nil
+    })
+    if err != nil || calls != 3 {
+        t.Errorf("got %v after %d calls, want success on the third", err, calls)
+    }
+
+    calls = 0
+    err = retrySlug(func() error {
+        calls++
+        return ErrDuplicate
+    })
+    if !errors.Is(err, ErrDuplicate) || calls != slugAttempts {
+        t.Errorf("got %v after %d calls, want ErrDuplicate after %d", err, calls, slugAttempts)
+    }
+
+    calls = 0
+    if err := retrySlug(func() error { calls++; return ErrNotFound }); !errors.Is(err, ErrNotFound) || calls != 1 {
+        t.Errorf("got %v after %d calls, want other errors returned at once", err, calls)
+    }
+}
+
+func TestConcurrentSlugs(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        // Each retry means another post was saved, so this many never run
+        // out of attempts.
+        const n = slugAttempts
+
+        var wg sync.WaitGroup
+        slugs := make([]string, n)
+        errs := make([]error, n)
+        for i := 0; i < n; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                post, err := s.CreatePost(context.Background(), Post{Title: "Same title"})
+                slugs[i], errs[i] = post.Slug, err
+            }()
+        }
+        wg.Wait()
+
+        for _, err := range errs {
+            if err != nil {
+                t.Fatal(err)
+            }
+        }
+        slices.Sort(slugs)
+        if len(slices.Compact(slugs)) != n {
+            t.Errorf("slugs %q aren't unique", slugs)
+        }
+    })
+}
+
+func TestSQLiteUniqueSlugs(t *testing.T) {
+    ctx := context.Background()
+    path := filepath.Join(t.TempDir(), "blog.db")
+    s, err := NewSQLiteStore(ctx, path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    first, err := s.CreatePost(ctx, Post{Title: "Hello"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Saving a post under another's slug fails instead of replacing it.
+    second := first
+    second.Id = primitive.NewObjectID()
+    if err := s.insertPost(ctx, second); !errors.Is(err, ErrDuplicate) {
+        t.Errorf("inserting a taken slug: %v, want ErrDuplicate", err)
+    }
+    if _, err := s.GetPost(ctx, first.Id.Hex()); err != nil {
+        t.Errorf("first post is gone: %v", err)
+    }
+
+    // Databases from before slugs were unique may have posts sharing one.
+    // Undo the last migration, share a slug, and migrate again.
+    _, err = s.db.ExecContext(ctx, `DROP INDEX posts_slug;
+        CREATE INDEX posts_slug ON posts (slug);
+        PRAGMA user_version = `+strconv.Itoa(len(sqliteMigrations)-1))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := s.insertPost(ctx, second); err != nil {
+        t.Fatal(err)
+    }
+    s.Close(ctx)
+
+    s, err = NewSQLiteStore(ctx, path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer s.Close(ctx)
+
+    if got, _ := s.GetPost(ctx, first.Id.Hex()); got.Slug != "hello" {
+        t.Errorf("oldest post has slug %q, want hello kept", got.Slug)
+    }
+    if got, _ := s.GetPost(ctx, second.Id.Hex()); got.Slug != "hello-"+second.Id.Hex() {
+        t.Errorf("newer post has slug %q, want its id appended", got.Slug)
+    }
+    if err := s.insertPost(ctx, second); !errors.Is(err, ErrDuplicate) {
+        t.Errorf("slug isn't unique after migrating: %v", err)
+    }
+}
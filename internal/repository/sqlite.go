@@ -0,0 +1,1549 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order. PRAGMA user_version records how many
+// have already run, so new schema changes are only ever appended here.
+var sqliteMigrations = []string{
+    `CREATE TABLE posts (
+        id         TEXT PRIMARY KEY,
+        title      TEXT NOT NULL DEFAULT '',
+        body       TEXT NOT NULL DEFAULT '',
+        date       INTEGER NOT NULL,
+        synopsys   TEXT NOT NULL DEFAULT '',
+        likes      INTEGER NOT NULL DEFAULT 0,
+        comments   INTEGER NOT NULL DEFAULT 0,
+        coverimage TEXT NOT NULL DEFAULT ''
+    );
+    CREATE INDEX posts_date ON posts (date DESC);
+
+    CREATE TABLE portfolio (
+        id         TEXT PRIMARY KEY,
+        title      TEXT NOT NULL DEFAULT '',
+        date       INTEGER NOT NULL,
+        repo       TEXT NOT NULL DEFAULT '',
+        url        TEXT NOT NULL DEFAULT '',
+        coverimage TEXT NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE users (
+        id       TEXT PRIMARY KEY,
+        username TEXT NOT NULL UNIQUE,
+        password TEXT NOT NULL
+    );
+
+    CREATE TABLE sessions (
+        token   TEXT PRIMARY KEY,
+        user_id TEXT NOT NULL,
+        expires INTEGER NOT NULL
+    );`,
+    `ALTER TABLE posts ADD COLUMN slug TEXT NOT NULL DEFAULT '';
+    ALTER TABLE posts ADD COLUMN oldslugs TEXT NOT NULL DEFAULT '[]';
+    CREATE INDEX posts_slug ON posts (slug);`,
+    `ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+    ALTER TABLE posts ADD COLUMN publishat INTEGER NOT NULL DEFAULT 0;
+    UPDATE posts SET publishat = date;
+    CREATE INDEX posts_status_date ON posts (status, date DESC);`,
+    `ALTER TABLE posts ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+    ALTER TABLE posts ADD COLUMN category TEXT NOT NULL DEFAULT '';
+    CREATE INDEX posts_category ON posts (category);`,
+    `DROP INDEX posts_date;
+    CREATE INDEX posts_date_id ON posts (date DESC, id DESC);
+    DROP INDEX posts_status_date;
+    CREATE INDEX posts_status_date_id ON posts (status, date DESC, id DESC);
+    CREATE INDEX portfolio_date_id ON portfolio (date DESC, id DESC);`,
+    `CREATE TABLE comments (
+        id        TEXT PRIMARY KEY,
+        post_id   TEXT NOT NULL,
+        parent_id TEXT NOT NULL,
+        name      TEXT NOT NULL DEFAULT '',
+        email     TEXT NOT NULL DEFAULT '',
+        body      TEXT NOT NULL DEFAULT '',
+        date      INTEGER NOT NULL,
+        status    TEXT NOT NULL
+    );
+    CREATE INDEX comments_post ON comments (post_id, status, date);
+    CREATE INDEX comments_status ON comments (status, date);`,
+    `ALTER TABLE comments ADD COLUMN spam_score REAL NOT NULL DEFAULT 0;
+    ALTER TABLE comments ADD COLUMN spam_reasons TEXT NOT NULL DEFAULT '[]';
+    ALTER TABLE comments ADD COLUMN moderated_at INTEGER NOT NULL DEFAULT 0;`,
+    `CREATE TABLE likes (
+        post_id TEXT NOT NULL,
+        visitor TEXT NOT NULL,
+        ip_hash TEXT NOT NULL,
+        date    INTEGER NOT NULL,
+        PRIMARY KEY (post_id, visitor)
+    );
+    CREATE INDEX likes_ip ON likes (post_id, ip_hash, date);
+    CREATE INDEX likes_visitor ON likes (visitor);`,
+    `ALTER TABLE posts ADD COLUMN reactions TEXT NOT NULL DEFAULT '{}';
+    UPDATE posts SET reactions = json_object('` + LikeReaction + `', likes) WHERE likes > 0;
+    CREATE TABLE reactions (
+        post_id TEXT NOT NULL,
+        visitor TEXT NOT NULL,
+        emoji   TEXT NOT NULL,
+        ip_hash TEXT NOT NULL,
+        date    INTEGER NOT NULL,
+        PRIMARY KEY (post_id, visitor, emoji)
+    );
+    INSERT INTO reactions SELECT post_id, visitor, '` + LikeReaction + `', ip_hash, date FROM likes;
+    DROP TABLE likes;
+    CREATE INDEX reactions_ip ON reactions (post_id, emoji, ip_hash, date);
+    CREATE INDEX reactions_visitor ON reactions (visitor);`,
+    `ALTER TABLE posts ADD COLUMN updated INTEGER NOT NULL DEFAULT 0;`,
+    `ALTER TABLE posts ADD COLUMN meta_title TEXT NOT NULL DEFAULT '';
+    ALTER TABLE posts ADD COLUMN meta_description TEXT NOT NULL DEFAULT '';
+    ALTER TABLE posts ADD COLUMN canonical TEXT NOT NULL DEFAULT '';
+    ALTER TABLE posts ADD COLUMN noindex INTEGER NOT NULL DEFAULT 0;`,
+    // Users from before roles existed could all do anything, so they become
+    // owners.
+    `ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT '` + RoleOwner + `';
+    ALTER TABLE posts ADD COLUMN author_id TEXT NOT NULL DEFAULT '';
+    CREATE TABLE invites (
+        id         TEXT PRIMARY KEY,
+        token_hash TEXT NOT NULL UNIQUE,
+        role       TEXT NOT NULL,
+        created_by TEXT NOT NULL,
+        created    INTEGER NOT NULL,
+        expires    INTEGER NOT NULL,
+        used_by    TEXT NOT NULL DEFAULT '',
+        used_at    INTEGER NOT NULL DEFAULT 0
+    );`,
+    // Sessions get ids, made up for the ones already there, to be revoked by.
+    `ALTER TABLE sessions ADD COLUMN id TEXT NOT NULL DEFAULT '';
+    UPDATE sessions SET id = lower(hex(randomblob(12)));
+    ALTER TABLE sessions ADD COLUMN created INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE sessions ADD COLUMN last_seen INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+    ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+    CREATE UNIQUE INDEX sessions_id ON sessions (id);
+    CREATE INDEX sessions_user ON sessions (user_id, last_seen DESC);
+    CREATE INDEX sessions_expires ON sessions (expires);`,
+    `CREATE TABLE audit (
+        id      TEXT PRIMARY KEY,
+        time    INTEGER NOT NULL,
+        action  TEXT NOT NULL,
+        actor   TEXT NOT NULL DEFAULT '',
+        subject TEXT NOT NULL DEFAULT '',
+        ip      TEXT NOT NULL DEFAULT '',
+        detail  TEXT NOT NULL DEFAULT ''
+    );
+    CREATE INDEX audit_time ON audit (time DESC);`,
+    `ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+    ALTER TABLE users ADD COLUMN totp_step INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '[]';
+    CREATE TABLE settings (
+        id                 INTEGER PRIMARY KEY CHECK (id = 1),
+        require_two_factor INTEGER NOT NULL DEFAULT 0
+    );`,
+    `ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+    CREATE UNIQUE INDEX users_email ON users (email) WHERE email != '';
+    CREATE TABLE password_resets (
+        id         TEXT PRIMARY KEY,
+        token_hash TEXT NOT NULL UNIQUE,
+        user_id    TEXT NOT NULL,
+        created    INTEGER NOT NULL,
+        expires    INTEGER NOT NULL,
+        used_at    INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX password_resets_user ON password_resets (user_id);`,
+    `CREATE TABLE api_tokens (
+        id         TEXT PRIMARY KEY,
+        user_id    TEXT NOT NULL,
+        name       TEXT NOT NULL,
+        token_hash TEXT NOT NULL UNIQUE,
+        scopes     TEXT NOT NULL DEFAULT '[]',
+        created    INTEGER NOT NULL,
+        expires    INTEGER NOT NULL DEFAULT 0,
+        last_used  INTEGER NOT NULL DEFAULT 0,
+        last_ip    TEXT NOT NULL DEFAULT ''
+    );
+    CREATE INDEX api_tokens_user ON api_tokens (user_id, created DESC);`,
+    // Sessions from before their tokens were hashed can't be looked up any
+    // more, so they are ended.
+    `DELETE FROM sessions;`,
+    // Slugs become unique. Posts that already shared one leave it to the
+    // oldest of them and get their id appended.
+    `UPDATE posts SET slug = slug || '-' || id
+        WHERE slug != '' AND EXISTS (SELECT 1 FROM posts AS older WHERE older.slug = posts.slug AND older.id < posts.id);
+    DROP INDEX posts_slug;
+    CREATE UNIQUE INDEX posts_slug ON posts (slug) WHERE slug != '';`,
+}
+
+var registerSQLiteFuncs sync.Once
+
+// SQLiteStore keeps everything in a single SQLite file using a pure Go
+// driver, so it builds without cgo.
+type SQLiteStore struct {
+    db *sql.DB
+}
+
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+    var err error
+    registerSQLiteFuncs.Do(func() {
+        // SQLite's own lower() and LIKE only fold ASCII.
+        err = sqlite.RegisterDeterministicScalarFunction("unicode_lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+            s, _ := args[0].(string)
+            return strings.ToLower(s), nil
+        })
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+    db, err := sql.Open("sqlite", dsn)
+    if err != nil {
+        return nil, err
+    }
+
+    // A single connection serialises writers instead of failing with
+    // SQLITE_BUSY, which is plenty for a personal blog.
+    db.SetMaxOpenConns(1)
+
+    s := &SQLiteStore{db: db}
+    if err := s.migrate(ctx); err != nil {
+        db.Close()
+        return nil, err
+    }
+
+    return s, nil
+}
+
+func (s *SQLiteStore) migrate(ctx context.Context) error {
+    var version int
+    if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+        return err
+    }
+
+    for i := version; i < len(sqliteMigrations); i++ {
+        tx, err := s.db.BeginTx(ctx, nil)
+        if err != nil {
+            return err
+        }
+
+        if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+            tx.Rollback()
+            return fmt.Errorf("sqlite migration %d: %w", i+1, err)
+        }
+
+        if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+            tx.Rollback()
+            return err
+        }
+
+        if err := tx.Commit(); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func (s *SQLiteStore) Close(ctx context.Context) error {
+    return s.db.Close()
+}
+
+// toMillis stores times as Unix milliseconds, the precision Mongo keeps. The
+// zero time is stored as 0.
+func toMillis(t time.Time) int64 {
+    if t.IsZero() {
+        return 0
+    }
+    return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+    if ms == 0 {
+        return time.Time{}
+    }
+    return time.UnixMilli(ms)
+}
+
+// parseID turns a stored hex id back into an ObjectID.
+func parseID(hex string) primitive.ObjectID {
+    id, _ := primitive.ObjectIDFromHex(hex)
+    return id
+}
+
+func notFound(err error) error {
+    if errors.Is(err, sql.ErrNoRows) {
+        return ErrNotFound
+    }
+    return err
+}
+
+func isUniqueViolation(err error) bool {
+    return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// escapeLike escapes the LIKE wildcards in s using \ as the escape character.
+func escapeLike(s string) string {
+    r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+    return r.Replace(s)
+}
+
+func (s *SQLiteStore) CreateUser(ctx context.Context, user User) (User, error) {
+    if user.Id.IsZero() {
+        user.Id = primitive.NewObjectID()
+    }
+    _, err := s.db.ExecContext(ctx,
+        "INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)", userValues(user)...)
+    if isUniqueViolation(err) {
+        return user, ErrDuplicate
+    }
+    return user, err
+}
+
+const userColumns = "id, username, password, role, email, totp_secret, totp_step, recovery_codes"
+
+func userValues(user User) []any {
+    return []any{user.Id.Hex(), user.Username, user.Password, user.Role, user.Email, user.TOTPSecret, user.TOTPStep, jsonList(user.RecoveryCodes)}
+}
+
+func scanUser(row interface{ Scan(...any) error }) (User, error) {
+    var user User
+    var id, recoveryCodes string
+    err := row.Scan(&id, &user.Username, &user.Password, &user.Role, &user.Email, &user.TOTPSecret, &user.TOTPStep, &recoveryCodes)
+    if err != nil {
+        return user, notFound(err)
+    }
+    user.Id = parseID(id)
+    return user, json.Unmarshal([]byte(recoveryCodes), &user.RecoveryCodes)
+}
+
+func (s *SQLiteStore) GetUser(ctx context.Context, id primitive.ObjectID) (User, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", id.Hex())
+    return scanUser(row)
+}
+
+func (s *SQLiteStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = ?", username)
+    return scanUser(row)
+}
+
+func (s *SQLiteStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+    if email == "" {
+        return User{}, ErrNotFound
+    }
+    row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = ?", email)
+    return scanUser(row)
+}
+
+func (s *SQLiteStore) ListUsers(ctx context.Context) ([]User, error) {
+    rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY username")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var users []User
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            return nil, err
+        }
+        users = append(users, user)
+    }
+    return users, rows.Err()
+}
+
+func (s *SQLiteStore) CountUsers(ctx context.Context) (int, error) {
+    var count int
+    err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+    return count, err
+}
+
+func (s *SQLiteStore) SetUserRole(ctx context.Context, id primitive.ObjectID, role string) error {
+    result, err := s.db.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+    result, err := s.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hash, id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) SetUserEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+    result, err := s.db.ExecContext(ctx, "UPDATE users SET email = ? WHERE id = ?", email, id.Hex())
+    if isUniqueViolation(err) {
+        return ErrDuplicate
+    }
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+const resetColumns = "id, token_hash, user_id, created, expires, used_at"
+
+func scanPasswordReset(row interface{ Scan(...any) error }) (PasswordReset, error) {
+    var reset PasswordReset
+    var id, userId string
+    var created, expires, usedAt int64
+    err := row.Scan(&id, &reset.TokenHash, &userId, &created, &expires, &usedAt)
+    if err != nil {
+        return reset, notFound(err)
+    }
+
+    reset.Id = parseID(id)
+    reset.UserId = parseID(userId)
+    reset.Created = fromMillis(created)
+    reset.Expires = fromMillis(expires)
+    reset.UsedAt = fromMillis(usedAt)
+    return reset, nil
+}
+
+func insertPasswordReset(ctx context.Context, db execer, reset PasswordReset) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO password_resets ("+resetColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+        reset.Id.Hex(), reset.TokenHash, reset.UserId.Hex(), toMillis(reset.Created),
+        toMillis(reset.Expires), toMillis(reset.UsedAt))
+    return err
+}
+
+func (s *SQLiteStore) CreatePasswordReset(ctx context.Context, reset PasswordReset) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ?", reset.UserId.Hex()); err != nil {
+        return err
+    }
+    if reset.Id.IsZero() {
+        reset.Id = primitive.NewObjectID()
+    }
+    if err := insertPasswordReset(ctx, tx, reset); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+func (s *SQLiteStore) GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+resetColumns+" FROM password_resets WHERE token_hash = ?", tokenHash)
+    return scanPasswordReset(row)
+}
+
+func (s *SQLiteStore) UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (PasswordReset, error) {
+    row := s.db.QueryRowContext(ctx,
+        `UPDATE password_resets SET used_at = ?
+        WHERE token_hash = ? AND used_at = 0 AND expires > ?
+        RETURNING `+resetColumns,
+        toMillis(now), tokenHash, toMillis(now))
+    reset, err := scanPasswordReset(row)
+    if errors.Is(err, ErrNotFound) {
+        return reset, ErrInvalidReset
+    }
+    return reset, err
+}
+
+func (s *SQLiteStore) DeleteUserPasswordResets(ctx context.Context, user primitive.ObjectID) error {
+    _, err := s.db.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ?", user.Hex())
+    return err
+}
+
+func (s *SQLiteStore) SetTwoFactor(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error {
+    result, err := s.db.ExecContext(ctx,
+        "UPDATE users SET totp_secret = ?, totp_step = 0, recovery_codes = ? WHERE id = ?",
+        secret, jsonList(recoveryCodes), id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, recoveryCodes []string) error {
+    result, err := s.db.ExecContext(ctx, "UPDATE users SET recovery_codes = ? WHERE id = ?", jsonList(recoveryCodes), id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+    result, err := s.db.ExecContext(ctx, "UPDATE users SET totp_step = ? WHERE id = ? AND totp_step < ?", step, id.Hex(), step)
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrCodeUsed
+    }
+    return nil
+}
+
+func (s *SQLiteStore) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+    result, err := s.db.ExecContext(ctx,
+        `UPDATE users SET recovery_codes = (
+            SELECT json_group_array(value) FROM json_each(recovery_codes) WHERE value != ?
+        )
+        WHERE id = ? AND EXISTS (SELECT 1 FROM json_each(recovery_codes) WHERE value = ?)`,
+        hash, id.Hex(), hash)
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) GetSettings(ctx context.Context) (SiteSettings, error) {
+    var settings SiteSettings
+    err := s.db.QueryRowContext(ctx, "SELECT require_two_factor FROM settings WHERE id = 1").Scan(&settings.RequireTwoFactor)
+    if errors.Is(err, sql.ErrNoRows) {
+        return SiteSettings{}, nil
+    }
+    return settings, err
+}
+
+func saveSettings(ctx context.Context, db execer, settings SiteSettings) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO settings (id, require_two_factor) VALUES (1, ?)", settings.RequireTwoFactor)
+    return err
+}
+
+func (s *SQLiteStore) SaveSettings(ctx context.Context, settings SiteSettings) error {
+    return saveSettings(ctx, s.db, settings)
+}
+
+const inviteColumns = "id, token_hash, role, created_by, created, expires, used_by, used_at"
+
+func scanInvite(row interface{ Scan(...any) error }) (Invite, error) {
+    var invite Invite
+    var id, createdBy, usedBy string
+    var created, expires, usedAt int64
+    err := row.Scan(&id, &invite.TokenHash, &invite.Role, &createdBy, &created, &expires, &usedBy, &usedAt)
+    if err != nil {
+        return invite, notFound(err)
+    }
+
+    invite.Id = parseID(id)
+    invite.CreatedBy = parseID(createdBy)
+    invite.Created = fromMillis(created)
+    invite.Expires = fromMillis(expires)
+    invite.UsedBy = parseID(usedBy)
+    invite.UsedAt = fromMillis(usedAt)
+    return invite, nil
+}
+
+func insertInvite(ctx context.Context, db execer, invite Invite) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO invites ("+inviteColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+        invite.Id.Hex(), invite.TokenHash, invite.Role, invite.CreatedBy.Hex(), toMillis(invite.Created),
+        toMillis(invite.Expires), invite.UsedBy.Hex(), toMillis(invite.UsedAt))
+    return err
+}
+
+func (s *SQLiteStore) CreateInvite(ctx context.Context, invite Invite) (Invite, error) {
+    invite.Id = primitive.NewObjectID()
+    return invite, insertInvite(ctx, s.db, invite)
+}
+
+func (s *SQLiteStore) Invites(ctx context.Context) ([]Invite, error) {
+    rows, err := s.db.QueryContext(ctx, "SELECT "+inviteColumns+" FROM invites ORDER BY created DESC")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var invites []Invite
+    for rows.Next() {
+        invite, err := scanInvite(rows)
+        if err != nil {
+            return nil, err
+        }
+        invites = append(invites, invite)
+    }
+    return invites, rows.Err()
+}
+
+func (s *SQLiteStore) GetInvite(ctx context.Context, tokenHash string) (Invite, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+inviteColumns+" FROM invites WHERE token_hash = ?", tokenHash)
+    return scanInvite(row)
+}
+
+func (s *SQLiteStore) UseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID, now time.Time) (Invite, error) {
+    row := s.db.QueryRowContext(ctx,
+        `UPDATE invites SET used_by = ?, used_at = ?
+        WHERE token_hash = ? AND used_at = 0 AND expires > ?
+        RETURNING `+inviteColumns,
+        user.Hex(), toMillis(now), tokenHash, toMillis(now))
+    invite, err := scanInvite(row)
+    if errors.Is(err, ErrNotFound) {
+        return invite, ErrInvalidInvite
+    }
+    return invite, err
+}
+
+func (s *SQLiteStore) ReleaseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID) error {
+    result, err := s.db.ExecContext(ctx,
+        "UPDATE invites SET used_by = '', used_at = 0 WHERE token_hash = ? AND used_by = ?",
+        tokenHash, user.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) DeleteInvite(ctx context.Context, id primitive.ObjectID) error {
+    result, err := s.db.ExecContext(ctx, "DELETE FROM invites WHERE id = ?", id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+const auditColumns = "id, time, action, actor, subject, ip, detail"
+
+func insertAuditEntry(ctx context.Context, db execer, entry AuditEntry) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO audit ("+auditColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+        entry.Id.Hex(), toMillis(entry.Time), entry.Action, entry.Actor, entry.Subject, entry.IP, entry.Detail)
+    return err
+}
+
+func (s *SQLiteStore) Audit(ctx context.Context, entry AuditEntry) error {
+    entry.Id = primitive.NewObjectID()
+    return insertAuditEntry(ctx, s.db, entry)
+}
+
+func (s *SQLiteStore) AuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+    rows, err := s.db.QueryContext(ctx,
+        "SELECT "+auditColumns+" FROM audit ORDER BY time DESC, id DESC LIMIT ?", limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var entries []AuditEntry
+    for rows.Next() {
+        var entry AuditEntry
+        var id string
+        var t int64
+        err := rows.Scan(&id, &t, &entry.Action, &entry.Actor, &entry.Subject, &entry.IP, &entry.Detail)
+        if err != nil {
+            return nil, err
+        }
+        entry.Id = parseID(id)
+        entry.Time = fromMillis(t)
+        entries = append(entries, entry)
+    }
+    return entries, rows.Err()
+}
+
+const sessionColumns = "id, token, user_id, created, expires, last_seen, user_agent, ip"
+
+func scanSession(row interface{ Scan(...any) error }) (Session, error) {
+    var session Session
+    var id, userId string
+    var created, expires, lastSeen int64
+    err := row.Scan(&id, &session.TokenHash, &userId, &created, &expires, &lastSeen, &session.UserAgent, &session.IP)
+    if err != nil {
+        return session, notFound(err)
+    }
+
+    session.Id = parseID(id)
+    session.UserId = parseID(userId)
+    session.Created = fromMillis(created)
+    session.Expires = fromMillis(expires)
+    session.LastSeen = fromMillis(lastSeen)
+    return session, nil
+}
+
+func insertSession(ctx context.Context, db execer, session Session) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+        session.Id.Hex(), session.TokenHash, session.UserId.Hex(), toMillis(session.Created),
+        toMillis(session.Expires), toMillis(session.LastSeen), session.UserAgent, session.IP)
+    return err
+}
+
+func (s *SQLiteStore) CreateSession(ctx context.Context, session Session) error {
+    if session.Id.IsZero() {
+        session.Id = primitive.NewObjectID()
+    }
+    return insertSession(ctx, s.db, session)
+}
+
+func (s *SQLiteStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+    return scanSession(s.db.QueryRowContext(ctx,
+        "SELECT "+sessionColumns+" FROM sessions WHERE token = ?", tokenHash))
+}
+
+func (s *SQLiteStore) TouchSession(ctx context.Context, tokenHash string, seen, expires time.Time) error {
+    result, err := s.db.ExecContext(ctx,
+        "UPDATE sessions SET last_seen = ?, expires = ? WHERE token = ?",
+        toMillis(seen), toMillis(expires), tokenHash)
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) UserSessions(ctx context.Context, user primitive.ObjectID) ([]Session, error) {
+    rows, err := s.db.QueryContext(ctx,
+        "SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? ORDER BY last_seen DESC", user.Hex())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var sessions []Session
+    for rows.Next() {
+        session, err := scanSession(rows)
+        if err != nil {
+            return nil, err
+        }
+        sessions = append(sessions, session)
+    }
+    return sessions, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteSession(ctx context.Context, tokenHash string) error {
+    result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE token = ?", tokenHash)
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) RevokeSession(ctx context.Context, user, id primitive.ObjectID) error {
+    result, err := s.db.ExecContext(ctx,
+        "DELETE FROM sessions WHERE id = ? AND user_id = ?", id.Hex(), user.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) DeleteUserSessions(ctx context.Context, user primitive.ObjectID) (int, error) {
+    result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = ?", user.Hex())
+    if err != nil {
+        return 0, err
+    }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
+
+func (s *SQLiteStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+    result, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires < ?", toMillis(now))
+    if err != nil {
+        return 0, err
+    }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
+
+const tokenColumns = "id, user_id, name, token_hash, scopes, created, expires, last_used, last_ip"
+
+func scanAPIToken(row interface{ Scan(...any) error }) (APIToken, error) {
+    var token APIToken
+    var id, userId, scopes string
+    var created, expires, lastUsed int64
+    err := row.Scan(&id, &userId, &token.Name, &token.TokenHash, &scopes, &created, &expires, &lastUsed, &token.LastIP)
+    if err != nil {
+        return token, notFound(err)
+    }
+
+    token.Id = parseID(id)
+    token.UserId = parseID(userId)
+    token.Created = fromMillis(created)
+    token.Expires = fromMillis(expires)
+    token.LastUsed = fromMillis(lastUsed)
+    err = json.Unmarshal([]byte(scopes), &token.Scopes)
+    return token, err
+}
+
+func insertAPIToken(ctx context.Context, db execer, token APIToken) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO api_tokens ("+tokenColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+        token.Id.Hex(), token.UserId.Hex(), token.Name, token.TokenHash, jsonList(token.Scopes),
+        toMillis(token.Created), toMillis(token.Expires), toMillis(token.LastUsed), token.LastIP)
+    return err
+}
+
+func (s *SQLiteStore) CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error) {
+    if token.Id.IsZero() {
+        token.Id = primitive.NewObjectID()
+    }
+    return token, insertAPIToken(ctx, s.db, token)
+}
+
+func (s *SQLiteStore) GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error) {
+    return scanAPIToken(s.db.QueryRowContext(ctx,
+        "SELECT "+tokenColumns+" FROM api_tokens WHERE token_hash = ?", tokenHash))
+}
+
+func (s *SQLiteStore) UserAPITokens(ctx context.Context, user primitive.ObjectID) ([]APIToken, error) {
+    rows, err := s.db.QueryContext(ctx,
+        "SELECT "+tokenColumns+" FROM api_tokens WHERE user_id = ? ORDER BY created DESC, id DESC", user.Hex())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var tokens []APIToken
+    for rows.Next() {
+        token, err := scanAPIToken(rows)
+        if err != nil {
+            return nil, err
+        }
+        tokens = append(tokens, token)
+    }
+    return tokens, rows.Err()
+}
+
+func (s *SQLiteStore) TouchAPIToken(ctx context.Context, id primitive.ObjectID, when time.Time, ip string) error {
+    result, err := s.db.ExecContext(ctx,
+        "UPDATE api_tokens SET last_used = ?, last_ip = ? WHERE id = ?", toMillis(when), ip, id.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (s *SQLiteStore) RevokeAPIToken(ctx context.Context, user, id primitive.ObjectID) error {
+    result, err := s.db.ExecContext(ctx,
+        "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id.Hex(), user.Hex())
+    if err != nil {
+        return err
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+const postColumns = "id, title, body, date, synopsys, likes, comments, coverimage, slug, oldslugs, status, publishat, tags, category, reactions, updated, " +
+    "meta_title, meta_description, canonical, noindex, author_id"
+
+func scanPost(row interface{ Scan(...any) error }) (Post, error) {
+    var post Post
+    var id, oldSlugs, tags, reactions, authorId string
+    var date, publishAt, updated int64
+    err := row.Scan(&id, &post.Title, &post.Body, &date, &post.Synopsys, &post.Likes, &post.Comments, &post.CoverImage,
+        &post.Slug, &oldSlugs, &post.Status, &publishAt, &tags, &post.Category, &reactions, &updated,
+        &post.SEO.Title, &post.SEO.Description, &post.SEO.Canonical, &post.SEO.NoIndex, &authorId)
+    if err != nil {
+        return post, notFound(err)
+    }
+
+    post.Id = parseID(id)
+    post.AuthorId = parseID(authorId)
+    post.Date = fromMillis(date)
+    post.PublishAt = fromMillis(publishAt)
+    post.Updated = fromMillis(updated)
+    if err := json.Unmarshal([]byte(oldSlugs), &post.OldSlugs); err != nil {
+        return post, err
+    }
+    if err := json.Unmarshal([]byte(tags), &post.Tags); err != nil {
+        return post, err
+    }
+    if reactions != "{}" {
+        err = json.Unmarshal([]byte(reactions), &post.Reactions)
+    }
+    return post, err
+}
+
+// jsonList stores a string slice as a JSON array, never as null.
+func jsonList(list []string) string {
+    if len(list) == 0 {
+        return "[]"
+    }
+    b, _ := json.Marshal(list)
+    return string(b)
+}
+
+// jsonCounts stores a map of counts as a JSON object, never as null.
+func jsonCounts(counts map[string]int) string {
+    if len(counts) == 0 {
+        return "{}"
+    }
+    b, _ := json.Marshal(counts)
+    return string(b)
+}
+
+func (s *SQLiteStore) queryPosts(ctx context.Context, query string, args ...any) ([]Post, error) {
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var posts []Post
+    for rows.Next() {
+        post, err := scanPost(rows)
+        if err != nil {
+            return posts, err
+        }
+        posts = append(posts, post)
+    }
+
+    return posts, rows.Err()
+}
+
+// insertPost stores post as it is, overwriting the post with the same id. It
+// fails with ErrDuplicate when another post has its slug; unlike INSERT OR
+// REPLACE, which would delete that post.
+func (s *SQLiteStore) insertPost(ctx context.Context, post Post) error {
+    _, err := s.db.ExecContext(ctx,
+        "INSERT INTO posts ("+postColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+            " ON CONFLICT (id) DO UPDATE SET "+setExcluded(postColumns),
+        post.Id.Hex(), post.Title, post.Body, toMillis(post.Date), post.Synopsys, post.Likes, post.Comments, post.CoverImage,
+        post.Slug, jsonList(post.OldSlugs), post.Status, toMillis(post.PublishAt), jsonList(post.Tags), post.Category,
+        jsonCounts(post.Reactions), toMillis(post.Updated),
+        post.SEO.Title, post.SEO.Description, post.SEO.Canonical, post.SEO.NoIndex, post.AuthorId.Hex())
+    if isUniqueViolation(err) {
+        return ErrDuplicate
+    }
+    return err
+}
+
+// setExcluded sets each of columns but id to the value being inserted, for
+// upserts.
+func setExcluded(columns string) string {
+    var set []string
+    for _, column := range strings.Split(columns, ", ") {
+        if column != "id" {
+            set = append(set, column+" = excluded."+column)
+        }
+    }
+    return strings.Join(set, ", ")
+}
+
+func (s *SQLiteStore) CreatePost(ctx context.Context, post Post) (Post, error) {
+    post, err := lifecycle(Post{}, taxonomy(post), time.Now())
+    if err != nil {
+        return post, err
+    }
+    post.Id = primitive.NewObjectID()
+    post.OldSlugs = nil
+
+    err = retrySlug(func() error {
+        slug, err := uniqueSlug(ctx, s, post.Title, post.Id)
+        if err != nil {
+            return err
+        }
+        post.Slug = slug
+        return s.insertPost(ctx, post)
+    })
+    return post, err
+}
+
+func (s *SQLiteStore) GetPosts(ctx context.Context) ([]Post, error) {
+    return s.QueryPosts(ctx, PostQuery{})
+}
+
+func (s *SQLiteStore) QueryPosts(ctx context.Context, q PostQuery) ([]Post, error) {
+    where, args := postWhere(q)
+    query := "SELECT " + postColumns + " FROM posts" + where + " ORDER BY date DESC, id DESC"
+    if q.Limit > 0 {
+        query += " LIMIT ?"
+        args = append(args, q.Limit)
+    }
+    return s.queryPosts(ctx, query, args...)
+}
+
+// afterCursorClause selects the rows that come after c when ordered newest
+// first.
+func afterCursorClause(c Cursor) (string, []any) {
+    return "(date < ? OR (date = ? AND id < ?))", []any{toMillis(c.Date), toMillis(c.Date), c.Id.Hex()}
+}
+
+// postWhere builds the WHERE clause, if any, selecting the posts matching q.
+func postWhere(q PostQuery) (string, []any) {
+    var where []string
+    var args []any
+
+    if !q.After.IsZero() {
+        clause, values := afterCursorClause(q.After)
+        where = append(where, clause)
+        args = append(args, values...)
+    }
+
+    if q.TitlePrefix != "" {
+        where = append(where, `unicode_lower(title) LIKE ? ESCAPE '\'`)
+        args = append(args, escapeLike(strings.ToLower(q.TitlePrefix))+"%")
+    }
+
+    if q.Status != "" {
+        where = append(where, "status = ?")
+        args = append(args, q.Status)
+    }
+
+    if q.Tag != "" {
+        where = append(where, "EXISTS (SELECT 1 FROM json_each(posts.tags) WHERE value = ?)")
+        args = append(args, q.Tag)
+    }
+
+    if q.Category != "" {
+        where = append(where, "category = ?")
+        args = append(args, q.Category)
+    }
+
+    if q.Ids != nil {
+        ids := make([]string, len(q.Ids))
+        for i, id := range q.Ids {
+            ids[i] = id.Hex()
+        }
+        where = append(where, "id IN (SELECT value FROM json_each(?))")
+        args = append(args, jsonList(ids))
+    }
+
+    if len(where) == 0 {
+        return "", nil
+    }
+    return " WHERE " + strings.Join(where, " AND "), args
+}
+
+func (s *SQLiteStore) TagCounts(ctx context.Context, q PostQuery) ([]TagCount, error) {
+    where, args := postWhere(q)
+    rows, err := s.db.QueryContext(ctx, `SELECT tag.value, COUNT(*) FROM posts, json_each(posts.tags) AS tag`+where+`
+        GROUP BY tag.value ORDER BY COUNT(*) DESC, tag.value`, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var counts []TagCount
+    for rows.Next() {
+        var count TagCount
+        if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+            return counts, err
+        }
+        counts = append(counts, count)
+    }
+
+    return counts, rows.Err()
+}
+
+func (s *SQLiteStore) GetPost(ctx context.Context, id string) (Post, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return Post{}, err
+    }
+
+    row := s.db.QueryRowContext(ctx, "SELECT "+postColumns+" FROM posts WHERE id = ?", objectId.Hex())
+    return scanPost(row)
+}
+
+func (s *SQLiteStore) GetPostBySlug(ctx context.Context, slug string) (Post, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+postColumns+` FROM posts
+        WHERE slug = ? OR EXISTS (SELECT 1 FROM json_each(posts.oldslugs) WHERE value = ?)
+        ORDER BY slug = ? DESC LIMIT 1`, slug, slug, slug)
+    return scanPost(row)
+}
+
+func (s *SQLiteStore) UpdatePost(ctx context.Context, p Post) (Post, error) {
+    stored, err := s.GetPost(ctx, p.Id.Hex())
+    if err != nil {
+        return Post{}, err
+    }
+
+    p, err = lifecycle(stored, taxonomy(p), time.Now())
+    if err != nil {
+        return Post{}, err
+    }
+
+    err = retrySlug(func() error {
+        slug, oldSlugs, err := reslug(ctx, s, stored, p.Title)
+        if err != nil {
+            return err
+        }
+
+        result, err := s.db.ExecContext(ctx,
+            `UPDATE posts SET title = ?, body = ?, synopsys = ?, coverimage = ?, slug = ?, oldslugs = ?,
+                status = ?, publishat = ?, date = ?, updated = ?, tags = ?, category = ?,
+                meta_title = ?, meta_description = ?, canonical = ?, noindex = ?
+            WHERE id = ?`,
+            p.Title, p.Body, p.Synopsys, p.CoverImage, slug, jsonList(oldSlugs),
+            p.Status, toMillis(p.PublishAt), toMillis(p.Date), toMillis(p.Updated), jsonList(p.Tags), p.Category,
+            p.SEO.Title, p.SEO.Description, p.SEO.Canonical, p.SEO.NoIndex, p.Id.Hex())
+        if isUniqueViolation(err) {
+            return ErrDuplicate
+        }
+        if err != nil {
+            return err
+        }
+
+        if n, _ := result.RowsAffected(); n == 0 {
+            return ErrNotFound
+        }
+        return nil
+    })
+    if err != nil {
+        return Post{}, err
+    }
+
+    return s.GetPost(ctx, p.Id.Hex())
+}
+
+func (s *SQLiteStore) DeletePost(ctx context.Context, id string) error {
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.ExecContext(ctx, "DELETE FROM posts WHERE id = ?", objectID.Hex())
+    if err != nil {
+        return err
+    }
+
+    if n, _ := result.RowsAffected(); n == 0 {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    if _, err := tx.ExecContext(ctx, "DELETE FROM comments WHERE post_id = ?", objectID.Hex()); err != nil {
+        return err
+    }
+
+    if _, err := tx.ExecContext(ctx, "DELETE FROM reactions WHERE post_id = ?", objectID.Hex()); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+func insertReaction(ctx context.Context, db execer, reaction Reaction) (bool, error) {
+    result, err := db.ExecContext(ctx,
+        "INSERT OR IGNORE INTO reactions (post_id, visitor, emoji, ip_hash, date) VALUES (?, ?, ?, ?, ?)",
+        reaction.PostId.Hex(), reaction.Visitor, reaction.Emoji, reaction.IPHash, toMillis(reaction.Date))
+    if err != nil {
+        return false, err
+    }
+    n, err := result.RowsAffected()
+    return n > 0, err
+}
+
+// countReaction is the statement counting one more (or, with -1, one less)
+// reaction with an emoji on a post, never going below zero. It takes the
+// delta, the emoji's JSON path and the post id.
+const countReaction = `UPDATE posts SET
+    likes = MAX(likes + ?1, 0),
+    reactions = json_set(reactions, ?2, MAX(COALESCE(json_extract(reactions, ?2), 0) + ?1, 0))
+    WHERE id = ?3`
+
+// reactionPath is the JSON path of an emoji in the reactions object.
+func reactionPath(emoji string) string {
+    return `$."` + emoji + `"`
+}
+
+func (s *SQLiteStore) React(ctx context.Context, reaction Reaction, since time.Time) (Post, error) {
+    post, err := s.GetPost(ctx, reaction.PostId.Hex())
+    if err != nil {
+        return post, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return post, err
+    }
+    defer tx.Rollback()
+
+    var recent int
+    err = tx.QueryRowContext(ctx,
+        "SELECT COUNT(*) FROM reactions WHERE post_id = ? AND emoji = ? AND ip_hash = ? AND date >= ?",
+        reaction.PostId.Hex(), reaction.Emoji, reaction.IPHash, toMillis(since)).Scan(&recent)
+    if err != nil {
+        return post, err
+    }
+    if recent > 0 {
+        return post, ErrAlreadyReacted
+    }
+
+    inserted, err := insertReaction(ctx, tx, reaction)
+    if err != nil {
+        return post, err
+    }
+    if !inserted {
+        return post, ErrAlreadyReacted
+    }
+
+    if _, err := tx.ExecContext(ctx, countReaction, 1, reactionPath(reaction.Emoji), reaction.PostId.Hex()); err != nil {
+        return post, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return post, err
+    }
+    return s.GetPost(ctx, reaction.PostId.Hex())
+}
+
+func (s *SQLiteStore) Unreact(ctx context.Context, postId primitive.ObjectID, visitor, emoji string) (Post, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return Post{}, err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.ExecContext(ctx, "DELETE FROM reactions WHERE post_id = ? AND visitor = ? AND emoji = ?",
+        postId.Hex(), visitor, emoji)
+    if err != nil {
+        return Post{}, err
+    }
+
+    if n, _ := result.RowsAffected(); n > 0 {
+        if _, err := tx.ExecContext(ctx, countReaction, -1, reactionPath(emoji), postId.Hex()); err != nil {
+            return Post{}, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return Post{}, err
+    }
+    return s.GetPost(ctx, postId.Hex())
+}
+
+func (s *SQLiteStore) VisitorReactions(ctx context.Context, visitor string) ([]Reaction, error) {
+    rows, err := s.db.QueryContext(ctx,
+        "SELECT post_id, visitor, emoji, ip_hash, date FROM reactions WHERE visitor = ?", visitor)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var reactions []Reaction
+    for rows.Next() {
+        var reaction Reaction
+        var postId string
+        var date int64
+        if err := rows.Scan(&postId, &reaction.Visitor, &reaction.Emoji, &reaction.IPHash, &date); err != nil {
+            return reactions, err
+        }
+        reaction.PostId = parseID(postId)
+        reaction.Date = fromMillis(date)
+        reactions = append(reactions, reaction)
+    }
+
+    return reactions, rows.Err()
+}
+
+func (s *SQLiteStore) PublishDue(ctx context.Context, now time.Time) ([]Post, error) {
+    due, err := s.queryPosts(ctx, "SELECT "+postColumns+" FROM posts WHERE status = ? AND publishat <= ?",
+        StatusScheduled, toMillis(now))
+    if err != nil {
+        return nil, err
+    }
+
+    var published []Post
+    for _, post := range due {
+        result, err := s.db.ExecContext(ctx,
+            "UPDATE posts SET status = ?, date = publishat, updated = ? WHERE id = ? AND status = ?",
+            StatusPublished, toMillis(now), post.Id.Hex(), StatusScheduled)
+        if err != nil {
+            return published, err
+        }
+
+        if n, _ := result.RowsAffected(); n > 0 {
+            post.Status = StatusPublished
+            post.Date = post.PublishAt
+            post.Updated = now
+            published = append(published, post)
+        }
+    }
+
+    return published, nil
+}
+
+const commentColumns = "id, post_id, parent_id, name, email, body, date, status, spam_score, spam_reasons, moderated_at"
+
+func scanComment(row interface{ Scan(...any) error }) (Comment, error) {
+    var comment Comment
+    var id, postId, parentId, reasons string
+    var date, moderatedAt int64
+    err := row.Scan(&id, &postId, &parentId, &comment.Name, &comment.Email, &comment.Body, &date, &comment.Status,
+        &comment.Verdict.Score, &reasons, &moderatedAt)
+    if err != nil {
+        return comment, notFound(err)
+    }
+
+    comment.Id = parseID(id)
+    comment.PostId = parseID(postId)
+    comment.ParentId = parseID(parentId)
+    comment.Date = fromMillis(date)
+    comment.ModeratedAt = fromMillis(moderatedAt)
+    err = json.Unmarshal([]byte(reasons), &comment.Verdict.Reasons)
+    return comment, err
+}
+
+// execer is what *sql.DB and *sql.Tx share for writing.
+type execer interface {
+    ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertComment(ctx context.Context, db execer, comment Comment) error {
+    _, err := db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO comments ("+commentColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+        comment.Id.Hex(), comment.PostId.Hex(), comment.ParentId.Hex(), comment.Name, comment.Email, comment.Body,
+        toMillis(comment.Date), comment.Status, comment.Verdict.Score, jsonList(comment.Verdict.Reasons),
+        toMillis(comment.ModeratedAt))
+    return err
+}
+
+// recountComments is the statement setting the comment count of the post
+// with the given id to its number of approved comments.
+const recountComments = `UPDATE posts SET comments =
+    (SELECT COUNT(*) FROM comments WHERE post_id = posts.id AND status = '` + CommentApproved + `')
+    WHERE id = ?`
+
+func (s *SQLiteStore) CreateComment(ctx context.Context, comment Comment) (Comment, error) {
+    comment, err := newComment(comment)
+    if err != nil {
+        return comment, err
+    }
+
+    if _, err := s.GetPost(ctx, comment.PostId.Hex()); err != nil {
+        return comment, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return comment, err
+    }
+    defer tx.Rollback()
+
+    if err := insertComment(ctx, tx, comment); err != nil {
+        return comment, err
+    }
+
+    if _, err := tx.ExecContext(ctx, recountComments, comment.PostId.Hex()); err != nil {
+        return comment, err
+    }
+
+    return comment, tx.Commit()
+}
+
+func (s *SQLiteStore) GetComment(ctx context.Context, id primitive.ObjectID) (Comment, error) {
+    row := s.db.QueryRowContext(ctx, "SELECT "+commentColumns+" FROM comments WHERE id = ?", id.Hex())
+    return scanComment(row)
+}
+
+func (s *SQLiteStore) QueryComments(ctx context.Context, q CommentQuery) ([]Comment, error) {
+    var where []string
+    var args []any
+
+    if !q.PostId.IsZero() {
+        where = append(where, "post_id = ?")
+        args = append(args, q.PostId.Hex())
+    }
+
+    if q.Status != "" {
+        where = append(where, "status = ?")
+        args = append(args, q.Status)
+    }
+
+    query := "SELECT " + commentColumns + " FROM comments"
+    if len(where) > 0 {
+        query += " WHERE " + strings.Join(where, " AND ")
+    }
+    query += " ORDER BY date, id"
+
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var comments []Comment
+    for rows.Next() {
+        comment, err := scanComment(rows)
+        if err != nil {
+            return comments, err
+        }
+        comments = append(comments, comment)
+    }
+
+    return comments, rows.Err()
+}
+
+func (s *SQLiteStore) SetCommentStatus(ctx context.Context, id primitive.ObjectID, status string) (Comment, error) {
+    if err := checkCommentStatus(status); err != nil {
+        return Comment{}, err
+    }
+
+    comment, err := s.GetComment(ctx, id)
+    if err != nil {
+        return comment, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return comment, err
+    }
+    defer tx.Rollback()
+
+    moderatedAt := time.Now()
+    if _, err := tx.ExecContext(ctx, "UPDATE comments SET status = ?, moderated_at = ? WHERE id = ?",
+        status, toMillis(moderatedAt), id.Hex()); err != nil {
+        return comment, err
+    }
+
+    if _, err := tx.ExecContext(ctx, recountComments, comment.PostId.Hex()); err != nil {
+        return comment, err
+    }
+
+    comment.Status = status
+    comment.ModeratedAt = moderatedAt
+    return comment, tx.Commit()
+}
+
+const entryColumns = "id, title, date, repo, url, coverimage"
+
+func scanEntry(row interface{ Scan(...any) error }) (PortfolioEntry, error) {
+    var entry PortfolioEntry
+    var id string
+    var date int64
+    err := row.Scan(&id, &entry.Title, &date, &entry.Repo, &entry.Url, &entry.CoverImage)
+    entry.Id = parseID(id)
+    entry.Date = fromMillis(date)
+    return entry, notFound(err)
+}
+
+func (s *SQLiteStore) GetPortfolioEntries(ctx context.Context, page Page) ([]PortfolioEntry, error) {
+    query := "SELECT " + entryColumns + " FROM portfolio"
+    var args []any
+
+    if !page.After.IsZero() {
+        clause, values := afterCursorClause(page.After)
+        query += " WHERE " + clause
+        args = values
+    }
+
+    query += " ORDER BY date DESC, id DESC"
+    if page.Limit > 0 {
+        query += " LIMIT ?"
+        args = append(args, page.Limit)
+    }
+
+    rows, err := s.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var entries []PortfolioEntry
+    for rows.Next() {
+        entry, err := scanEntry(rows)
+        if err != nil {
+            return entries, err
+        }
+        entries = append(entries, entry)
+    }
+
+    return entries, rows.Err()
+}
+
+func (s *SQLiteStore) GetEntry(ctx context.Context, id string) (PortfolioEntry, error) {
+    objectId, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return PortfolioEntry{}, err
+    }
+
+    row := s.db.QueryRowContext(ctx, "SELECT "+entryColumns+" FROM portfolio WHERE id = ?", objectId.Hex())
+    return scanEntry(row)
+}
+
+func (s *SQLiteStore) insertEntry(ctx context.Context, entry PortfolioEntry) error {
+    _, err := s.db.ExecContext(ctx,
+        "INSERT OR REPLACE INTO portfolio ("+entryColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+        entry.Id.Hex(), entry.Title, toMillis(entry.Date), entry.Repo, entry.Url, entry.CoverImage)
+    return err
+}
+
+func (s *SQLiteStore) CreatePortfolioEntry(ctx context.Context, entry PortfolioEntry) (PortfolioEntry, error) {
+    entry.Id = primitive.NewObjectID()
+    entry.Date = time.Now()
+    return entry, s.insertEntry(ctx, entry)
+}
+
+func (s *SQLiteStore) UpdateEntry(ctx context.Context, e PortfolioEntry) (PortfolioEntry, error) {
+    result, err := s.db.ExecContext(ctx,
+        "UPDATE portfolio SET title = ?, repo = ?, url = ?, coverimage = ? WHERE id = ?",
+        e.Title, e.Repo, e.Url, e.CoverImage, e.Id.Hex())
+    if err != nil {
+        return PortfolioEntry{}, err
+    }
+
+    if n, _ := result.RowsAffected(); n == 0 {
+        return PortfolioEntry{}, ErrNotFound
+    }
+
+    return s.GetEntry(ctx, e.Id.Hex())
+}
+
+func (s *SQLiteStore) DeleteEntry(ctx context.Context, id string) error {
+    objectID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return fmt.Errorf("invalid ID format: %v", err)
+    }
+
+    result, err := s.db.ExecContext(ctx, "DELETE FROM portfolio WHERE id = ?", objectID.Hex())
+    if err != nil {
+        return err
+    }
+
+    if n, _ := result.RowsAffected(); n == 0 {
+        return fmt.Errorf("no post found with ID %s: %w", id, ErrNotFound)
+    }
+
+    return nil
+}
@@ -0,0 +1,394 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "golang.org/x/crypto/bcrypt"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+)
+
+var (
+    ErrNotFound  = errors.New("not found")
+    ErrDuplicate = errors.New("already exists")
+    // ErrAlreadyReacted is returned when a visitor reacts to a post the way
+    // they, or someone from the same address not long ago, already did.
+    ErrAlreadyReacted = errors.New("already reacted")
+    // ErrInvalidCredentials is returned for unknown usernames and wrong
+    // passwords alike.
+    ErrInvalidCredentials = errors.New("invalid username or password")
+    // ErrInvalidInvite is returned for invites that are unknown, used or
+    // expired.
+    ErrInvalidInvite = errors.New("invite is unknown, used or expired")
+    // ErrCodeUsed is returned for one-time codes that were already used.
+    ErrCodeUsed = errors.New("code already used")
+    // ErrInvalidReset is returned for password reset links that are
+    // unknown, used or expired.
+    ErrInvalidReset = errors.New("reset link is unknown, used or expired")
+)
+
+// Cursor is where an item sits in a listing ordered newest first. Listings
+// carry on after it from one page to the next.
+type Cursor struct {
+    Date time.Time
+    Id   primitive.ObjectID
+}
+
+func (c Cursor) IsZero() bool {
+    return c.Id.IsZero()
+}
+
+// Precedes tells whether c comes before other in a listing, ties on the date
+// broken by id.
+func (c Cursor) Precedes(other Cursor) bool {
+    if !c.Date.Equal(other.Date) {
+        return c.Date.After(other.Date)
+    }
+    return c.Id.Hex() > other.Id.Hex()
+}
+
+// String encodes c for URLs; ParseCursor decodes it.
+func (c Cursor) String() string {
+    return fmt.Sprintf("%d.%s", c.Date.UnixNano(), c.Id.Hex())
+}
+
+// CursorError reports a cursor that ParseCursor couldn't decode.
+type CursorError struct {
+    Cursor string
+}
+
+func (e *CursorError) Error() string {
+    return fmt.Sprintf("invalid cursor %q", e.Cursor)
+}
+
+func ParseCursor(s string) (Cursor, error) {
+    nanos, hex, _ := strings.Cut(s, ".")
+
+    n, err := strconv.ParseInt(nanos, 10, 64)
+    if err != nil {
+        return Cursor{}, &CursorError{Cursor: s}
+    }
+
+    id, err := primitive.ObjectIDFromHex(hex)
+    if err != nil {
+        return Cursor{}, &CursorError{Cursor: s}
+    }
+
+    return Cursor{Date: time.Unix(0, n), Id: id}, nil
+}
+
+// Page limits a listing to at most Limit items after the cursor After. The
+// zero Page lists everything.
+type Page struct {
+    Limit int
+    After Cursor
+}
+
+// PostQuery narrows down QueryPosts. Zero fields don't filter.
+type PostQuery struct {
+    Page
+    // TitlePrefix matches posts whose title starts with it, ignoring case.
+    TitlePrefix string
+    // Status only returns posts in that status.
+    Status string
+    // Tag only returns posts carrying that tag.
+    Tag string
+    // Category only returns posts in that category.
+    Category string
+    // Ids, unless nil, only returns the posts with these ids.
+    Ids []primitive.ObjectID
+}
+
+// PublishedPosts is the query behind every public listing.
+var PublishedPosts = PostQuery{Status: StatusPublished}
+
+type PostStore interface {
+    GetPosts(ctx context.Context) ([]Post, error)
+    // QueryPosts lists the posts matching q newest first.
+    QueryPosts(ctx context.Context, q PostQuery) ([]Post, error)
+    GetPost(ctx context.Context, id string) (Post, error)
+    // GetPostBySlug finds the post whose current or former slug is slug.
+    GetPostBySlug(ctx context.Context, slug string) (Post, error)
+    // CreatePost stores a new post, giving it a slug unique among all posts.
+    CreatePost(ctx context.Context, post Post) (Post, error)
+    // UpdatePost overwrites the editable fields of the stored post with the
+    // ones in post and returns the result. A new title gets a new slug and the
+    // previous one is kept in OldSlugs.
+    UpdatePost(ctx context.Context, post Post) (Post, error)
+    DeletePost(ctx context.Context, id string) error
+    // TagCounts counts the posts matching q under each of their tags, most
+    // used tags first.
+    TagCounts(ctx context.Context, q PostQuery) ([]TagCount, error)
+    // PublishDue publishes the scheduled posts whose time has come and
+    // returns them.
+    PublishDue(ctx context.Context, now time.Time) ([]Post, error)
+}
+
+// ReactionStore counts reactions to posts, at most one of each emoji per
+// visitor.
+type ReactionStore interface {
+    // React records reaction and counts it on its post, unless the visitor
+    // already reacted with the same emoji or the same IP hash did since the
+    // given time.
+    React(ctx context.Context, reaction Reaction, since time.Time) (Post, error)
+    // Unreact takes back a visitor's reaction, if they had one.
+    Unreact(ctx context.Context, postId primitive.ObjectID, visitor, emoji string) (Post, error)
+    // VisitorReactions lists every reaction of a visitor.
+    VisitorReactions(ctx context.Context, visitor string) ([]Reaction, error)
+}
+
+// CommentQuery narrows down QueryComments. Zero fields don't filter.
+type CommentQuery struct {
+    PostId primitive.ObjectID
+    Status string
+}
+
+type CommentStore interface {
+    // CreateComment stores a new comment, pending moderation unless it
+    // already has a status.
+    CreateComment(ctx context.Context, comment Comment) (Comment, error)
+    GetComment(ctx context.Context, id primitive.ObjectID) (Comment, error)
+    // QueryComments lists the comments matching q oldest first, the order
+    // they are read in.
+    QueryComments(ctx context.Context, q CommentQuery) ([]Comment, error)
+    // SetCommentStatus moderates a comment, marking it as moderated, and
+    // updates the comment count of its post.
+    SetCommentStatus(ctx context.Context, id primitive.ObjectID, status string) (Comment, error)
+}
+
+type PortfolioStore interface {
+    // GetPortfolioEntries lists a page of entries newest first.
+    GetPortfolioEntries(ctx context.Context, page Page) ([]PortfolioEntry, error)
+    GetEntry(ctx context.Context, id string) (PortfolioEntry, error)
+    CreatePortfolioEntry(ctx context.Context, entry PortfolioEntry) (PortfolioEntry, error)
+    UpdateEntry(ctx context.Context, entry PortfolioEntry) (PortfolioEntry, error)
+    DeleteEntry(ctx context.Context, id string) error
+}
+
+type UserStore interface {
+    // CreateUser stores an already hashed user, with a new id unless it has
+    // one. It fails with ErrDuplicate when the username is taken.
+    CreateUser(ctx context.Context, user User) (User, error)
+    GetUser(ctx context.Context, id primitive.ObjectID) (User, error)
+    GetUserByUsername(ctx context.Context, username string) (User, error)
+    GetUserByEmail(ctx context.Context, email string) (User, error)
+    // ListUsers lists every user by username.
+    ListUsers(ctx context.Context) ([]User, error)
+    CountUsers(ctx context.Context) (int, error)
+    SetUserRole(ctx context.Context, id primitive.ObjectID, role string) error
+    // SetPassword stores a new password hash for a user.
+    SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error
+    // SetUserEmail changes the email of a user, or removes it when empty. It
+    // fails with ErrDuplicate when another user has it.
+    SetUserEmail(ctx context.Context, id primitive.ObjectID, email string) error
+    // SetTwoFactor turns on two-factor authentication for a user with the
+    // secret and hashed recovery codes, or turns it off when secret is
+    // empty.
+    SetTwoFactor(ctx context.Context, id primitive.ObjectID, secret string, recoveryCodes []string) error
+    // SetRecoveryCodes replaces the hashed recovery codes of a user.
+    SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, recoveryCodes []string) error
+    // UseTOTPStep records that a code of the time step was accepted, failing
+    // with ErrCodeUsed if one of it or a later step already was.
+    UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error
+    // UseRecoveryCode takes the hashed recovery code from the user's, failing
+    // with ErrNotFound if it isn't one of them.
+    UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error
+}
+
+// InviteStore keeps the invites the owner hands out to new admin users.
+// Invites are looked up by the hash of their token, never the token itself.
+type InviteStore interface {
+    CreateInvite(ctx context.Context, invite Invite) (Invite, error)
+    // Invites lists every invite newest first.
+    Invites(ctx context.Context) ([]Invite, error)
+    GetInvite(ctx context.Context, tokenHash string) (Invite, error)
+    // UseInvite marks the invite used by user, failing with
+    // ErrInvalidInvite unless it was still usable at now. Only one caller
+    // can ever use an invite.
+    UseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID, now time.Time) (Invite, error)
+    // ReleaseInvite makes the invite used by user usable again, for when
+    // registering them failed after it was used.
+    ReleaseInvite(ctx context.Context, tokenHash string, user primitive.ObjectID) error
+    DeleteInvite(ctx context.Context, id primitive.ObjectID) error
+}
+
+type SessionStore interface {
+    // CreateSession stores a new session, with a new id unless it has one.
+    CreateSession(ctx context.Context, session Session) error
+    GetSession(ctx context.Context, tokenHash string) (Session, error)
+    // TouchSession records that the session was used at seen and keeps it
+    // alive until expires.
+    TouchSession(ctx context.Context, tokenHash string, seen, expires time.Time) error
+    // UserSessions lists the sessions of a user, most recently seen first.
+    UserSessions(ctx context.Context, user primitive.ObjectID) ([]Session, error)
+    DeleteSession(ctx context.Context, tokenHash string) error
+    // RevokeSession deletes the session with the id if it belongs to user.
+    RevokeSession(ctx context.Context, user, id primitive.ObjectID) error
+    // DeleteUserSessions signs a user out everywhere and says how many
+    // sessions it ended.
+    DeleteUserSessions(ctx context.Context, user primitive.ObjectID) (int, error)
+    // DeleteExpiredSessions deletes the sessions that expired before now.
+    DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+// AuditStore keeps the audit log.
+type AuditStore interface {
+    Audit(ctx context.Context, entry AuditEntry) error
+    // AuditLog lists the latest limit entries, newest first.
+    AuditLog(ctx context.Context, limit int) ([]AuditEntry, error)
+}
+
+// PasswordResetStore keeps the links sent to users who forgot their password.
+// Like invites, they are looked up by the hash of their token.
+type PasswordResetStore interface {
+    // CreatePasswordReset stores a reset, replacing the user's earlier ones
+    // so that only the latest link works.
+    CreatePasswordReset(ctx context.Context, reset PasswordReset) error
+    GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+    // UsePasswordReset marks the reset used, failing with ErrInvalidReset
+    // unless it was still usable at now. Only one caller can ever use it.
+    UsePasswordReset(ctx context.Context, tokenHash string, now time.Time) (PasswordReset, error)
+    // DeleteUserPasswordResets deletes every reset of the user, so that no
+    // link sent before their password changed still works.
+    DeleteUserPasswordResets(ctx context.Context, user primitive.ObjectID) error
+}
+
+// APITokenStore keeps the personal access tokens users make for scripts.
+// Like invites, they are looked up by the hash of their token.
+type APITokenStore interface {
+    // CreateAPIToken stores a new token, with a new id unless it has one.
+    CreateAPIToken(ctx context.Context, token APIToken) (APIToken, error)
+    GetAPIToken(ctx context.Context, tokenHash string) (APIToken, error)
+    // UserAPITokens lists the tokens of a user, newest first.
+    UserAPITokens(ctx context.Context, user primitive.ObjectID) ([]APIToken, error)
+    // TouchAPIToken records that the token was used at when, from ip.
+    TouchAPIToken(ctx context.Context, id primitive.ObjectID, when time.Time, ip string) error
+    // RevokeAPIToken deletes the token with the id if it belongs to user.
+    RevokeAPIToken(ctx context.Context, user, id primitive.ObjectID) error
+}
+
+// SettingsStore keeps the settings the owner changes from the admin page.
+type SettingsStore interface {
+    // GetSettings returns the zero SiteSettings until some are saved.
+    GetSettings(ctx context.Context) (SiteSettings, error)
+    SaveSettings(ctx context.Context, settings SiteSettings) error
+}
+
+// Store is everything the site needs from a storage backend.
+type Store interface {
+    PostStore
+    CommentStore
+    ReactionStore
+    PortfolioStore
+    UserStore
+    InviteStore
+    SessionStore
+    AuditStore
+    SettingsStore
+    PasswordResetStore
+    APITokenStore
+    Close(ctx context.Context) error
+}
+
+var (
+    _ Store = (*MongoStore)(nil)
+    _ Store = (*MemoryStore)(nil)
+    _ Store = (*SQLiteStore)(nil)
+)
+
+// Open connects to the backend selected in the configuration and brings
+// older data up to date.
+func Open(ctx context.Context, cfg *config.Config) (Store, error) {
+    var store Store
+    var err error
+
+    switch cfg.Store {
+    case config.StoreMongo:
+        store, err = NewMongoStore(ctx, cfg.Mongo.URI, cfg.Mongo.Database)
+    case config.StoreSQLite:
+        store, err = NewSQLiteStore(ctx, cfg.SQLite.Path)
+    case config.StoreMemory:
+        store = NewMemoryStore()
+    default:
+        err = fmt.Errorf("unknown store %q", cfg.Store)
+    }
+
+    if err != nil {
+        return nil, err
+    }
+
+    if err := BackfillPosts(ctx, store); err != nil {
+        store.Close(ctx)
+        return nil, err
+    }
+
+    return store, nil
+}
+
+// RegisterUser holds the password of a new user to the policy, hashes it
+// and stores them.
+func RegisterUser(ctx context.Context, users UserStore, user User, policy config.PasswordConfig) (User, error) {
+    if !IsRole(user.Role) {
+        return User{}, fmt.Errorf("%w: %q", ErrInvalidRole, user.Role)
+    }
+
+    if err := CheckPasswordStrength(policy, user.Username, user.Password); err != nil {
+        return user, err
+    }
+
+    err := user.HashPassword(policy.Cost)
+    if err != nil {
+        return user, err
+    }
+
+    return users.CreateUser(ctx, user)
+}
+
+// unknownUsers stand in for users that don't exist, one for each bcrypt
+// cost asked for, so that checking their password takes as long as checking
+// a real one.
+var unknownUsers sync.Map
+
+func unknownUser(cost int) User {
+    if user, ok := unknownUsers.Load(cost); ok {
+        return user.(User)
+    }
+
+    user := User{Password: "not a password"}
+    if err := user.HashPassword(cost); err != nil {
+        panic(err)
+    }
+    unknownUsers.Store(cost, user)
+    return user
+}
+
+// legacyCost is the bcrypt cost passwords were hashed with before it could
+// be configured. They are only rehashed when their user next signs in.
+const legacyCost = bcrypt.DefaultCost
+
+// AuthenticateUser checks a username and password. Unknown usernames and
+// wrong passwords fail the same way, with ErrInvalidCredentials. Unknown
+// ones take as long as the cheapest stored hash can, so that no user's
+// password is quicker to check than a made up username.
+func AuthenticateUser(ctx context.Context, users UserStore, username, password string, policy config.PasswordConfig) (*User, error) {
+    user, err := users.GetUserByUsername(ctx, username)
+    if errors.Is(err, ErrNotFound) {
+        unknown := unknownUser(min(policy.Cost, legacyCost))
+        unknown.CheckPassword(password)
+        return nil, ErrInvalidCredentials
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    if !user.CheckPassword(password) {
+        return nil, ErrInvalidCredentials
+    }
+
+    return &user, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "testing"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// forEachStore runs test against every store: memory, SQLite in a fresh file
+// and, when BLOG_TEST_MONGO_URI points at a server, Mongo in a fresh database
+// that is dropped afterwards.
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+    t.Run("memory", func(t *testing.T) {
+        test(t, NewMemoryStore())
+    })
+    t.Run("sqlite", func(t *testing.T) {
+        s, err := NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "blog.db"))
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(func() { s.Close(context.Background()) })
+        test(t, s)
+    })
+    t.Run("mongo", func(t *testing.T) {
+        uri := os.Getenv("BLOG_TEST_MONGO_URI")
+        if uri == "" {
+            t.Skip("BLOG_TEST_MONGO_URI isn't set")
+        }
+        s, err := NewMongoStore(context.Background(), uri, "blog_test_"+primitive.NewObjectID().Hex())
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(func() {
+            s.db.Drop(context.Background())
+            s.Close(context.Background())
+        })
+        test(t, s)
+    })
+}
+
+func createUser(t *testing.T, s Store, username string) User {
+    t.Helper()
+    user, err := s.CreateUser(context.Background(), User{Username: username, Password: "hash", Role: RoleAuthor})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return user
+}
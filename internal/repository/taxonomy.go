@@ -0,0 +1,58 @@
+package repository
+
+import (
+    "slices"
+    "sort"
+    "strings"
+)
+
+// TagCount is how many posts carry a tag.
+type TagCount struct {
+    Tag   string `bson:"_id"`
+    Count int    `bson:"count"`
+}
+
+// NormalizeTag turns tag into the form it is stored and linked under, the
+// same way titles become slugs, so "Go Lang" and "go-lang" are one tag.
+func NormalizeTag(tag string) string {
+    return slugify(tag)
+}
+
+// ParseTags splits a comma separated list of tags as typed in the post form.
+func ParseTags(s string) []string {
+    return normalizeTags(strings.Split(s, ","))
+}
+
+func normalizeTags(tags []string) []string {
+    var normalized []string
+    for _, tag := range tags {
+        tag = NormalizeTag(tag)
+        if tag != "" && !slices.Contains(normalized, tag) {
+            normalized = append(normalized, tag)
+        }
+    }
+    return normalized
+}
+
+// NormalizeCategory trims category and collapses the whitespace inside it.
+// Categories keep their case since they are shown as typed.
+func NormalizeCategory(category string) string {
+    return strings.Join(strings.Fields(category), " ")
+}
+
+// taxonomy cleans up the tags and category of a post about to be saved.
+func taxonomy(post Post) Post {
+    post.Tags = normalizeTags(post.Tags)
+    post.Category = NormalizeCategory(post.Category)
+    return post
+}
+
+// sortTagCounts orders the most used tags first and ties alphabetically.
+func sortTagCounts(counts []TagCount) {
+    sort.Slice(counts, func(i, j int) bool {
+        if counts[i].Count != counts[j].Count {
+            return counts[i].Count > counts[j].Count
+        }
+        return counts[i].Tag < counts[j].Tag
+    })
+}
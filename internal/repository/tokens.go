@@ -0,0 +1,86 @@
+package repository
+
+import (
+    "slices"
+    "time"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes an API token can be given. Each one lets the token do one kind of
+// thing through the API, and only while its user's role still allows it.
+const (
+    ScopePostsWrite    = "posts:write"
+    ScopeMediaWrite    = "media:write"
+    ScopePortfolioRead = "portfolio:read"
+)
+
+var Scopes = []string{ScopePostsWrite, ScopeMediaWrite, ScopePortfolioRead}
+
+// scopeRoles is the least trusted role that may hold each scope.
+var scopeRoles = map[string]string{
+    ScopePostsWrite:    RoleAuthor,
+    ScopeMediaWrite:    RoleAuthor,
+    ScopePortfolioRead: RoleViewer,
+}
+
+func IsScope(scope string) bool {
+    _, ok := scopeRoles[scope]
+    return ok
+}
+
+// CanUseScope tells whether the user's role allows scope.
+func (u User) CanUseScope(scope string) bool {
+    role, ok := scopeRoles[scope]
+    return ok && u.Can(role)
+}
+
+// UserScopes lists the scopes the user's role allows.
+func (u User) UserScopes() []string {
+    var scopes []string
+    for _, scope := range Scopes {
+        if u.CanUseScope(scope) {
+            scopes = append(scopes, scope)
+        }
+    }
+    return scopes
+}
+
+// APITokenPrefix starts every API token, so that they are easy to tell apart
+// from other secrets, by people and by secret scanners alike.
+const APITokenPrefix = "blog_"
+
+// APIToken lets scripts act as its user through the API, within its scopes,
+// until it expires or is revoked. Only a hash of the token is stored.
+type APIToken struct {
+    Id        primitive.ObjectID `bson:"_id,omitempty"`
+    UserId    primitive.ObjectID `bson:"user_id"`
+    // Name says what the token is for, as its user put it.
+    Name      string             `bson:"name"`
+    TokenHash string             `bson:"token_hash"`
+    Scopes    []string           `bson:"scopes"`
+    Created   time.Time          `bson:"created"`
+    // Expires is zero for tokens that never expire.
+    Expires   time.Time          `bson:"expires,omitempty"`
+    // LastUsed is zero until the token is first used.
+    LastUsed  time.Time          `bson:"last_used,omitempty"`
+    LastIP    string             `bson:"last_ip,omitempty"`
+}
+
+// NewAPIToken makes a token to hand out and the hash to store in its place.
+func NewAPIToken() (token, hash string, err error) {
+    token, _, err = NewToken()
+    if err != nil {
+        return "", "", err
+    }
+    token = APITokenPrefix + token
+    return token, HashToken(token), nil
+}
+
+func (t APIToken) HasScope(scope string) bool {
+    return slices.Contains(t.Scopes, scope)
+}
+
+// Expired tells whether the token can no longer be used at now.
+func (t APIToken) Expired(now time.Time) bool {
+    return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/base32"
+    "errors"
+    "strings"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/totp"
+)
+
+// RecoveryCodeCount is how many recovery codes users get at a time.
+const RecoveryCodeCount = 10
+
+// SiteSettings are the settings the owner changes from the admin page.
+type SiteSettings struct {
+    // RequireTwoFactor makes every user turn on two-factor authentication
+    // before they can use the admin.
+    RequireTwoFactor bool `bson:"require_two_factor"`
+}
+
+// HasTwoFactor tells whether signing in as the user takes a second factor.
+func (u User) HasTwoFactor() bool {
+    return u.TOTPSecret != ""
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes makes a set of recovery codes to show the user once, and
+// the hashes to store.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+    for range RecoveryCodeCount {
+        b := make([]byte, 8)
+        if _, err := rand.Read(b); err != nil {
+            return nil, nil, err
+        }
+        code := strings.ToLower(recoveryEncoding.EncodeToString(b)[:10])
+        codes = append(codes, code[:5]+"-"+code[5:])
+        hashes = append(hashes, HashToken(code))
+    }
+    return codes, hashes, nil
+}
+
+// normalizeCode forgives the spaces, dashes and capitals people type codes
+// with.
+func normalizeCode(code string) string {
+    return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// VerifySecondFactor checks a code from the user's authenticator app, or one
+// of their recovery codes, which is then used up. Wrong and reused codes fail
+// with ErrInvalidCredentials. It tells whether a recovery code was used.
+func VerifySecondFactor(ctx context.Context, users UserStore, user User, code string, now time.Time) (recovery bool, err error) {
+    code = normalizeCode(code)
+
+    if len(code) == totp.Digits {
+        step, ok := totp.Validate(user.TOTPSecret, code, now)
+        if !ok {
+            return false, ErrInvalidCredentials
+        }
+        err := users.UseTOTPStep(ctx, user.Id, step)
+        if errors.Is(err, ErrCodeUsed) {
+            return false, ErrInvalidCredentials
+        }
+        return false, err
+    }
+
+    err = users.UseRecoveryCode(ctx, user.Id, HashToken(code))
+    if errors.Is(err, ErrNotFound) {
+        return true, ErrInvalidCredentials
+    }
+    return true, err
+}
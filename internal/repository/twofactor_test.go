@@ -0,0 +1,121 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/totp"
+)
+
+// enrol turns on two-factor authentication for a new user and returns them
+// as stored.
+func enrol(t *testing.T, s Store, hashes []string) User {
+    t.Helper()
+    ctx := context.Background()
+    user := createUser(t, s, "alice")
+
+    secret, err := totp.NewSecret()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := s.SetTwoFactor(ctx, user.Id, secret, hashes); err != nil {
+        t.Fatal(err)
+    }
+    user, err = s.GetUser(ctx, user.Id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return user
+}
+
+func TestTOTPReplay(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        user := enrol(t, s, nil)
+        now := time.Now()
+        step := totp.Step(now)
+
+        code := func(step int64) string {
+            c, err := totp.Code(user.TOTPSecret, step)
+            if err != nil {
+                t.Fatal(err)
+            }
+            return c
+        }
+
+        if _, err := VerifySecondFactor(ctx, s, user, code(step), now); err != nil {
+            t.Fatalf("first use of a code: %v", err)
+        }
+        if _, err := VerifySecondFactor(ctx, s, user, code(step), now); !errors.Is(err, ErrInvalidCredentials) {
+            t.Errorf("second use of a code gave %v, want ErrInvalidCredentials", err)
+        }
+        // The step before is still in the window, but older than the one used.
+        if _, err := VerifySecondFactor(ctx, s, user, code(step-1), now); !errors.Is(err, ErrInvalidCredentials) {
+            t.Errorf("code of an earlier step gave %v, want ErrInvalidCredentials", err)
+        }
+        if _, err := VerifySecondFactor(ctx, s, user, code(step+1), now.Add(totp.Period)); err != nil {
+            t.Errorf("code of the next step: %v", err)
+        }
+
+        if err := s.UseTOTPStep(ctx, user.Id, step+1); !errors.Is(err, ErrCodeUsed) {
+            t.Errorf("UseTOTPStep of a used step gave %v, want ErrCodeUsed", err)
+        }
+    })
+}
+
+func TestTOTPStepResetByNewSecret(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        user := enrol(t, s, nil)
+        if err := s.UseTOTPStep(ctx, user.Id, totp.Step(time.Now())); err != nil {
+            t.Fatal(err)
+        }
+
+        secret, _ := totp.NewSecret()
+        if err := s.SetTwoFactor(ctx, user.Id, secret, nil); err != nil {
+            t.Fatal(err)
+        }
+        if err := s.UseTOTPStep(ctx, user.Id, totp.Step(time.Now())); err != nil {
+            t.Errorf("step of a new secret: %v", err)
+        }
+    })
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+    forEachStore(t, func(t *testing.T, s Store) {
+        ctx := context.Background()
+        codes, hashes, err := NewRecoveryCodes()
+        if err != nil {
+            t.Fatal(err)
+        }
+        user := enrol(t, s, hashes)
+
+        recovery, err := VerifySecondFactor(ctx, s, user, codes[0], time.Now())
+        if err != nil || !recovery {
+            t.Fatalf("first use of a recovery code gave %v, %v", recovery, err)
+        }
+        if _, err := VerifySecondFactor(ctx, s, user, codes[0], time.Now()); !errors.Is(err, ErrInvalidCredentials) {
+            t.Errorf("second use of a recovery code gave %v, want ErrInvalidCredentials", err)
+        }
+
+        // Codes are forgiving of how they are typed.
+        typed := strings.ToUpper(strings.ReplaceAll(codes[1], "-", " "))
+        if _, err := VerifySecondFactor(ctx, s, user, typed, time.Now()); err != nil {
+            t.Errorf("recovery code typed as %q: %v", typed, err)
+        }
+
+        if _, err := VerifySecondFactor(ctx, s, user, "aaaaa-aaaaa", time.Now()); !errors.Is(err, ErrInvalidCredentials) {
+            t.Errorf("made up recovery code gave %v, want ErrInvalidCredentials", err)
+        }
+
+        user, err = s.GetUser(ctx, user.Id)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(user.RecoveryCodes) != RecoveryCodeCount-2 {
+            t.Errorf("%d recovery codes left, want %d", len(user.RecoveryCodes), RecoveryCodeCount-2)
+        }
+    })
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+    "context"
+    "log"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// maxSchedulerSleep bounds how long the scheduler sleeps, so posts scheduled
+// while it waits are still picked up promptly.
+const maxSchedulerSleep = time.Minute
+
+// RunScheduler publishes scheduled posts when their time comes. It wakes up at
+// the next publish time it knows of, or after a minute at most, and returns
+// when ctx is done.
+func RunScheduler(ctx context.Context, posts repository.PostStore) {
+    for {
+        published, err := posts.PublishDue(ctx, time.Now())
+        if err != nil {
+            log.Printf("Error publishing scheduled posts: %v\n", err)
+        }
+
+        for _, post := range published {
+            log.Printf("Published scheduled post %q\n", post.Title)
+        }
+
+        sleep := maxSchedulerSleep
+        if next, ok := nextPublishAt(ctx, posts); ok {
+            if until := time.Until(next); until < sleep {
+                sleep = max(until, time.Second)
+            }
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(sleep):
+        }
+    }
+}
+
+func nextPublishAt(ctx context.Context, posts repository.PostStore) (time.Time, bool) {
+    scheduled, err := posts.QueryPosts(ctx, repository.PostQuery{Status: repository.StatusScheduled})
+    if err != nil || len(scheduled) == 0 {
+        return time.Time{}, false
+    }
+
+    next := scheduled[0].PublishAt
+    for _, post := range scheduled[1:] {
+        if post.PublishAt.Before(next) {
+            next = post.PublishAt
+        }
+    }
+
+    return next, true
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+    "html"
+    "regexp"
+    "strings"
+    "unicode"
+    "github.com/vinny-pereira/personal-blog/internal"
+    "golang.org/x/text/unicode/norm"
+)
+
+// token is a word of a text with its byte offsets, so matches can be
+// highlighted in the original. term is what the word is indexed under, empty
+// for stop words.
+type token struct {
+    start, end int
+    term       string
+}
+
+var stopWords = map[string]bool{}
+
+func init() {
+    for _, w := range strings.Fields(`a an and are as at be but by for from has have he her his i if in
+        into is it its me my no not of on or our she so that the their them then there these they this
+        to was we were what when where which who will with you your`) {
+        stopWords[w] = true
+    }
+}
+
+// tokenize splits text into words: runs of letters and digits.
+func tokenize(text string) []token {
+    var tokens []token
+    start := -1
+
+    for i, r := range text {
+        word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+        if word && start < 0 {
+            start = i
+        }
+        if !word && start >= 0 {
+            tokens = append(tokens, newToken(text, start, i))
+            start = -1
+        }
+    }
+
+    if start >= 0 {
+        tokens = append(tokens, newToken(text, start, len(text)))
+    }
+    return tokens
+}
+
+func newToken(text string, start, end int) token {
+    return token{start: start, end: end, term: analyze(text[start:end])}
+}
+
+// analyze folds a word and stems it. Stop words come out empty.
+func analyze(word string) string {
+    folded := fold(word)
+    if stopWords[folded] {
+        return ""
+    }
+    return stem(folded)
+}
+
+// fold lower cases word and drops its accents.
+func fold(word string) string {
+    var b strings.Builder
+    for _, r := range norm.NFKD.String(word) {
+        if !unicode.Is(unicode.Mn, r) {
+            b.WriteRune(unicode.ToLower(r))
+        }
+    }
+    return b.String()
+}
+
+// terms returns the indexable terms of text in order.
+func terms(text string) []string {
+    var terms []string
+    for _, t := range tokenize(text) {
+        if t.term != "" {
+            terms = append(terms, t.term)
+        }
+    }
+    return terms
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText renders markdown and strips the markup, leaving the text a
+// reader would see.
+func plainText(md string) string {
+    rendered := string(internal.MdToHtml([]byte(md)))
+    text := html.UnescapeString(htmlTag.ReplaceAllString(rendered, " "))
+    return strings.Join(strings.Fields(text), " ")
+}
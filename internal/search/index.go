@@ -0,0 +1,264 @@
+package search
+
+import (
+    "context"
+    "html/template"
+    "math"
+    "sort"
+    "strings"
+    "sync"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// The fields of a post that are searched, and how much a match in each one
+// counts towards the score.
+const (
+    fieldTitle = iota
+    fieldSynopsys
+    fieldBody
+    numFields
+)
+
+var fieldWeights = [numFields]float64{3, 2, 1}
+
+// BM25 parameters: k1 caps how much repeating a term helps and b how much
+// long fields are penalised.
+const (
+    k1 = 1.2
+    b  = 0.75
+)
+
+type document struct {
+    fields [numFields]string
+    length [numFields]int
+    terms  map[string]bool
+}
+
+// Index is an inverted index over the title, synopsis and body of every
+// post. It is safe for concurrent use.
+type Index struct {
+    lock     sync.RWMutex
+    docs     map[primitive.ObjectID]*document
+    postings map[string]map[primitive.ObjectID]*[numFields]int
+    length   [numFields]int
+}
+
+func NewIndex() *Index {
+    return &Index{
+        docs:     map[primitive.ObjectID]*document{},
+        postings: map[string]map[primitive.ObjectID]*[numFields]int{},
+    }
+}
+
+// Build indexes every post in posts.
+func (ix *Index) Build(ctx context.Context, posts repository.PostStore) error {
+    all, err := posts.GetPosts(ctx)
+    if err != nil {
+        return err
+    }
+
+    for _, post := range all {
+        ix.Add(post)
+    }
+    return nil
+}
+
+// Add indexes post, replacing what was indexed for it before.
+func (ix *Index) Add(post repository.Post) {
+    doc := &document{terms: map[string]bool{}}
+    doc.fields[fieldTitle] = post.Title
+    doc.fields[fieldSynopsys] = post.Synopsys
+    doc.fields[fieldBody] = plainText(post.Body)
+
+    counts := map[string]*[numFields]int{}
+    for f, text := range doc.fields {
+        for _, term := range terms(text) {
+            if counts[term] == nil {
+                counts[term] = &[numFields]int{}
+            }
+            counts[term][f]++
+            doc.length[f]++
+            doc.terms[term] = true
+        }
+    }
+
+    ix.lock.Lock()
+    defer ix.lock.Unlock()
+
+    ix.remove(post.Id)
+    ix.docs[post.Id] = doc
+    for f := range doc.length {
+        ix.length[f] += doc.length[f]
+    }
+
+    for term, tf := range counts {
+        if ix.postings[term] == nil {
+            ix.postings[term] = map[primitive.ObjectID]*[numFields]int{}
+        }
+        ix.postings[term][post.Id] = tf
+    }
+}
+
+// Remove drops the post with the given id from the index.
+func (ix *Index) Remove(id primitive.ObjectID) {
+    ix.lock.Lock()
+    defer ix.lock.Unlock()
+
+    ix.remove(id)
+}
+
+func (ix *Index) remove(id primitive.ObjectID) {
+    doc, ok := ix.docs[id]
+    if !ok {
+        return
+    }
+
+    for term := range doc.terms {
+        delete(ix.postings[term], id)
+        if len(ix.postings[term]) == 0 {
+            delete(ix.postings, term)
+        }
+    }
+
+    for f := range doc.length {
+        ix.length[f] -= doc.length[f]
+    }
+    delete(ix.docs, id)
+}
+
+// Result is a post matching a search, with the matched words of its title
+// and of a snippet of its text wrapped in <mark>.
+type Result struct {
+    Id      primitive.ObjectID
+    Score   float64
+    Title   template.HTML
+    Snippet template.HTML
+}
+
+// Search returns the posts containing every word of query, best matches
+// first. Unless query ends in a space its last word also matches as a prefix,
+// so results show up while it is still being typed.
+func (ix *Index) Search(query string) []Result {
+    var words []string
+    var last string
+    for _, t := range tokenize(query) {
+        if t.term != "" {
+            words = append(words, t.term)
+            last = fold(query[t.start:t.end])
+        }
+    }
+
+    if len(words) == 0 {
+        return nil
+    }
+
+    prefix := !strings.HasSuffix(query, " ")
+
+    ix.lock.RLock()
+    defer ix.lock.RUnlock()
+
+    // Each word of the query matches one or more indexed terms.
+    matches := make([][]string, len(words))
+    for i, word := range words {
+        if i == len(words)-1 && prefix {
+            matches[i] = ix.withPrefix(word, last)
+        } else if ix.postings[word] != nil {
+            matches[i] = []string{word}
+        }
+
+        if len(matches[i]) == 0 {
+            return nil
+        }
+    }
+
+    var scores map[primitive.ObjectID]float64
+    for i, terms := range matches {
+        found := map[primitive.ObjectID]float64{}
+        for _, term := range terms {
+            for id, tf := range ix.postings[term] {
+                found[id] += ix.score(term, id, tf)
+            }
+        }
+
+        if i == 0 {
+            scores = found
+            continue
+        }
+
+        for id := range scores {
+            if score, ok := found[id]; ok {
+                scores[id] += score
+            } else {
+                delete(scores, id)
+            }
+        }
+    }
+
+    highlight := map[string]bool{}
+    for _, terms := range matches {
+        for _, term := range terms {
+            highlight[term] = true
+        }
+    }
+
+    results := make([]Result, 0, len(scores))
+    for id, score := range scores {
+        doc := ix.docs[id]
+        snippet := makeSnippet(doc.fields[fieldBody], highlight)
+        if snippet == "" {
+            snippet = makeSnippet(doc.fields[fieldSynopsys], highlight)
+        }
+
+        results = append(results, Result{
+            Id:      id,
+            Score:   score,
+            Title:   markAll(doc.fields[fieldTitle], highlight),
+            Snippet: snippet,
+        })
+    }
+
+    sort.Slice(results, func(i, j int) bool {
+        if results[i].Score != results[j].Score {
+            return results[i].Score > results[j].Score
+        }
+        return results[i].Id.Timestamp().After(results[j].Id.Timestamp())
+    })
+    return results
+}
+
+// withPrefix lists the indexed terms that a partly typed word may become:
+// those starting with its stem, and stems the word already goes past by a
+// few letters, the way "programm" is on its way to "program".
+func (ix *Index) withPrefix(stemmed, word string) []string {
+    var terms []string
+    for term := range ix.postings {
+        if strings.HasPrefix(term, stemmed) ||
+            strings.HasPrefix(word, term) && len(word)-len(term) <= 3 && len(term) >= 3 {
+            terms = append(terms, term)
+        }
+    }
+    return terms
+}
+
+// score is the BM25F score of term in the document id, whose term
+// frequencies per field are tf.
+func (ix *Index) score(term string, id primitive.ObjectID, tf *[numFields]int) float64 {
+    n := float64(len(ix.docs))
+    df := float64(len(ix.postings[term]))
+    idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+    doc := ix.docs[id]
+    weighted := 0.0
+    for f := range tf {
+        if tf[f] == 0 {
+            continue
+        }
+
+        avg := float64(ix.length[f]) / n
+        norm := 1 - b + b*float64(doc.length[f])/avg
+        weighted += fieldWeights[f] * float64(tf[f]) / norm
+    }
+
+    return idf * weighted / (k1 + weighted)
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+    "strings"
+    "testing"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// newTestIndex indexes posts and returns the index with their ids, in order.
+func newTestIndex(posts ...repository.Post) (*Index, []primitive.ObjectID) {
+    ix := NewIndex()
+    ids := make([]primitive.ObjectID, len(posts))
+    for i, post := range posts {
+        post.Id = primitive.NewObjectID()
+        ids[i] = post.Id
+        ix.Add(post)
+    }
+    return ix, ids
+}
+
+func resultIds(results []Result) []primitive.ObjectID {
+    ids := make([]primitive.ObjectID, len(results))
+    for i, r := range results {
+        ids[i] = r.Id
+    }
+    return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+    filler := "Some words about the weather, gardening and cooking dinner."
+    ix, ids := newTestIndex(
+        repository.Post{Title: "Cooking notes", Body: filler + " A short aside on databases."},
+        repository.Post{Title: "Databases", Body: filler},
+        repository.Post{Title: "Weekend", Synopsys: "On databases", Body: filler},
+        repository.Post{Title: "Storage", Body: filler + " Databases, databases and more databases."},
+        repository.Post{Title: "Unrelated", Body: filler},
+    )
+    body, title, synopsis, repeated := ids[0], ids[1], ids[2], ids[3]
+
+    tests := []struct {
+        name          string
+        query         string
+        better, worse primitive.ObjectID
+    }{
+        {"title counts more than the body", "databases", title, body},
+        {"title counts more than the synopsis", "databases", title, synopsis},
+        {"repeating a word counts", "databases", repeated, body},
+    }
+    for _, test := range tests {
+        results := ix.Search(test.query)
+        rank := map[primitive.ObjectID]int{}
+        for i, r := range results {
+            rank[r.Id] = i + 1
+        }
+        if rank[test.better] == 0 || rank[test.worse] == 0 || rank[test.better] > rank[test.worse] {
+            t.Errorf("%s: ranked %d and %d", test.name, rank[test.better], rank[test.worse])
+        }
+    }
+
+    results := ix.Search("databases")
+    if len(results) != 4 {
+        t.Fatalf("found %d posts, want the 4 mentioning databases", len(results))
+    }
+    for i := 1; i < len(results); i++ {
+        if results[i].Score > results[i-1].Score {
+            t.Errorf("result %d scored %f, more than %f before it", i, results[i].Score, results[i-1].Score)
+        }
+    }
+}
+
+func TestSearchRareWords(t *testing.T) {
+    // Every post says "blog", only one says "kubernetes": matching the rare
+    // word is worth more than matching the common one.
+    ix, ids := newTestIndex(
+        repository.Post{Title: "One", Body: "blog blog kubernetes"},
+        repository.Post{Title: "Two", Body: "blog blog blog"},
+        repository.Post{Title: "Three", Body: "blog kubernetes"},
+        repository.Post{Title: "Four", Body: "blog"},
+    )
+    common := ix.score("blog", ids[0], ix.postings["blog"][ids[0]])
+    rare := ix.score("kubernet", ids[0], ix.postings["kubernet"][ids[0]])
+    if rare <= common {
+        t.Errorf("rare word scored %f, common one %f", rare, common)
+    }
+}
+
+func TestSearchMatching(t *testing.T) {
+    ix, ids := newTestIndex(
+        repository.Post{Title: "Programming in Go", Body: "Go makes concurrent programs simple."},
+        repository.Post{Title: "Gardening", Body: "Programs for the allotment."},
+        repository.Post{Title: "Café culture", Body: "Where to sit."},
+    )
+    goPost, garden, cafe := ids[0], ids[1], ids[2]
+
+    tests := []struct {
+        query string
+        want  []primitive.ObjectID
+    }{
+        {"programming", []primitive.ObjectID{goPost, garden}},
+        {"go programs", []primitive.ObjectID{goPost}},
+        {"concurrent allotment", nil},
+        {"Cafe", []primitive.ObjectID{cafe}},
+        // The last word is matched as it is typed...
+        {"progr", []primitive.ObjectID{goPost, garden}},
+        {"garde", []primitive.ObjectID{garden}},
+        // ...but not once it is finished.
+        {"garde ", nil},
+        {"the and of", nil},
+        {"", nil},
+    }
+    for _, test := range tests {
+        got := resultIds(ix.Search(test.query))
+        if !sameIds(got, test.want) {
+            t.Errorf("Search(%q) found %v, want %v", test.query, got, test.want)
+        }
+    }
+
+    ix.Remove(garden)
+    if got := resultIds(ix.Search("programs")); !sameIds(got, []primitive.ObjectID{goPost}) {
+        t.Errorf("after removing found %v", got)
+    }
+
+    ix.Add(repository.Post{Id: goPost, Title: "Rewritten"})
+    if got := ix.Search("programs"); len(got) != 0 {
+        t.Errorf("after reindexing found %v", resultIds(got))
+    }
+}
+
+// sameIds compares ids ignoring their order.
+func sameIds(a, b []primitive.ObjectID) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    seen := map[primitive.ObjectID]bool{}
+    for _, id := range a {
+        seen[id] = true
+    }
+    for _, id := range b {
+        if !seen[id] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestSearchHighlights(t *testing.T) {
+    ix, _ := newTestIndex(repository.Post{
+        Title: "Connecting <things>",
+        Body:  "Some **markdown** about connections & networks.",
+    })
+
+    results := ix.Search("connect")
+    if len(results) != 1 {
+        t.Fatalf("found %d posts", len(results))
+    }
+    if want := "<mark>Connecting</mark> &lt;things&gt;"; string(results[0].Title) != want {
+        t.Errorf("title %q, want %q", results[0].Title, want)
+    }
+    if want := "Some markdown about <mark>connections</mark> &amp; networks"; string(results[0].Snippet) != want {
+        t.Errorf("snippet %q, want %q", results[0].Snippet, want)
+    }
+
+    // Posts matching only in the synopsis get a snippet of it instead.
+    ix, _ = newTestIndex(repository.Post{Title: "Notes", Synopsys: "Connected thoughts", Body: "Nothing here."})
+    results = ix.Search("connect")
+    if len(results) != 1 || !strings.Contains(string(results[0].Snippet), "<mark>Connected</mark>") {
+        t.Errorf("results %+v, want a snippet of the synopsis", results)
+    }
+}
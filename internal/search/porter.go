@@ -0,0 +1,300 @@
+package search
+
+// stem reduces an English word to its stem with the Porter algorithm, so
+// "connect", "connected" and "connection" all become "connect". It expects a
+// lower case ASCII word; anything else is returned as it is.
+//
+// See https://tartarus.org/martin/PorterStemmer/def.txt
+func stem(word string) string {
+    if len(word) <= 2 {
+        return word
+    }
+    for i := 0; i < len(word); i++ {
+        if word[i] < 'a' || word[i] > 'z' {
+            return word
+        }
+    }
+
+    s := &stemmer{b: []byte(word), k: len(word) - 1}
+    s.step1ab()
+    if s.k > 0 {
+        s.step1c()
+        s.step2()
+        s.step3()
+        s.step4()
+        s.step5()
+    }
+    return string(s.b[:s.k+1])
+}
+
+// stemmer holds the word being stemmed in b[0..k]. j marks the end of the
+// stem once ends has matched a suffix.
+type stemmer struct {
+    b    []byte
+    k, j int
+}
+
+// cons tells whether b[i] is a consonant.
+func (s *stemmer) cons(i int) bool {
+    switch s.b[i] {
+    case 'a', 'e', 'i', 'o', 'u':
+        return false
+    case 'y':
+        return i == 0 || !s.cons(i-1)
+    }
+    return true
+}
+
+// m measures the number of consonant sequences in b[0..j]: with c a run of
+// consonants and v a run of vowels, [c](vc){m}[v].
+func (s *stemmer) m() int {
+    n, i := 0, 0
+    for {
+        if i > s.j {
+            return n
+        }
+        if !s.cons(i) {
+            break
+        }
+        i++
+    }
+    i++
+
+    for {
+        for {
+            if i > s.j {
+                return n
+            }
+            if s.cons(i) {
+                break
+            }
+            i++
+        }
+        i++
+        n++
+
+        for {
+            if i > s.j {
+                return n
+            }
+            if !s.cons(i) {
+                break
+            }
+            i++
+        }
+        i++
+    }
+}
+
+// vowelInStem tells whether b[0..j] contains a vowel.
+func (s *stemmer) vowelInStem() bool {
+    for i := 0; i <= s.j; i++ {
+        if !s.cons(i) {
+            return true
+        }
+    }
+    return false
+}
+
+// doubleCons tells whether b[i-1..i] is a double consonant.
+func (s *stemmer) doubleCons(i int) bool {
+    return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc tells whether b[i-2..i] is consonant, vowel, consonant with the last
+// one not w, x or y, as in hop or cav(e).
+func (s *stemmer) cvc(i int) bool {
+    if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+        return false
+    }
+    switch s.b[i] {
+    case 'w', 'x', 'y':
+        return false
+    }
+    return true
+}
+
+// ends tells whether b[0..k] ends with suffix, and if so sets j to the end
+// of what precedes it.
+func (s *stemmer) ends(suffix string) bool {
+    l := len(suffix)
+    if l > s.k+1 || string(s.b[s.k-l+1:s.k+1]) != suffix {
+        return false
+    }
+    s.j = s.k - l
+    return true
+}
+
+// setTo replaces b[j+1..k] with suffix.
+func (s *stemmer) setTo(suffix string) {
+    s.b = append(s.b[:s.j+1], suffix...)
+    s.k = s.j + len(suffix)
+}
+
+// replace sets the suffix when the stem is long enough.
+func (s *stemmer) replace(suffix string) {
+    if s.m() > 0 {
+        s.setTo(suffix)
+    }
+}
+
+// replaceFirst replaces the first suffix in pairs of (suffix, replacement)
+// that b ends with, if any.
+func (s *stemmer) replaceFirst(pairs ...string) {
+    for i := 0; i < len(pairs); i += 2 {
+        if s.ends(pairs[i]) {
+            s.replace(pairs[i+1])
+            return
+        }
+    }
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (s *stemmer) step1ab() {
+    if s.b[s.k] == 's' {
+        switch {
+        case s.ends("sses"):
+            s.k -= 2
+        case s.ends("ies"):
+            s.setTo("i")
+        case s.b[s.k-1] != 's':
+            s.k--
+        }
+    }
+
+    if s.ends("eed") {
+        if s.m() > 0 {
+            s.k--
+        }
+        return
+    }
+
+    if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+        s.k = s.j
+        switch {
+        case s.ends("at"):
+            s.setTo("ate")
+        case s.ends("bl"):
+            s.setTo("ble")
+        case s.ends("iz"):
+            s.setTo("ize")
+        case s.doubleCons(s.k):
+            switch s.b[s.k] {
+            case 'l', 's', 'z':
+            default:
+                s.k--
+            }
+        default:
+            s.j = s.k
+            if s.m() == 1 && s.cvc(s.k) {
+                s.setTo("e")
+            }
+        }
+    }
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (s *stemmer) step1c() {
+    if s.ends("y") && s.vowelInStem() {
+        s.b[s.k] = 'i'
+    }
+}
+
+// step2 maps double suffixes to single ones, e.g. -ization to -ize.
+func (s *stemmer) step2() {
+    switch s.b[s.k-1] {
+    case 'a':
+        s.replaceFirst("ational", "ate", "tional", "tion")
+    case 'c':
+        s.replaceFirst("enci", "ence", "anci", "ance")
+    case 'e':
+        s.replaceFirst("izer", "ize")
+    case 'l':
+        s.replaceFirst("bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous")
+    case 'o':
+        s.replaceFirst("ization", "ize", "ation", "ate", "ator", "ate")
+    case 's':
+        s.replaceFirst("alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous")
+    case 't':
+        s.replaceFirst("aliti", "al", "iviti", "ive", "biliti", "ble")
+    case 'g':
+        s.replaceFirst("logi", "log")
+    }
+}
+
+// step3 deals with -ic-, -full, -ness and the like.
+func (s *stemmer) step3() {
+    switch s.b[s.k] {
+    case 'e':
+        s.replaceFirst("icate", "ic", "ative", "", "alize", "al")
+    case 'i':
+        s.replaceFirst("iciti", "ic")
+    case 'l':
+        s.replaceFirst("ical", "ic", "ful", "")
+    case 's':
+        s.replaceFirst("ness", "")
+    }
+}
+
+// step4 takes off -ant, -ence and the like from long enough stems.
+func (s *stemmer) step4() {
+    var suffixes []string
+    switch s.b[s.k-1] {
+    case 'a':
+        suffixes = []string{"al"}
+    case 'c':
+        suffixes = []string{"ance", "ence"}
+    case 'e':
+        suffixes = []string{"er"}
+    case 'i':
+        suffixes = []string{"ic"}
+    case 'l':
+        suffixes = []string{"able", "ible"}
+    case 'n':
+        suffixes = []string{"ant", "ement", "ment", "ent"}
+    case 'o':
+        if s.ends("ion") && s.j >= 0 && (s.b[s.j] == 's' || s.b[s.j] == 't') {
+            break
+        }
+        suffixes = []string{"ou"}
+    case 's':
+        suffixes = []string{"ism"}
+    case 't':
+        suffixes = []string{"ate", "iti"}
+    case 'u':
+        suffixes = []string{"ous"}
+    case 'v':
+        suffixes = []string{"ive"}
+    case 'z':
+        suffixes = []string{"ize"}
+    default:
+        return
+    }
+
+    matched := suffixes == nil
+    for _, suffix := range suffixes {
+        if s.ends(suffix) {
+            matched = true
+            break
+        }
+    }
+
+    if matched && s.m() > 1 {
+        s.k = s.j
+    }
+}
+
+// step5 removes a final -e and turns -ll into -l on long enough stems.
+func (s *stemmer) step5() {
+    s.j = s.k
+    if s.b[s.k] == 'e' {
+        a := s.m()
+        if a > 1 || a == 1 && !s.cvc(s.k-1) {
+            s.k--
+        }
+    }
+
+    if s.b[s.k] == 'l' && s.doubleCons(s.k) && s.m() > 1 {
+        s.k--
+    }
+}
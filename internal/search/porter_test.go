@@ -0,0 +1,70 @@
+package search
+
+import "testing"
+
+func TestStem(t *testing.T) {
+    // From the examples in Porter's paper, step by step.
+    tests := []struct {
+        word, want string
+    }{
+        // Step 1a: plurals.
+        {"caresses", "caress"},
+        {"ponies", "poni"},
+        {"ties", "ti"},
+        {"caress", "caress"},
+        {"cats", "cat"},
+        // Step 1b: -ed and -ing.
+        {"feed", "feed"},
+        {"agreed", "agre"},
+        {"plastered", "plaster"},
+        {"bled", "bled"},
+        {"motoring", "motor"},
+        {"sing", "sing"},
+        {"conflated", "conflat"},
+        {"troubled", "troubl"},
+        {"sized", "size"},
+        {"hopping", "hop"},
+        {"tanned", "tan"},
+        {"falling", "fall"},
+        {"hissing", "hiss"},
+        {"fizzed", "fizz"},
+        {"failing", "fail"},
+        {"filing", "file"},
+        // Step 1c: y after a vowel.
+        {"happy", "happi"},
+        {"sky", "sky"},
+        // Steps 2 to 4: double, single and lone suffixes.
+        {"relational", "relat"},
+        {"conditional", "condit"},
+        {"rational", "ration"},
+        {"digitizer", "digit"},
+        {"generalization", "gener"},
+        {"hopeful", "hope"},
+        {"goodness", "good"},
+        {"revival", "reviv"},
+        {"allowance", "allow"},
+        {"adjustable", "adjust"},
+        {"electrical", "electr"},
+        // Step 5: a final e and ll.
+        {"probate", "probat"},
+        {"rate", "rate"},
+        {"cease", "ceas"},
+        {"controlling", "control"},
+        {"roll", "roll"},
+        // The point of stemming: one stem for the whole family.
+        {"connect", "connect"},
+        {"connected", "connect"},
+        {"connection", "connect"},
+        {"connections", "connect"},
+        // Words it leaves alone.
+        {"go", "go"},
+        {"café", "café"},
+        {"go1", "go1"},
+        {"", ""},
+    }
+    for _, test := range tests {
+        if got := stem(test.word); got != test.want {
+            t.Errorf("stem(%q) = %q, want %q", test.word, got, test.want)
+        }
+    }
+}
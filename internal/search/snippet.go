@@ -0,0 +1,77 @@
+package search
+
+import (
+    "html/template"
+    "strings"
+)
+
+// snippetWords is how many words of text a snippet shows.
+const snippetWords = 30
+
+// makeSnippet picks the stretch of text with the most highlighted words and
+// marks them. It is empty when nothing in text is highlighted.
+func makeSnippet(text string, highlight map[string]bool) template.HTML {
+    tokens := tokenize(text)
+
+    best, bestCount, count := -1, 0, 0
+    for i, t := range tokens {
+        if highlight[t.term] {
+            count++
+        }
+        if i >= snippetWords && highlight[tokens[i-snippetWords].term] {
+            count--
+        }
+
+        if count > bestCount {
+            best, bestCount = max(0, i-snippetWords+1), count
+        }
+    }
+
+    if best < 0 {
+        return ""
+    }
+
+    // Start a few words before the first match for some context.
+    for best > 0 && !highlight[tokens[best].term] {
+        best++
+    }
+    start := max(0, best-5)
+    end := min(len(tokens), start+snippetWords)
+
+    var b strings.Builder
+    if start > 0 {
+        b.WriteString("… ")
+    }
+    writeMarked(&b, text[tokens[start].start:tokens[end-1].end], tokens[start:end], tokens[start].start, highlight)
+    if end < len(tokens) {
+        b.WriteString(" …")
+    }
+
+    return template.HTML(b.String())
+}
+
+// markAll escapes text and marks every highlighted word in it.
+func markAll(text string, highlight map[string]bool) template.HTML {
+    var b strings.Builder
+    writeMarked(&b, text, tokenize(text), 0, highlight)
+    return template.HTML(b.String())
+}
+
+// writeMarked writes text, escaped, wrapping the highlighted tokens in <mark>.
+// Token offsets are relative to offset.
+func writeMarked(b *strings.Builder, text string, tokens []token, offset int, highlight map[string]bool) {
+    pos := 0
+    for _, t := range tokens {
+        if !highlight[t.term] {
+            continue
+        }
+
+        start, end := t.start-offset, t.end-offset
+        b.WriteString(template.HTMLEscapeString(text[pos:start]))
+        b.WriteString("<mark>")
+        b.WriteString(template.HTMLEscapeString(text[start:end]))
+        b.WriteString("</mark>")
+        pos = end
+    }
+    b.WriteString(template.HTMLEscapeString(text[pos:]))
+}
@@ -0,0 +1,61 @@
+package search
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestMakeSnippet(t *testing.T) {
+    words := func(from, to int) string {
+        var w []string
+        for i := from; i < to; i++ {
+            w = append(w, "word")
+        }
+        return strings.Join(w, " ")
+    }
+    highlight := map[string]bool{"golang": true, "gopher": true}
+
+    tests := []struct {
+        name string
+        text string
+        want string
+    }{
+        {"no match", "nothing to see", ""},
+        // Snippets run from the first word to the last.
+        {"short", "I like golang.", "I like <mark>golang</mark>"},
+        {"escaped", "golang <b> & co", "<mark>golang</mark> &lt;b&gt; &amp; co"},
+        {"accents and case", "Golang, GOPHER", "<mark>Golang</mark>, <mark>GOPHER</mark>"},
+        {
+            "cut at the end",
+            "golang " + words(0, 40),
+            "<mark>golang</mark> " + words(0, snippetWords-1) + " …",
+        },
+        {
+            "cut at both ends, with some words before the match",
+            words(0, 50) + " golang " + words(0, 50),
+            "… " + words(0, 5) + " <mark>golang</mark> " + words(0, snippetWords-6) + " …",
+        },
+    }
+    for _, test := range tests {
+        if got := string(makeSnippet(test.text, highlight)); got != test.want {
+            t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+        }
+    }
+}
+
+func TestMakeSnippetPicksTheBestStretch(t *testing.T) {
+    highlight := map[string]bool{"golang": true}
+    text := "golang " + strings.Repeat("filler ", 60) + "golang golang golang " + strings.Repeat("filler ", 60)
+
+    got := string(makeSnippet(text, highlight))
+    if strings.Count(got, "<mark>") != 3 {
+        t.Errorf("snippet %q, want the stretch with three matches", got)
+    }
+}
+
+func TestMarkAll(t *testing.T) {
+    got := string(markAll("Gophers & golang <3", map[string]bool{"golang": true}))
+    if want := "Gophers &amp; <mark>golang</mark> &lt;3"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+}
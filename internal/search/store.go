@@ -0,0 +1,45 @@
+package search
+
+import (
+    "context"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// Store keeps an Index up to date with the posts created, edited and deleted
+// through it. Everything else goes straight to the wrapped store.
+type Store struct {
+    repository.Store
+    index *Index
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func NewStore(store repository.Store, index *Index) *Store {
+    return &Store{Store: store, index: index}
+}
+
+func (s *Store) CreatePost(ctx context.Context, post repository.Post) (repository.Post, error) {
+    post, err := s.Store.CreatePost(ctx, post)
+    if err == nil {
+        s.index.Add(post)
+    }
+    return post, err
+}
+
+func (s *Store) UpdatePost(ctx context.Context, post repository.Post) (repository.Post, error) {
+    post, err := s.Store.UpdatePost(ctx, post)
+    if err == nil {
+        s.index.Add(post)
+    }
+    return post, err
+}
+
+func (s *Store) DeletePost(ctx context.Context, id string) error {
+    err := s.Store.DeletePost(ctx, id)
+    if err == nil {
+        objectId, _ := primitive.ObjectIDFromHex(id)
+        s.index.Remove(objectId)
+    }
+    return err
+}
@@ -0,0 +1,31 @@
+package internal
+
+import (
+    "context"
+    "log"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// sessionSweepInterval is how often expired sessions are deleted.
+const sessionSweepInterval = time.Hour
+
+// RunSessionSweeper deletes expired sessions every sessionSweepInterval and
+// returns when ctx is done. Mongo already expires them with a TTL index, but
+// the other stores only have this.
+func RunSessionSweeper(ctx context.Context, sessions repository.SessionStore) {
+    for {
+        deleted, err := sessions.DeleteExpiredSessions(ctx, time.Now())
+        if err != nil {
+            log.Printf("Error deleting expired sessions: %v\n", err)
+        } else if deleted > 0 {
+            log.Printf("Deleted %d expired sessions\n", deleted)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(sessionSweepInterval):
+        }
+    }
+}
@@ -0,0 +1,79 @@
+// Package sitemap writes sitemaps and sitemap indexes following
+// https://www.sitemaps.org/protocol.html.
+package sitemap
+
+import (
+    "encoding/xml"
+    "time"
+)
+
+// MaxURLs is the most URLs the protocol allows in one sitemap. Sites with
+// more split them over several sitemaps listed in an index.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a page in a sitemap, or a sitemap in an index. Loc must be absolute.
+// A zero LastMod is left out.
+type URL struct {
+    Loc     string
+    LastMod time.Time
+}
+
+type urlSet struct {
+    XMLName xml.Name   `xml:"urlset"`
+    Xmlns   string     `xml:"xmlns,attr"`
+    URLs    []location `xml:"url"`
+}
+
+type sitemapIndex struct {
+    XMLName  xml.Name   `xml:"sitemapindex"`
+    Xmlns    string     `xml:"xmlns,attr"`
+    Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+    Loc     string `xml:"loc"`
+    LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap writes a sitemap listing urls, which should be no more than MaxURLs.
+func Sitemap(urls []URL) ([]byte, error) {
+    return marshal(urlSet{Xmlns: namespace, URLs: locations(urls)})
+}
+
+// Index writes a sitemap index listing the sitemaps at urls.
+func Index(sitemaps []URL) ([]byte, error) {
+    return marshal(sitemapIndex{Xmlns: namespace, Sitemaps: locations(sitemaps)})
+}
+
+// Latest is the latest LastMod among urls, zero if none has one.
+func Latest(urls []URL) time.Time {
+    var latest time.Time
+    for _, u := range urls {
+        if u.LastMod.After(latest) {
+            latest = u.LastMod
+        }
+    }
+    return latest
+}
+
+func locations(urls []URL) []location {
+    list := make([]location, 0, len(urls))
+    for _, u := range urls {
+        loc := location{Loc: u.Loc}
+        if !u.LastMod.IsZero() {
+            loc.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+        }
+        list = append(list, loc)
+    }
+    return list
+}
+
+func marshal(doc any) ([]byte, error) {
+    out, err := xml.MarshalIndent(doc, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,171 @@
+package spam
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "net/url"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "unicode"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+const (
+    // minTraining is how many comments of each kind the classifier needs to
+    // have seen before it gives an opinion.
+    minTraining = 5
+    // interesting is how many of the words furthest from neutral are weighed
+    // for a score.
+    interesting = 15
+    // strength is how many sightings it takes for a word's own odds to
+    // outweigh the neutral guess of 0.5.
+    strength = 1.0
+)
+
+// Classifier is a naive Bayes classifier learning what spam looks like from
+// the comments admins mark as spam or approve.
+type Classifier struct {
+    lock     sync.RWMutex
+    spam     map[string]int
+    ham      map[string]int
+    spamDocs int
+    hamDocs  int
+}
+
+func NewClassifier() *Classifier {
+    return &Classifier{spam: map[string]int{}, ham: map[string]int{}}
+}
+
+// Build trains the classifier with every comment admins have already
+// moderated.
+func (c *Classifier) Build(ctx context.Context, store repository.CommentStore) error {
+    for _, status := range []string{repository.CommentSpam, repository.CommentApproved} {
+        comments, err := store.QueryComments(ctx, repository.CommentQuery{Status: status})
+        if err != nil {
+            return err
+        }
+
+        for _, comment := range comments {
+            c.Learn(comment, 1)
+        }
+    }
+    return nil
+}
+
+// Learn adds a comment moderated as spam or approved to what the classifier
+// knows, or takes it back out with a weight of -1. Comments nobody has
+// moderated and rejected ones, which aren't necessarily spam, are skipped.
+func (c *Classifier) Learn(comment repository.Comment, weight int) {
+    if comment.ModeratedAt.IsZero() {
+        return
+    }
+
+    var counts map[string]int
+    var docs *int
+    switch comment.Status {
+    case repository.CommentSpam:
+        counts, docs = c.spam, &c.spamDocs
+    case repository.CommentApproved:
+        counts, docs = c.ham, &c.hamDocs
+    default:
+        return
+    }
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    *docs += weight
+    for _, f := range features(text(comment.Name, comment.Email, comment.Body)) {
+        counts[f] += weight
+        if counts[f] <= 0 {
+            delete(counts, f)
+        }
+    }
+}
+
+// Score is the probability that text is spam, 0.5 while the classifier
+// hasn't seen enough to tell.
+func (c *Classifier) Score(text string) float64 {
+    c.lock.RLock()
+    defer c.lock.RUnlock()
+
+    if c.spamDocs < minTraining || c.hamDocs < minTraining {
+        return 0.5
+    }
+
+    var probs []float64
+    for _, f := range features(text) {
+        spam := float64(c.spam[f]) / float64(c.spamDocs)
+        ham := float64(c.ham[f]) / float64(c.hamDocs)
+        if spam+ham == 0 {
+            continue
+        }
+
+        // Words seen only a few times lean towards neutral.
+        seen := float64(c.spam[f] + c.ham[f])
+        p := (strength*0.5 + seen*spam/(spam+ham)) / (strength + seen)
+        probs = append(probs, min(max(p, 0.01), 0.99))
+    }
+
+    sort.Slice(probs, func(i, j int) bool {
+        return math.Abs(probs[i]-0.5) > math.Abs(probs[j]-0.5)
+    })
+    if len(probs) > interesting {
+        probs = probs[:interesting]
+    }
+
+    // Multiply the odds in log space so long comments don't underflow.
+    var logSpam, logHam float64
+    for _, p := range probs {
+        logSpam += math.Log(p)
+        logHam += math.Log(1 - p)
+    }
+    return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+// Check turns the classifier's score into a signal. Only leaning towards
+// spam counts; a score of 0.5 or less adds nothing.
+func (c *Classifier) Check(s Submission) Signal {
+    p := c.Score(s.text())
+    if p <= 0.5 {
+        return Signal{}
+    }
+    return Signal{Score: 2 * (p - 0.5), Reason: fmt.Sprintf("reads like past spam (%.0f%%)", p*100)}
+}
+
+var urlPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s()<>"\]]+`)
+
+// features lists the distinct words of text, lower cased, and the hosts it
+// links to.
+func features(text string) []string {
+    seen := map[string]bool{}
+    var list []string
+    add := func(f string) {
+        if !seen[f] {
+            seen[f] = true
+            list = append(list, f)
+        }
+    }
+
+    for _, raw := range urlPattern.FindAllString(text, -1) {
+        if !strings.Contains(raw, "://") {
+            raw = "http://" + raw
+        }
+        if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+            add("host:" + strings.ToLower(u.Hostname()))
+        }
+    }
+
+    words := strings.FieldsFunc(text, func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '$' && r != '\''
+    })
+    for _, w := range words {
+        if n := len(w); n >= 2 && n <= 30 {
+            add(strings.ToLower(w))
+        }
+    }
+    return list
+}
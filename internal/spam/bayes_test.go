@@ -0,0 +1,160 @@
+package spam
+
+import (
+    "context"
+    "fmt"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+func moderated(status, body string) repository.Comment {
+    return repository.Comment{Name: "someone", Email: "someone@example.com", Body: body, Status: status, ModeratedAt: time.Now()}
+}
+
+var (
+    spamBodies = []string{
+        "Buy cheap pills now at http://pills.example",
+        "Cheap casino bonus, visit www.casino.example today",
+        "Best cheap pills online http://pills.example/buy",
+        "Win big at the casino http://casino.example",
+        "Cheap pills and casino deals, buy now",
+        "Limited offer: cheap pills http://pills.example",
+    }
+    hamBodies = []string{
+        "Thanks, this explained generics in Go really well",
+        "Great post, the benchmark section was really useful",
+        "I had the same problem with modules, thanks for writing this up",
+        "Could you write more about Go generics and interfaces?",
+        "Really clear explanation of the benchmark results",
+        "Thanks for the post, it helped with my modules setup",
+    }
+)
+
+func trained() *Classifier {
+    c := NewClassifier()
+    for _, body := range spamBodies {
+        c.Learn(moderated(repository.CommentSpam, body), 1)
+    }
+    for _, body := range hamBodies {
+        c.Learn(moderated(repository.CommentApproved, body), 1)
+    }
+    return c
+}
+
+func TestClassifierScore(t *testing.T) {
+    c := trained()
+
+    tests := []struct {
+        text     string
+        min, max float64
+    }{
+        {"Cheap pills, buy now at http://pills.example", 0.9, 1},
+        {"Visit the casino for cheap deals", 0.8, 1},
+        {"Thanks, really useful post about Go generics", 0, 0.1},
+        {"Benchmark and modules, thanks", 0, 0.2},
+        // Words it has never seen give no opinion.
+        {"Zebra quantum marmalade", 0.5, 0.5},
+        {"", 0.5, 0.5},
+    }
+    for _, test := range tests {
+        if got := c.Score(test.text); got < test.min || got > test.max {
+            t.Errorf("Score(%q) = %.3f, want between %v and %v", test.text, got, test.min, test.max)
+        }
+    }
+}
+
+func TestClassifierLearning(t *testing.T) {
+    c := NewClassifier()
+    spammy := "Cheap pills, buy now at http://pills.example"
+
+    // Until it has seen enough of both kinds it has no opinion.
+    for i, body := range spamBodies[:minTraining] {
+        c.Learn(moderated(repository.CommentSpam, body), 1)
+        c.Learn(moderated(repository.CommentApproved, hamBodies[i]), 1)
+        if got := c.Score(spammy); i < minTraining-1 && got != 0.5 {
+            t.Fatalf("after %d of each scored %.3f, want 0.5", i+1, got)
+        }
+    }
+    if got := c.Score(spammy); got <= 0.5 {
+        t.Fatalf("after %d of each scored %.3f", minTraining, got)
+    }
+
+    // Comments nobody moderated, and rejected ones, teach it nothing.
+    before := c.Score(spammy)
+    c.Learn(repository.Comment{Body: spammy, Status: repository.CommentSpam}, 1)
+    c.Learn(moderated(repository.CommentRejected, spammy), 1)
+    c.Learn(moderated(repository.CommentPending, spammy), 1)
+    if got := c.Score(spammy); got != before {
+        t.Errorf("unmoderated comments moved the score from %.3f to %.3f", before, got)
+    }
+
+    // Taking a comment back out undoes learning it.
+    cheap := c.spam["cheap"]
+    c.Learn(moderated(repository.CommentSpam, spamBodies[0]), -1)
+    if c.spamDocs != minTraining-1 || c.spam["cheap"] != cheap-1 {
+        t.Errorf("after unlearning %d spam, cheap seen %d times, want %d and %d", c.spamDocs, c.spam["cheap"], minTraining-1, cheap-1)
+    }
+    if got := c.Score(spammy); got != 0.5 {
+        t.Errorf("below the minimum again scored %.3f, want 0.5", got)
+    }
+}
+
+func TestClassifierCheck(t *testing.T) {
+    c := trained()
+
+    if got := c.Check(Submission{Body: "Thanks, really useful post about Go generics"}); got != (Signal{}) {
+        t.Errorf("ham gave %+v", got)
+    }
+    got := c.Check(Submission{Name: "cheap pills", Body: "Buy cheap pills now http://pills.example"})
+    if got.Score < 0.8 || !strings.HasPrefix(got.Reason, "reads like past spam") || got.Reject {
+        t.Errorf("spam gave %+v", got)
+    }
+}
+
+func TestClassifierBuild(t *testing.T) {
+    ctx := context.Background()
+    store := repository.NewMemoryStore()
+    post, err := store.CreatePost(ctx, repository.Post{Title: "Post"})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    add := func(body, status string) {
+        comment, err := store.CreateComment(ctx, repository.Comment{PostId: post.Id, Name: "someone", Body: body})
+        if err == nil && status != "" {
+            _, err = store.SetCommentStatus(ctx, comment.Id, status)
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    for i := range spamBodies {
+        add(spamBodies[i], repository.CommentSpam)
+        add(hamBodies[i], repository.CommentApproved)
+        add(fmt.Sprintf("Pending comment %d", i), "")
+    }
+
+    c := NewClassifier()
+    if err := c.Build(ctx, store); err != nil {
+        t.Fatal(err)
+    }
+    if c.spamDocs != len(spamBodies) || c.hamDocs != len(hamBodies) {
+        t.Errorf("learned %d spam and %d ham, want %d of each", c.spamDocs, c.hamDocs, len(spamBodies))
+    }
+    if _, ok := c.ham["pending"]; ok {
+        t.Error("learned from a pending comment")
+    }
+}
+
+func TestFeatures(t *testing.T) {
+    got := features("Visit WWW.Example.com and https://Shop.example/buy?x=1, visit again! a I'm $100 " + strings.Repeat("x", 31))
+    // Hosts first, then each word once, lower cased, between 2 and 30
+    // letters long.
+    want := []string{"host:www.example.com", "host:shop.example", "visit", "www", "example", "com", "and", "https", "shop", "buy", "again", "i'm", "$100"}
+    if !slices.Equal(got, want) {
+        t.Errorf("features = %q\nwant       %q", got, want)
+    }
+}
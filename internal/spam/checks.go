@@ -0,0 +1,158 @@
+package spam
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Honeypot catches bots that fill in every field of a form, including one
+// people never see.
+type Honeypot struct{}
+
+func (Honeypot) Check(s Submission) Signal {
+    if s.Honeypot != "" {
+        return Signal{Score: 1, Reason: "filled in the hidden field"}
+    }
+    return Signal{}
+}
+
+// tokenMaxAge is how long a form can stay open before its token is stale.
+const tokenMaxAge = 24 * time.Hour
+
+// Tokens signs the time a form was served. A submission that comes back
+// quicker than a person could write it, or that never loaded the form, stands
+// out. The key is made at startup, so forms served before a restart come back
+// with a token that no longer checks out, which only counts as a weak signal.
+type Tokens struct {
+    key      []byte
+    minDelay time.Duration
+    maxAge   time.Duration
+}
+
+func NewTokens(minDelay, maxAge time.Duration) *Tokens {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        panic(err)
+    }
+    return &Tokens{key: key, minDelay: minDelay, maxAge: maxAge}
+}
+
+// Issue makes the token for a form served now.
+func (t *Tokens) Issue() string {
+    issued := strconv.FormatInt(time.Now().UnixMilli(), 36)
+    return issued + "." + t.sign(issued)
+}
+
+func (t *Tokens) sign(issued string) string {
+    mac := hmac.New(sha256.New, t.key)
+    mac.Write([]byte(issued))
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (t *Tokens) Check(s Submission) Signal {
+    issued, signature, ok := strings.Cut(s.Token, ".")
+    if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(issued))) {
+        return Signal{Score: 0.8, Reason: "missing or invalid form token"}
+    }
+
+    millis, err := strconv.ParseInt(issued, 36, 64)
+    if err != nil {
+        return Signal{Score: 0.8, Reason: "missing or invalid form token"}
+    }
+
+    elapsed := time.Since(time.UnixMilli(millis))
+    switch {
+    case elapsed < t.minDelay:
+        return Signal{Score: 1, Reason: fmt.Sprintf("sent %s after loading the form", elapsed.Round(time.Millisecond))}
+    case elapsed > t.maxAge:
+        return Signal{Score: 0.5, Reason: "form token expired"}
+    }
+    return Signal{}
+}
+
+// RateLimit turns away an IP once it has sent limit submissions within the
+// last window.
+type RateLimit struct {
+    lock      sync.Mutex
+    limit     int
+    window    time.Duration
+    sent      map[string][]time.Time
+    lastSweep time.Time
+    // now tells the time, which tests move along themselves.
+    now       func() time.Time
+}
+
+func NewRateLimit(limit int, window time.Duration) *RateLimit {
+    return &RateLimit{limit: limit, window: window, sent: map[string][]time.Time{}, lastSweep: time.Now(), now: time.Now}
+}
+
+func (l *RateLimit) Check(s Submission) Signal {
+    l.lock.Lock()
+    defer l.lock.Unlock()
+
+    now := l.now()
+    since := now.Add(-l.window)
+
+    // Forget IPs that have gone quiet so the map doesn't keep growing.
+    if now.Sub(l.lastSweep) > l.window {
+        for ip, times := range l.sent {
+            if times[len(times)-1].Before(since) {
+                delete(l.sent, ip)
+            }
+        }
+        l.lastSweep = now
+    }
+
+    times := l.sent[s.IP]
+    for len(times) > 0 && times[0].Before(since) {
+        times = times[1:]
+    }
+
+    if len(times) >= l.limit {
+        l.sent[s.IP] = times
+        return Signal{Score: 1, Reason: "too many submissions", Reject: true}
+    }
+
+    l.sent[s.IP] = append(times, now)
+    return Signal{}
+}
+
+var (
+    link       = regexp.MustCompile(`(?i)https?://|www\.|<a\s|\[url`)
+    markupLink = regexp.MustCompile(`(?i)<a\s|\[url`)
+)
+
+// Links scores submissions by how many links they carry, reaching 1 at Max.
+// Links in the name, or written as HTML or BBCode, which comments don't
+// render, are taken for spam outright.
+type Links struct {
+    Max int
+}
+
+func (l Links) Check(s Submission) Signal {
+    if link.MatchString(s.Name) {
+        return Signal{Score: 1, Reason: "link in the name"}
+    }
+    if markupLink.MatchString(s.Body) {
+        return Signal{Score: 1, Reason: "HTML or BBCode links"}
+    }
+
+    n := len(link.FindAllStringIndex(s.Body, -1))
+    if n == 0 {
+        return Signal{}
+    }
+
+    reason := "1 link"
+    if n > 1 {
+        reason = fmt.Sprintf("%d links", n)
+    }
+    return Signal{Score: min(float64(n)/float64(l.Max), 1), Reason: reason}
+}
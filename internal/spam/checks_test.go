@@ -0,0 +1,122 @@
+package spam
+
+import (
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestTokens(t *testing.T) {
+    tokens := NewTokens(3*time.Second, time.Hour)
+    // token signs a form served at, the way Issue would have then.
+    token := func(at time.Time) string {
+        issued := strconv.FormatInt(at.UnixMilli(), 36)
+        return issued + "." + tokens.sign(issued)
+    }
+    now := time.Now()
+    valid := token(now.Add(-time.Minute))
+    issued, signature, _ := strings.Cut(valid, ".")
+
+    tests := []struct {
+        name   string
+        token  string
+        score  float64
+        reason string
+    }{
+        {"valid", valid, 0, ""},
+        {"just issued", tokens.Issue(), 1, "after loading the form"},
+        {"too fast", token(now.Add(-time.Second)), 1, "after loading the form"},
+        {"expired", token(now.Add(-2 * time.Hour)), 0.5, "form token expired"},
+        {"missing", "", 0.8, "missing or invalid form token"},
+        {"no signature", issued, 0.8, "missing or invalid form token"},
+        {"tampered signature", issued + "." + strings.ToUpper(signature), 0.8, "missing or invalid form token"},
+        {"tampered time", strconv.FormatInt(now.Add(-time.Hour).UnixMilli(), 36) + "." + signature, 0.8, "missing or invalid form token"},
+        {"other key", NewTokens(0, time.Hour).Issue(), 0.8, "missing or invalid form token"},
+        {"signed garbage", "!!." + tokens.sign("!!"), 0.8, "missing or invalid form token"},
+    }
+    for _, test := range tests {
+        signal := tokens.Check(Submission{Token: test.token})
+        if signal.Score != test.score || !strings.Contains(signal.Reason, test.reason) || signal.Reject {
+            t.Errorf("%s: got %+v, want score %v for %q", test.name, signal, test.score, test.reason)
+        }
+    }
+}
+
+// clock is a time that only moves when told to.
+type clock struct {
+    t time.Time
+}
+
+func (c *clock) Now() time.Time {
+    return c.t
+}
+
+func TestRateLimit(t *testing.T) {
+    c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+    l := NewRateLimit(3, time.Minute)
+    l.now = c.Now
+    l.lastSweep = c.Now()
+
+    steps := []struct {
+        advance time.Duration
+        ip      string
+        reject  bool
+    }{
+        {0, "192.0.2.1", false},
+        {20 * time.Second, "192.0.2.1", false},
+        {20 * time.Second, "192.0.2.1", false},
+        // The fourth within a minute is turned away, but not other IPs.
+        {10 * time.Second, "192.0.2.1", true},
+        {0, "192.0.2.2", false},
+        // A window is exactly a minute: the first still counts at 60s...
+        {10 * time.Second, "192.0.2.1", true},
+        // ...and no longer past it, freeing one slot.
+        {time.Second, "192.0.2.1", false},
+        {0, "192.0.2.1", true},
+        // Turned away submissions don't count, so the next slot frees up
+        // once the second one ages out.
+        {20 * time.Second, "192.0.2.1", false},
+        // A quiet minute later, everything is forgotten.
+        {2 * time.Minute, "192.0.2.1", false},
+        {0, "192.0.2.1", false},
+        {0, "192.0.2.1", false},
+        {0, "192.0.2.1", true},
+    }
+    for i, step := range steps {
+        c.t = c.t.Add(step.advance)
+        signal := l.Check(Submission{IP: step.ip})
+        if signal.Reject != step.reject {
+            t.Fatalf("step %d, %s at %s: rejected %v, want %v", i+1, step.ip, c.t.Format(time.TimeOnly), signal.Reject, step.reject)
+        }
+        if step.reject && (signal.Score != 1 || signal.Reason != "too many submissions") {
+            t.Errorf("step %d: signal %+v", i+1, signal)
+        }
+    }
+
+    if _, ok := l.sent["192.0.2.2"]; ok {
+        t.Error("quiet IP wasn't swept")
+    }
+}
+
+func TestLinks(t *testing.T) {
+    links := Links{Max: 3}
+    tests := []struct {
+        name  string
+        s     Submission
+        score float64
+    }{
+        {"none", Submission{Body: "Nice post."}, 0},
+        {"one", Submission{Body: "See https://example.com"}, 1.0 / 3},
+        {"two", Submission{Body: "www.a.example and http://b.example"}, 2.0 / 3},
+        {"capped", Submission{Body: strings.Repeat("http://x.example ", 5)}, 1},
+        {"in the name", Submission{Name: "www.cheap.example", Body: "Hi"}, 1},
+        {"html", Submission{Body: `<a href="x">x</a>`}, 1},
+        {"bbcode", Submission{Body: "[url=x]x[/url]"}, 1},
+    }
+    for _, test := range tests {
+        if got := links.Check(test.s); got.Score != test.score {
+            t.Errorf("%s: score %v, want %v", test.name, got.Score, test.score)
+        }
+    }
+}
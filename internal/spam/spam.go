@@ -0,0 +1,105 @@
+// Package spam decides how likely a comment is to be spam before it is
+// stored, from a chain of independent checks.
+package spam
+
+import (
+    "strings"
+    "github.com/vinny-pereira/personal-blog/internal/config"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// Submission is what a reader sent through a form along with where it came
+// from.
+type Submission struct {
+    IP       string
+    Name     string
+    Email    string
+    Body     string
+    // Honeypot is a field hidden from people, so only bots fill it in.
+    Honeypot string
+    // Token is the one issued with the form by Tokens.
+    Token    string
+}
+
+// text is what the classifier reads of a submission.
+func (s Submission) text() string {
+    return text(s.Name, s.Email, s.Body)
+}
+
+func text(name, email, body string) string {
+    domain := ""
+    if at := strings.LastIndexByte(email, '@'); at >= 0 {
+        domain = email[at+1:]
+    }
+    return name + "\n" + domain + "\n" + body
+}
+
+// Signal is one check's opinion of a submission. Score goes from 0 to 1 like
+// a verdict's and Reason says what was found when it isn't 0.
+type Signal struct {
+    Score  float64
+    Reason string
+    // Reject asks for the submission to be turned away without storing it.
+    Reject bool
+}
+
+type Check interface {
+    Check(s Submission) Signal
+}
+
+// Chain runs its checks in order and combines what they found, so a few
+// weak signals together can add up to spam. The first check rejecting the
+// submission stops the chain.
+type Chain []Check
+
+func (c Chain) Check(s Submission) (verdict repository.Verdict, rejected bool) {
+    clean := 1.0
+    for _, check := range c {
+        signal := check.Check(s)
+        if signal.Reason != "" {
+            verdict.Reasons = append(verdict.Reasons, signal.Reason)
+        }
+        if signal.Reject {
+            return verdict, true
+        }
+        clean *= 1 - min(max(signal.Score, 0), 1)
+    }
+
+    verdict.Score = 1 - clean
+    return verdict, false
+}
+
+// Filter is the chain comments go through and the tokens their forms carry.
+type Filter struct {
+    Tokens    *Tokens
+    Checks    Chain
+    // Threshold is the score from which a submission is taken for spam.
+    Threshold float64
+}
+
+// NewFilter sets up the default chain: the honeypot, the form token, a
+// per-IP rate limit, the number of links and the classifier.
+func NewFilter(cfg config.SpamConfig, classifier *Classifier) *Filter {
+    tokens := NewTokens(cfg.MinDelay.Duration, tokenMaxAge)
+    return &Filter{
+        Tokens: tokens,
+        Checks: Chain{
+            Honeypot{},
+            tokens,
+            NewRateLimit(cfg.RateLimit, cfg.RateWindow.Duration),
+            Links{Max: cfg.MaxLinks},
+            classifier,
+        },
+        Threshold: cfg.Threshold,
+    }
+}
+
+// Check runs the chain over s. When it isn't rejected, status is where the
+// submission should go: straight to spam or to the moderation queue.
+func (f *Filter) Check(s Submission) (verdict repository.Verdict, status string, rejected bool) {
+    verdict, rejected = f.Checks.Check(s)
+    if verdict.Score >= f.Threshold {
+        return verdict, repository.CommentSpam, rejected
+    }
+    return verdict, repository.CommentPending, rejected
+}
@@ -0,0 +1,36 @@
+package spam
+
+import (
+    "context"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "github.com/vinny-pereira/personal-blog/internal/repository"
+)
+
+// Store teaches a Classifier from the comments moderated through it.
+// Everything else goes straight to the wrapped store.
+type Store struct {
+    repository.Store
+    classifier *Classifier
+}
+
+var _ repository.Store = (*Store)(nil)
+
+func NewStore(store repository.Store, classifier *Classifier) *Store {
+    return &Store{Store: store, classifier: classifier}
+}
+
+// SetCommentStatus takes back what the comment taught the classifier before,
+// in case an admin changed their mind, and learns from the new decision.
+func (s *Store) SetCommentStatus(ctx context.Context, id primitive.ObjectID, status string) (repository.Comment, error) {
+    before, err := s.Store.GetComment(ctx, id)
+    if err != nil {
+        return before, err
+    }
+
+    comment, err := s.Store.SetCommentStatus(ctx, id, status)
+    if err == nil {
+        s.classifier.Learn(before, -1)
+        s.classifier.Learn(comment, 1)
+    }
+    return comment, err
+}
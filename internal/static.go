@@ -0,0 +1,92 @@
+package internal
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "io/fs"
+    "mime"
+    "net/http"
+    "path"
+)
+
+// contentTypes covers the assets whose type the platform mime table may not
+// know, so the browser never has to sniff them.
+var contentTypes = map[string]string{
+    ".css":   "text/css; charset=utf-8",
+    ".js":    "text/javascript; charset=utf-8",
+    ".ico":   "image/x-icon",
+    ".jpg":   "image/jpeg",
+    ".jpeg":  "image/jpeg",
+    ".png":   "image/png",
+    ".svg":   "image/svg+xml",
+    ".webp":  "image/webp",
+    ".woff2": "font/woff2",
+}
+
+// Assets are the static files served under /dist/.
+type Assets struct {
+    FS fs.FS
+    // Version changes whenever any asset does; pages append it to asset URLs
+    // as a cache buster.
+    Version string
+    // Immutable assets can be cached by browsers for a year. Assets read from
+    // an on-disk directory are not, so edits show up on the next reload.
+    Immutable bool
+}
+
+func NewAssets(fsys fs.FS, immutable bool) (*Assets, error) {
+    version, err := AssetVersion(fsys)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Assets{FS: fsys, Version: version, Immutable: immutable}, nil
+}
+
+func (a *Assets) Handler() http.Handler {
+    files := http.FileServer(http.FS(a.FS))
+    immutable := a.Immutable
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ext := path.Ext(r.URL.Path)
+        if ct, ok := contentTypes[ext]; ok {
+            w.Header().Set("Content-Type", ct)
+        } else if ct := mime.TypeByExtension(ext); ct != "" {
+            w.Header().Set("Content-Type", ct)
+        }
+
+        if immutable {
+            w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+        } else {
+            w.Header().Set("Cache-Control", "no-cache")
+        }
+
+        files.ServeHTTP(w, r)
+    })
+}
+
+// AssetVersion hashes every file in fsys.
+func AssetVersion(fsys fs.FS) (string, error) {
+    h := sha256.New()
+    err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+        if err != nil || d.IsDir() {
+            return err
+        }
+
+        f, err := fsys.Open(p)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+
+        io.WriteString(h, p)
+        _, err = io.Copy(h, f)
+        return err
+    })
+    if err != nil {
+        return "", err
+    }
+
+    return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+    "context"
+    "fmt"
+    "html/template"
+    "io/fs"
+    "log"
+    "path/filepath"
+    "sync/atomic"
+    "time"
+)
+
+// Templates is the parsed template set shared by every handler. Get is safe
+// to call from any goroutine; Reload parses the files again and swaps the new
+// set in atomically, so in-flight requests keep the set they started with.
+type Templates struct {
+    fsys    fs.FS
+    current atomic.Pointer[template.Template]
+}
+
+// LoadTemplates parses every template in fsys once. It fails if any of them
+// has a parse error, so a broken template is caught at startup.
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+    t := &Templates{fsys: fsys}
+    if err := t.Reload(); err != nil {
+        return nil, err
+    }
+    return t, nil
+}
+
+func (t *Templates) Get() *template.Template {
+    return t.current.Load()
+}
+
+func (t *Templates) Reload() error {
+    tmpl, err := ParseTemplates(t.fsys)
+    if err != nil {
+        return fmt.Errorf("parsing templates: %w", err)
+    }
+
+    t.current.Store(tmpl)
+    return nil
+}
+
+// Watch polls the template files and reloads the set whenever a file is
+// added, removed or modified. A set that fails to parse is logged and the
+// previous one stays in use. It returns when ctx is done.
+func (t *Templates) Watch(ctx context.Context, interval time.Duration) {
+    last, err := fingerprint(t.fsys)
+    if err != nil {
+        log.Printf("Error watching templates: %v\n", err)
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+
+        current, err := fingerprint(t.fsys)
+        if err != nil {
+            log.Printf("Error watching templates: %v\n", err)
+            continue
+        }
+
+        if current == last {
+            continue
+        }
+        last = current
+
+        if err := t.Reload(); err != nil {
+            log.Printf("Keeping previous templates: %v\n", err)
+            continue
+        }
+        log.Println("Templates reloaded")
+    }
+}
+
+// fingerprint summarises the names, sizes and modification times of the
+// templates in fsys so a change to any of them changes the result.
+func fingerprint(fsys fs.FS) (string, error) {
+    var sum string
+    err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+
+        if d.IsDir() || filepath.Ext(path) != ".html" {
+            return nil
+        }
+
+        info, err := d.Info()
+        if err != nil {
+            return err
+        }
+
+        sum += fmt.Sprintf("%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+        return nil
+    })
+
+    return sum, err
+}
@@ -0,0 +1,110 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 that
+// authenticator apps generate, with the defaults they all support: SHA-1,
+// six digits and 30 second steps.
+package totp
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+    "rsc.io/qr"
+)
+
+const (
+    // Period is how long each code is good for.
+    Period = 30 * time.Second
+    // Digits is how long codes are.
+    Digits = 6
+    // Skew is how many steps before and after the current one are also
+    // accepted, for clocks that are a little off.
+    Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret makes a random secret, base32 encoded the way apps take it.
+func NewSecret() (string, error) {
+    b := make([]byte, 20)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return encoding.EncodeToString(b), nil
+}
+
+// Step is the time step t falls in.
+func Step(t time.Time) int64 {
+    return t.Unix() / int64(Period/time.Second)
+}
+
+// Code is the code for secret at the given time step.
+func Code(secret string, step int64) (string, error) {
+    key, err := encoding.DecodeString(strings.ToUpper(secret))
+    if err != nil {
+        return "", fmt.Errorf("invalid TOTP secret: %w", err)
+    }
+
+    var counter [8]byte
+    binary.BigEndian.PutUint64(counter[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(counter[:])
+    sum := mac.Sum(nil)
+
+    // Dynamic truncation, RFC 4226 section 5.3.
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate looks for code among the steps around now and returns the step
+// it belongs to. Callers must refuse steps they already accepted a code for.
+func Validate(secret, code string, now time.Time) (int64, bool) {
+    if len(code) != Digits {
+        return 0, false
+    }
+
+    current := Step(now)
+    for step := current - Skew; step <= current+Skew; step++ {
+        expected, err := Code(secret, step)
+        if err != nil {
+            return 0, false
+        }
+        if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+// URL is the otpauth:// URL apps enrol the secret from.
+func URL(issuer, account, secret string) string {
+    u := url.URL{
+        Scheme: "otpauth",
+        Host:   "totp",
+        Path:   "/" + issuer + ":" + account,
+    }
+    q := url.Values{}
+    q.Set("secret", secret)
+    q.Set("issuer", issuer)
+    q.Set("algorithm", "SHA1")
+    q.Set("digits", fmt.Sprint(Digits))
+    q.Set("period", fmt.Sprint(int(Period/time.Second)))
+    u.RawQuery = q.Encode()
+    return u.String()
+}
+
+// QRCode renders url as a PNG QR code to be scanned by an app.
+func QRCode(url string) ([]byte, error) {
+    code, err := qr.Encode(url, qr.M)
+    if err != nil {
+        return nil, err
+    }
+    code.Scale = 6
+    return code.PNG(), nil
+}
@@ -0,0 +1,104 @@
+package totp
+
+import (
+    "encoding/base32"
+    "testing"
+    "time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// TestCode checks the SHA-1 test vectors of RFC 6238, appendix B. They have
+// eight digits; codes here are their last six.
+func TestCode(t *testing.T) {
+    tests := []struct {
+        unix int64
+        want string
+    }{
+        {59, "94287082"},
+        {1111111109, "07081804"},
+        {1111111111, "14050471"},
+        {1234567890, "89005924"},
+        {2000000000, "69279037"},
+        {20000000000, "65353130"},
+    }
+
+    for _, test := range tests {
+        step := Step(time.Unix(test.unix, 0))
+        got, err := Code(rfcSecret, step)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if want := test.want[len(test.want)-Digits:]; got != want {
+            t.Errorf("code at %d = %s, want %s", test.unix, got, want)
+        }
+    }
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+    upper, _ := Code(rfcSecret, 1)
+    lower, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+    if err != nil || lower != upper {
+        t.Errorf("lowercase secret gave %q, %v, want %q", lower, err, upper)
+    }
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+    if _, err := Code("not base32!", 1); err == nil {
+        t.Error("invalid secret gave no error")
+    }
+}
+
+func TestValidate(t *testing.T) {
+    // The step of 1111111109 starts at 1111111080 and ends a second after
+    // it. 1111111111 is a second into the next.
+    tests := []struct {
+        name string
+        code int64
+        now  int64
+        want bool
+    }{
+        {"same step", 1111111109, 1111111109, true},
+        {"start of the same step", 1111111080, 1111111109, true},
+        {"first second of the next step", 1111111111, 1111111111, true},
+        {"one step behind", 1111111080, 1111111111, true},
+        {"one step ahead", 1111111111, 1111111109, true},
+        {"two steps behind", 1111111050, 1111111111, false},
+        {"two steps ahead", 1111111140, 1111111109, false},
+        {"far away", 59, 1111111109, false},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            want := Step(time.Unix(test.code, 0))
+            code, err := Code(rfcSecret, want)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            step, ok := Validate(rfcSecret, code, time.Unix(test.now, 0))
+            if ok != test.want {
+                t.Fatalf("Validate = %v, want %v", ok, test.want)
+            }
+            // The step is what callers record to refuse the code again.
+            if ok && step != want {
+                t.Errorf("Validate gave step %d, want %d", step, want)
+            }
+        })
+    }
+}
+
+func TestValidateMalformed(t *testing.T) {
+    now := time.Unix(1234567890, 0)
+    code, _ := Code(rfcSecret, Step(now))
+
+    for _, bad := range []string{"", code[:Digits-1], code + "0", "89005924"} {
+        if _, ok := Validate(rfcSecret, bad, now); ok {
+            t.Errorf("Validate accepted %q", bad)
+        }
+    }
+    if _, ok := Validate("not base32!", code, now); ok {
+        t.Error("Validate accepted a code for an invalid secret")
+    }
+}
@@ -1,7 +1,7 @@
 package internal
 
 import(
-    "os"
+    "io/fs"
     "path/filepath"
     "bytes"
     "log"
@@ -22,15 +22,15 @@ func RenderTemplate(tmpl *template.Template, name string, data interface{}) (str
 }
 
 
-func ParseTemplates() (*template.Template, error) {
+func ParseTemplates(fsys fs.FS) (*template.Template, error) {
 	tmpl := template.New("")
-	err := filepath.Walk("./web/ui", func(path string, info os.FileInfo, err error) error {
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() && filepath.Ext(path) == ".html" {
-			_, err := tmpl.ParseFiles(path)
+		if !d.IsDir() && filepath.Ext(path) == ".html" {
+			_, err := tmpl.ParseFS(fsys, path)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,32 @@
+// Package web holds the templates and static assets compiled into the binary,
+// so the server runs the same no matter which directory it is started from.
+package web
+
+import (
+    "embed"
+    "io/fs"
+    "os"
+)
+
+//go:embed ui
+var ui embed.FS
+
+//go:embed wwwroot/dist
+var dist embed.FS
+
+// Templates returns the HTML templates. When dir is set they are read from
+// disk instead, which is how themes are worked on without rebuilding.
+func Templates(dir string) (fs.FS, error) {
+    if dir != "" {
+        return os.DirFS(dir), nil
+    }
+    return fs.Sub(ui, "ui")
+}
+
+// Static returns the assets served under /dist/, read from dir when it is set.
+func Static(dir string) (fs.FS, error) {
+    if dir != "" {
+        return os.DirFS(dir), nil
+    }
+    return fs.Sub(dist, "wwwroot/dist")
+}